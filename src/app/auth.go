@@ -0,0 +1,33 @@
+package main
+
+import (
+	"auth"
+	"config"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+func newAuthenticator(cfg config.Auth) (*auth.Authenticator, error) {
+	a := &auth.Authenticator{Disabled: cfg.Disabled}
+	if a.Disabled {
+		log.Warn("Authentication is disabled, every request is allowed.")
+		return a, nil
+	}
+
+	keys, err := auth.ParseAPIKeys(cfg.APIKeys.Get())
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_API_KEYS: %v", err)
+	}
+	a.APIKeys = keys
+	a.HMACSecret = []byte(cfg.JWTHS256Secret.Get())
+	if path := cfg.JWTRS256PublicKeyFile; path != "" {
+		if a.RSAPublicKey, err = auth.LoadRSAPublicKey(path); err != nil {
+			return nil, fmt.Errorf("invalid AUTH_JWT_RS256_PUBLIC_KEY_FILE: %v", err)
+		}
+	}
+
+	if len(a.APIKeys) == 0 && len(a.HMACSecret) == 0 && a.RSAPublicKey == nil {
+		log.Warn("No API keys or JWT keys are set, every request to the order endpoints will be rejected.")
+	}
+	return a, nil
+}
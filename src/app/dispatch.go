@@ -0,0 +1,54 @@
+package main
+
+import (
+	"config"
+	"context"
+	"entity"
+	log "github.com/sirupsen/logrus"
+	rh "requesthandler"
+	"sync"
+	"time"
+)
+
+const dispatchBatch = 100
+
+// setupDispatch sets the auto-dispatch settings of dep. In create mode new orders are queued for a background
+// dispatcher, in periodic mode unassigned orders are dispatched every interval. Both run until ctx is done.
+func setupDispatch(ctx context.Context, wg *sync.WaitGroup, dep *rh.Dependencies, cfg config.Dispatch) error {
+	dep.Dispatch = rh.DispatchConfig{Candidates: cfg.Candidates, MaxLocationAge: cfg.MaxLocationAge,
+		OnCreate: cfg.Enabled(config.DispatchCreate)}
+	if dep.Dispatch.OnCreate {
+		dep.Dispatch.Queue = make(chan entity.Order, cfg.QueueSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dep.RunDispatchQueue(ctx)
+		}()
+	}
+	if !cfg.Enabled(config.DispatchPeriodic) {
+		return nil
+	}
+
+	log.Printf("Dispatching unassigned orders every %v", cfg.Interval)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(cfg.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				n, err := dep.DispatchPending(ctx, dispatchBatch)
+				if err != nil {
+					log.Errorf("Cannot dispatch orders: %v", err)
+				}
+				if n > 0 {
+					log.Infof("Dispatched %d orders", n)
+				}
+			}
+		}
+	}()
+	return nil
+}
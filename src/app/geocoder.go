@@ -0,0 +1,43 @@
+package main
+
+import (
+	"config"
+	"fmt"
+	"geocoder"
+	log "github.com/sirupsen/logrus"
+)
+
+// newGeocoder returns the geocoder provider, nil for none
+func newGeocoder(provider string, cfg *config.Config) (geocoder.Provider, error) {
+	switch provider {
+	case geocoder.ProviderNone:
+		return nil, nil
+	case geocoder.ProviderGoogle:
+		return geocoder.NewGoogle(cfg.Maps.APIKey.Get())
+	case geocoder.ProviderNominatim:
+		return geocoder.NewNominatim(cfg.Geocoder.NominatimURL, cfg.Geocoder.UserAgent), nil
+	case geocoder.ProviderStub:
+		path := cfg.Geocoder.StubFile
+		g, err := geocoder.LoadStub(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GEOCODER_STUB_FILE %q: %v", path, err)
+		}
+		log.Warnf("Geocoding with the %d places of %s only.", len(g.Places), path)
+		return g, nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", provider)
+	}
+}
+
+// newGeocoders returns the geocoder and reverse geocoder providers, sharing one when both are the same
+func newGeocoders(cfg *config.Config) (geocoder.Geocoder, geocoder.ReverseGeocoder, error) {
+	geo, err := newGeocoder(cfg.Geocoder.Provider, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Geocoder.ReverseProvider == cfg.Geocoder.Provider {
+		return geo, geo, nil
+	}
+	reverse, err := newGeocoder(cfg.Geocoder.ReverseProvider, cfg)
+	return geo, reverse, err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"net"
+	"time"
+)
+
+// serveGRPC serves s on addr in the background, returning the function stopping it. Calls in flight get up to
+// shutdownTimeout to finish.
+func serveGRPC(s *grpc.Server, addr string, shutdownTimeout time.Duration) (func(), error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC server error: %v", err)
+	}
+	go func() {
+		log.Printf("Starting gRPC server on %s", lis.Addr())
+		if err := s.Serve(lis); err != nil {
+			log.Errorf("gRPC server error: %v", err)
+		}
+	}()
+	return func() {
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			s.Stop()
+		}
+		log.Println("gRPC server stopped")
+	}, nil
+}
@@ -1,33 +1,232 @@
 package main
 
 import (
+	"auth"
+	"config"
+	"context"
 	"dao"
 	"distancehelper"
 	"entity"
+	"eventbus"
+	"flag"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
+	"grpcapi"
+	"logging"
+	"metrics"
 	"net/http"
+	"openapi"
+	"os"
+	"os/signal"
+	"pricing"
+	"ratelimit"
 	rh "requesthandler"
+	"secret"
+	"sync"
+	"syscall"
+	"tracing"
 )
 
 func main() {
+	log.SetFormatter(&log.JSONFormatter{})
+	cfg, printConfig, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run returns only after the server stopped, so deferred cleanups are executed
+func run(cfg *config.Config) error {
+	// setup tracing
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing.Exporter)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("Cannot flush traces: %v", err)
+		}
+	}()
+
 	// setup db
 	log.Println("initializing DB...")
-	dao.InitDB()
+	dao.InitDB(cfg.Database.DSN)
 	DB := dao.GetDB()
 	defer DB.Close()
-	DB.AutoMigrate(&entity.Order{})
+	DB.AutoMigrate(&entity.Order{}, &entity.IdempotencyKey{}, &entity.ServiceArea{}, &entity.Setting{}, &entity.Courier{}, &entity.CourierLocation{},
+		&entity.WebhookSubscription{}, &entity.WebhookDelivery{}, &entity.OutboxEvent{})
+	metrics.RegisterGormCallbacks(DB)
+	tracing.RegisterGormCallbacks(DB)
 	log.Println("DB initialized")
 
-	dep := &rh.Dependencies{DB: DB, Map: &distancehelper.GMapReal{}, Dao: &dao.GormDB{}, MapHelper: &distancehelper.GMapHelper{}}
+	geo, reverse, err := newGeocoders(cfg)
+	if err != nil {
+		return err
+	}
+
+	var prices *pricing.Rules
+	if path := cfg.Pricing.RulesFile; path != "" {
+		if prices, err = pricing.LoadRules(path); err != nil {
+			return fmt.Errorf("invalid PRICING_RULES_FILE: %v", err)
+		}
+	} else {
+		log.Warn("PRICING_RULES_FILE is not set, orders are not priced.")
+	}
+	if cfg.Maps.APIKey.Get() == "" {
+		log.Warn("Google API key is not set, distance will always be 0.")
+	}
+
+	breaker := distancehelper.NewCircuitBreaker(cfg.Maps.BreakerFailures, cfg.Maps.BreakerCooldown)
+	dep := &rh.Dependencies{DB: DB, Map: &distancehelper.GMapReal{}, Dao: &dao.GormDB{},
+		MapHelper: &distancehelper.GMapHelper{Breaker: breaker, APIKey: cfg.Maps.APIKey},
+		Geocoder:  geo, ReverseGeocoder: reverse, Pricing: prices, IdempotencyWindow: cfg.Orders.IdempotencyWindow,
+		CourierLocationHistory: cfg.Orders.CourierLocationHistory,
+		Events:                 eventbus.New(cfg.Orders.StreamHistory),
+		Offers:                 rh.NewOffers(cfg.Offers.Radius, cfg.Offers.Timeout),
+		RouteRules: rh.RouteRules{MinDistanceMeters: cfg.Routes.MinDistance, MaxDistanceMeters: cfg.Routes.MaxDistance,
+			MaxDuration: cfg.Routes.MaxDuration, RejectIdenticalPoints: cfg.Routes.RejectIdenticalPoints}}
+	// stops the background jobs, waiting for them to finish before the DB is closed
+	jobs, stopJobs := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		stopJobs()
+		wg.Wait()
+	}()
+	// the distance lookups and new DB connections use the secrets read from files as they change
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		secret.Watch(jobs, cfg.Secrets.Refresh, cfg.Database.Password, cfg.Maps.APIKey)
+	}()
+	if err := setupZones(jobs, &wg, dep, cfg.Zones); err != nil {
+		return err
+	}
+	if err := setupDispatch(jobs, &wg, dep, cfg.Dispatch); err != nil {
+		return err
+	}
+	if err := setupWebhooks(jobs, &wg, dep, cfg.Webhooks); err != nil {
+		return err
+	}
+	if err := setupOutbox(jobs, &wg, dep, cfg.Outbox); err != nil {
+		return err
+	}
+	metrics.RegisterOrderCounter(func() (map[string]int, error) { return dep.Dao.CountOrdersByStatus(DB) })
+
+	authn, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return err
+	}
+
+	limits, err := ratelimit.ParseConfig(cfg.HTTP.RateLimits)
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMITS: %v", err)
+	}
+
+	validator, err := openapi.NewValidator(cfg.OpenAPI.ValidateRequests, cfg.OpenAPI.ValidateResponses)
+	if err != nil {
+		return err
+	}
+	if validator.Responses {
+		log.Warn("Responses are validated against the OpenAPI document, for tests and staging only.")
+	}
 
 	// setup routes
 	router := httprouter.New()
-	router.POST("/orders", dep.HandleNewOrder)
-	router.PATCH("/orders/:id", dep.HandleTakeOrder)
-	router.GET("/orders", dep.HandleListOrder)
+	(&routes{router: router, authn: authn, limits: limits, validator: validator}).register(dep)
 
 	// start server
-	log.Println("Starting server")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	handler := logging.Middleware(router)
+	srv := newServer(handler, cfg.HTTP)
+	// the gRPC API calls the same routes, stopped after the HTTP server has ended the order streams
+	if cfg.GRPC.ListenAddr != config.GRPCOff {
+		stopGRPC, err := serveGRPC(grpcapi.NewServer(handler), cfg.GRPC.ListenAddr, cfg.HTTP.ShutdownTimeout)
+		if err != nil {
+			return err
+		}
+		defer stopGRPC()
+	}
+	// ends the order streams and offer connections, the streams would hold up the shutdown otherwise and the
+	// hijacked offer connections are not closed by it
+	srv.RegisterOnShutdown(dep.Events.Close)
+	srv.RegisterOnShutdown(dep.Offers.Close)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	return serve(srv, cfg.HTTP.ShutdownTimeout, stop)
+}
+
+type routes struct {
+	router *httprouter.Router
+	authn  *auth.Authenticator
+	limits ratelimit.Config
+	// checks the requests against openapi.json, nil for none
+	validator *openapi.Validator
+	// "<method> <path>" of the routes registered
+	patterns []string
+}
+
+// register adds the routes of dep, each of them described in openapi.json
+func (rs *routes) register(dep *rh.Dependencies) {
+	rs.handle("POST", "/orders", dep.WithIdempotency(dep.HandleNewOrder), auth.RoleCustomer, auth.RoleDispatcher)
+	rs.handle("POST", "/quotes", dep.HandleQuote, auth.RoleCustomer, auth.RoleDispatcher)
+	rs.handle("PATCH", "/orders/:id", dep.HandleTakeOrder, auth.RoleCourier)
+	rs.handle("GET", "/orders", dep.HandleListOrder, auth.RoleDispatcher)
+	// httprouter cannot have /orders/stream next to /orders/:id, so the stream is served as the order with id stream
+	stream := rs.wrap("GET", "/orders/stream", dep.HandleOrderStream, auth.RoleDispatcher)
+	get := rs.wrap("GET", "/orders/:id", dep.HandleGetOrder, auth.RoleCustomer, auth.RoleCourier, auth.RoleDispatcher)
+	rs.router.GET("/orders/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if ps.ByName("id") == "stream" {
+			stream(w, r, ps)
+		} else {
+			get(w, r, ps)
+		}
+	})
+	rs.handle("POST", "/couriers/:id/location", dep.HandleCourierLocation, auth.RoleCourier)
+	rs.handle("GET", "/couriers/offers", dep.HandleCourierOffers, auth.RoleCourier)
+	rs.handle("GET", "/admin/couriers", dep.HandleListCouriers, auth.RoleAdmin)
+	rs.handle("PUT", "/admin/couriers/:id", dep.HandleSaveCourier, auth.RoleAdmin)
+	rs.handle("GET", "/admin/zones", dep.HandleListZones, auth.RoleAdmin)
+	rs.handle("PUT", "/admin/zones", dep.HandleReplaceZones, auth.RoleAdmin)
+	rs.handle("GET", "/admin/webhooks", dep.HandleListWebhooks, auth.RoleAdmin)
+	rs.handle("POST", "/admin/webhooks", dep.HandleCreateWebhook, auth.RoleAdmin)
+	rs.handle("DELETE", "/admin/webhooks/:id", dep.HandleDeleteWebhook, auth.RoleAdmin)
+	rs.handle("GET", "/admin/webhooks/:id/deliveries", dep.HandleListWebhookDeliveries, auth.RoleAdmin)
+	rs.handle("POST", "/admin/webhooks/:id/deliveries/:delivery/retry", dep.HandleRetryWebhookDelivery, auth.RoleAdmin)
+	rs.handle("GET", "/healthz", dep.HandleHealth)
+	rs.handle("GET", "/readyz", dep.HandleReady)
+	rs.handle("GET", "/openapi.json", openapi.Handle)
+	rs.router.Handler("GET", "/metrics", metrics.Handler())
+	rs.patterns = append(rs.patterns, "GET /metrics")
+}
+
+// handle registers h with the per route instrumentation, rate limit and request validation, only letting callers
+// with one of roles through. Without roles the route is public.
+func (rs *routes) handle(method string, path string, h httprouter.Handle, roles ...string) {
+	rs.router.Handle(method, path, rs.wrap(method, path, h, roles...))
+}
+
+// wrap returns h with the middlewares of handle, for a route registered by the caller
+func (rs *routes) wrap(method string, path string, h httprouter.Handle, roles ...string) httprouter.Handle {
+	// validated after authentication and rate limiting, so callers without access learn nothing about the API
+	h = rs.validator.Middleware(h)
+	// limited per principal, so it runs after authentication
+	h = ratelimit.New(rs.limits.For(method, path)).Middleware(h)
+	if len(roles) > 0 {
+		h = rs.authn.Require(h, roles...)
+	}
+	rs.patterns = append(rs.patterns, method+" "+path)
+	return tracing.Instrument(path, metrics.Instrument(path, h))
 }
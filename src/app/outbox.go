@@ -0,0 +1,60 @@
+package main
+
+import (
+	"config"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"outbox"
+	rh "requesthandler"
+	"sync"
+	"time"
+)
+
+const outboxBatch = 100
+
+// setupOutbox sets the sinks of cfg and relays the outbox to them every interval until ctx is done
+func setupOutbox(ctx context.Context, wg *sync.WaitGroup, dep *rh.Dependencies, cfg config.Outbox) error {
+	for _, name := range cfg.Sinks {
+		var sink outbox.Sink
+		switch name {
+		case outbox.SinkWebhook:
+			sink = dep.WebhookSink()
+		case outbox.SinkLog:
+			sink = outbox.Log{}
+		case outbox.SinkNATS:
+			sink = outbox.NewNATS(cfg.NATSURL.Get(), cfg.NATSSubjectPrefix, cfg.PublishTimeout)
+		case outbox.SinkKafka:
+			sink = outbox.NewKafkaREST(cfg.KafkaRESTURL, cfg.KafkaTopic, cfg.PublishTimeout)
+		default:
+			return fmt.Errorf("unknown outbox sink %q", name)
+		}
+		dep.EventSinks = append(dep.EventSinks, outbox.Named{Name: name, Sink: sink})
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(cfg.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				// keep going while there is a backlog
+				for {
+					n, err := dep.RelayOutbox(ctx, outboxBatch)
+					if err != nil {
+						log.Errorf("Cannot relay outbox: %v", err)
+						break
+					}
+					if n < outboxBatch || ctx.Err() != nil {
+						break
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
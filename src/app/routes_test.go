@@ -0,0 +1,104 @@
+package main
+
+import (
+	"auth"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"openapi"
+	"ratelimit"
+	"regexp"
+	rh "requesthandler"
+	"responseutil"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func TestRoutesDocumented(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+pathParam.ReplaceAllString(path, ":$1")] = true
+		}
+	}
+	rs := &routes{router: httprouter.New(), authn: &auth.Authenticator{}, limits: ratelimit.Config{}}
+
+	rs.register(&rh.Dependencies{})
+
+	for _, p := range rs.patterns {
+		if !documented[p] {
+			t.Errorf("%s is not in openapi.json", p)
+		}
+		delete(documented, p)
+	}
+	var extra []string
+	for p := range documented {
+		extra = append(extra, p)
+	}
+	sort.Strings(extra)
+	if len(extra) > 0 {
+		t.Errorf("openapi.json describes routes which are not served: %v", extra)
+	}
+}
+
+func TestRoutesValidateRequests(t *testing.T) {
+	v, err := openapi.NewValidator(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := httprouter.New()
+	(&routes{router: router, authn: &auth.Authenticator{}, limits: ratelimit.Config{}, validator: v}).register(&rh.Dependencies{})
+	body := `{"origin": ["22.3", "114.1"], "destination": ["22.4", "114.2"], "weight": 2}`
+
+	// authentication comes first
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/orders", strings.NewReader(body)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 before validation, got %d", w.Code)
+	}
+
+	router = httprouter.New()
+	(&routes{router: router, authn: &auth.Authenticator{Disabled: true}, limits: ratelimit.Config{}, validator: v}).register(&rh.Dependencies{})
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+
+	router.ServeHTTP(w, r)
+
+	var p responseutil.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || p.Code != responseutil.CodeValidationFailed || len(p.Errors) != 1 || p.Errors[0].Field != "weight" {
+		t.Errorf("Expected weight to be rejected, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRoutesOrderStream(t *testing.T) {
+	v, err := openapi.NewValidator(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := httprouter.New()
+	(&routes{router: router, authn: &auth.Authenticator{Disabled: true}, limits: ratelimit.Config{}, validator: v}).register(&rh.Dependencies{})
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/orders/stream?status=DONE", nil))
+
+	// rejected by the stream, not validated or handled as an order id
+	var p responseutil.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || p.Code != responseutil.CodeInvalidQuery {
+		t.Errorf("Expected the stream to reject the status, got %d %s", w.Code, w.Body)
+	}
+}
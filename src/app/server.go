@@ -0,0 +1,48 @@
+package main
+
+import (
+	"config"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"os"
+	"time"
+)
+
+func newServer(handler http.Handler, cfg config.HTTP) *http.Server {
+	return &http.Server{
+		Addr:           cfg.ListenAddr,
+		Handler:        handler,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+}
+
+// serve blocks until a signal is received on stop, then waits up to shutdownTimeout for in-flight requests to finish
+func serve(srv *http.Server, shutdownTimeout time.Duration, stop <-chan os.Signal) error {
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			errs <- err
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("server error: %v", err)
+	case sig := <-stop:
+		log.Printf("Received %v, shutting down server...", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("server shutdown error: %v", err)
+	}
+	log.Println("Server stopped")
+	return nil
+}
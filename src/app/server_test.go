@@ -0,0 +1,116 @@
+package main
+
+import (
+	"config"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	cfg := config.Default().HTTP
+	cfg.ListenAddr = ":9090"
+	cfg.ReadTimeout = 5 * time.Second
+	cfg.MaxHeaderBytes = 4096
+
+	srv := newServer(http.NotFoundHandler(), cfg)
+
+	if srv.Addr != ":9090" || srv.ReadTimeout != 5*time.Second || srv.MaxHeaderBytes != 4096 {
+		t.Errorf("Expected server settings from config, got %s %v %d", srv.Addr, srv.ReadTimeout, srv.MaxHeaderBytes)
+	}
+	if srv.WriteTimeout != cfg.WriteTimeout || srv.IdleTimeout != cfg.IdleTimeout {
+		t.Errorf("Expected default timeouts, got %v %v", srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestServeWaitsForInFlightRequests(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Addr: freeAddr(t), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	shutdownHooks := make(chan struct{})
+	srv.RegisterOnShutdown(func() { close(shutdownHooks) })
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() { served <- serve(srv, 5*time.Second, stop) }()
+
+	responses := make(chan int, 1)
+	go func() {
+		for {
+			resp, err := http.Get("http://" + srv.Addr)
+			if err == nil {
+				resp.Body.Close()
+				responses <- resp.StatusCode
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	<-entered
+	stop <- syscall.SIGTERM
+	<-shutdownHooks
+
+	select {
+	case err := <-served:
+		t.Fatalf("Expected serve to wait for the request, returned %v", err)
+	default:
+	}
+	close(release)
+
+	if code := <-responses; code != http.StatusNoContent {
+		t.Errorf("Expected in-flight request to complete, got %d", code)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Addr: freeAddr(t), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() { served <- serve(srv, 10*time.Millisecond, stop) }()
+	go func() {
+		for {
+			if resp, err := http.Get("http://" + srv.Addr); err == nil {
+				resp.Body.Close()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	<-entered
+	stop <- syscall.SIGTERM
+
+	if err := <-served; err == nil || !strings.Contains(err.Error(), "shutdown") {
+		t.Errorf("Expected shutdown timeout error, got %v", err)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	srv := &http.Server{Addr: "127.0.0.1:-1"}
+
+	if err := serve(srv, time.Second, make(chan os.Signal)); err == nil {
+		t.Errorf("Expected listen error")
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
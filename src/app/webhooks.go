@@ -0,0 +1,45 @@
+package main
+
+import (
+	"config"
+	"context"
+	log "github.com/sirupsen/logrus"
+	rh "requesthandler"
+	"sync"
+	"time"
+	"webhook"
+)
+
+const webhookBatch = 100
+
+// setupWebhooks sets the webhook settings of dep and delivers due webhooks every interval until ctx is done
+func setupWebhooks(ctx context.Context, wg *sync.WaitGroup, dep *rh.Dependencies, cfg config.Webhooks) error {
+	dep.Webhooks = rh.WebhookConfig{Sender: webhook.NewSender(cfg.Timeout, cfg.AllowPrivateTargets),
+		MaxAttempts: cfg.MaxAttempts, Backoff: webhook.Backoff{Base: cfg.Backoff, Max: cfg.MaxBackoff}}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(cfg.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				// keep going while there is a backlog
+				for {
+					n, err := dep.DeliverWebhooks(ctx, webhookBatch)
+					if err != nil {
+						log.Errorf("Cannot deliver webhooks: %v", err)
+						break
+					}
+					if n < webhookBatch || ctx.Err() != nil {
+						break
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"config"
+	"context"
+	"fmt"
+	"geofence"
+	log "github.com/sirupsen/logrus"
+	rh "requesthandler"
+	"sync"
+	"time"
+)
+
+// setupZones loads the service areas into dep, seeding the database from the zones file once: after the first
+// seed or a replacement through PUT /admin/zones the database is authoritative, even when it has no areas.
+// Afterwards the zones are reloaded every refresh until ctx is done, to pick up replacements made through other
+// instances.
+func setupZones(ctx context.Context, wg *sync.WaitGroup, dep *rh.Dependencies, cfg config.Zones) error {
+	dep.Zones = &geofence.Zones{}
+	if path := cfg.File; path != "" {
+		if err := seedZones(ctx, dep, path); err != nil {
+			return fmt.Errorf("invalid SERVICE_AREAS_FILE: %v", err)
+		}
+	}
+	if err := dep.ReloadZones(ctx); err != nil {
+		return fmt.Errorf("cannot load service areas: %v", err)
+	}
+	if len(dep.Zones.List()) == 0 {
+		log.Warn("No service areas are set, orders are accepted anywhere.")
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(cfg.Refresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := dep.ReloadZones(ctx); err != nil {
+					log.Errorf("Cannot reload service areas: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func seedZones(ctx context.Context, dep *rh.Dependencies, path string) error {
+	zones, err := geofence.Load(path)
+	if err != nil {
+		return err
+	}
+	seeded, err := dep.SeedZones(ctx, zones)
+	if err != nil {
+		return err
+	}
+	if seeded {
+		log.Printf("Seeded %d service areas from %s", len(zones), path)
+	} else {
+		log.Infof("Service areas were seeded before, SERVICE_AREAS_FILE %s is ignored.", path)
+	}
+	return nil
+}
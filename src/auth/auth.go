@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"responseutil"
+	"strings"
+)
+
+const (
+	RoleCustomer   = "customer"
+	RoleCourier    = "courier"
+	RoleDispatcher = "dispatcher"
+	RoleAdmin      = "admin"
+
+	APIKeyHeader = "X-API-Key"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated caller
+type Principal struct {
+	ID    string
+	Roles []string
+}
+
+// HasAnyRole returns whether the principal has one of roles, admins have every role
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	for _, have := range p.Roles {
+		if have == RoleAdmin {
+			return true
+		}
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticator accepts static API keys in the X-API-Key header, and HS256 or RS256 signed JWTs as bearer tokens.
+// JWTs carry the principal id in "sub" and its roles in "roles". A method is only enabled when configured.
+type Authenticator struct {
+	APIKeys      map[string]*Principal
+	HMACSecret   []byte
+	RSAPublicKey *rsa.PublicKey
+	// Disabled lets every request through as admin, for local development only
+	Disabled bool
+}
+
+type claims struct {
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+var anonymousAdmin = &Principal{ID: "anonymous", Roles: []string{RoleAdmin}}
+
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if a.Disabled {
+		return anonymousAdmin, nil
+	}
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+	if h := r.Header.Get("Authorization"); h != "" {
+		if !strings.HasPrefix(h, "Bearer ") {
+			return nil, ErrInvalidCredentials
+		}
+		return a.authenticateJWT(strings.TrimPrefix(h, "Bearer "))
+	}
+	return nil, ErrNoCredentials
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (*Principal, error) {
+	// compare against every key, so the time taken does not tell which key was close
+	var found *Principal
+	for k, p := range a.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return found, nil
+}
+
+func (a *Authenticator) authenticateJWT(token string) (*Principal, error) {
+	var methods []string
+	if len(a.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.RSAPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() == jwt.SigningMethodRS256.Alg() {
+			return a.RSAPublicKey, nil
+		}
+		return a.HMACSecret, nil
+	}, jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrInvalidCredentials, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%v: missing subject", ErrInvalidCredentials)
+	}
+	return &Principal{ID: c.Subject, Roles: c.Roles}, nil
+}
+
+// Require only calls h for callers having one of roles, with the principal available through FromContext
+func (a *Authenticator) Require(h httprouter.Handle, roles ...string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="llmc"`)
+			responseutil.WriteError(w, r, http.StatusUnauthorized, responseutil.CodeUnauthorized, "Missing or invalid credentials")
+			return
+		}
+		if !p.HasAnyRole(roles...) {
+			responseutil.WriteError(w, r, http.StatusForbidden, responseutil.CodeForbidden, fmt.Sprintf("Requires role %s", strings.Join(roles, " or ")))
+			return
+		}
+		h(w, r.WithContext(WithPrincipal(r.Context(), p)), ps)
+	}
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the authenticated principal, or nil outside of Require
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKey{}).(*Principal)
+	return p
+}
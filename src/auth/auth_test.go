@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var hmacSecret = []byte("secret")
+
+func TestAPIKey(t *testing.T) {
+	a := &Authenticator{APIKeys: map[string]*Principal{"k1": {ID: "courier-1", Roles: []string{RoleCourier}}}}
+
+	p, err := a.Authenticate(requestWithHeader(APIKeyHeader, "k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "courier-1", p.ID)
+
+	_, err = a.Authenticate(requestWithHeader(APIKeyHeader, "k2"))
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestNoCredentials(t *testing.T) {
+	a := &Authenticator{HMACSecret: hmacSecret}
+
+	_, err := a.Authenticate(requestWithHeader("", ""))
+
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestHS256(t *testing.T) {
+	a := &Authenticator{HMACSecret: hmacSecret}
+	token := sign(t, jwt.SigningMethodHS256, hmacSecret, "dispatcher-1", time.Hour)
+
+	p, err := a.Authenticate(requestWithHeader("Authorization", "Bearer "+token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "dispatcher-1", p.ID)
+	assert.True(t, p.HasAnyRole(RoleDispatcher))
+}
+
+func TestHS256Expired(t *testing.T) {
+	a := &Authenticator{HMACSecret: hmacSecret}
+	token := sign(t, jwt.SigningMethodHS256, hmacSecret, "dispatcher-1", -time.Minute)
+
+	_, err := a.Authenticate(requestWithHeader("Authorization", "Bearer "+token))
+
+	assert.NotNil(t, err)
+}
+
+func TestHS256WrongSecret(t *testing.T) {
+	a := &Authenticator{HMACSecret: hmacSecret}
+	token := sign(t, jwt.SigningMethodHS256, []byte("other"), "dispatcher-1", time.Hour)
+
+	_, err := a.Authenticate(requestWithHeader("Authorization", "Bearer "+token))
+
+	assert.NotNil(t, err)
+}
+
+func TestRS256(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a := &Authenticator{RSAPublicKey: &key.PublicKey}
+	token := sign(t, jwt.SigningMethodRS256, key, "courier-1", time.Hour)
+
+	p, err := a.Authenticate(requestWithHeader("Authorization", "Bearer "+token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "courier-1", p.ID)
+}
+
+func TestHS256RejectedWhenOnlyRS256Configured(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a := &Authenticator{RSAPublicKey: &key.PublicKey}
+	// signing with the public key bytes as HMAC secret is the classic algorithm confusion attack
+	token := sign(t, jwt.SigningMethodHS256, key.PublicKey.N.Bytes(), "admin-1", time.Hour)
+
+	_, err := a.Authenticate(requestWithHeader("Authorization", "Bearer "+token))
+
+	assert.NotNil(t, err)
+}
+
+func TestRequire(t *testing.T) {
+	a := &Authenticator{APIKeys: map[string]*Principal{
+		"courier":  {ID: "courier-1", Roles: []string{RoleCourier}},
+		"customer": {ID: "customer-1", Roles: []string{RoleCustomer}},
+		"admin":    {ID: "admin-1", Roles: []string{RoleAdmin}},
+	}}
+	var seen *Principal
+	h := a.Require(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		seen = FromContext(r.Context())
+	}, RoleCourier)
+
+	r := map[string]int{
+		"":         http.StatusUnauthorized,
+		"unknown":  http.StatusUnauthorized,
+		"customer": http.StatusForbidden,
+		"courier":  http.StatusOK,
+		"admin":    http.StatusOK,
+	}
+	for k, v := range r {
+		seen = nil
+		w := httptest.NewRecorder()
+		h(w, requestWithHeader(APIKeyHeader, k), nil)
+		assert.Equal(t, v, w.Code, "key %s", k)
+		if v == http.StatusOK {
+			assert.Equal(t, k+"-1", seen.ID)
+		} else {
+			assert.Nil(t, seen)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	a := &Authenticator{Disabled: true}
+
+	p, err := a.Authenticate(requestWithHeader("", ""))
+
+	assert.Nil(t, err)
+	assert.True(t, p.HasAnyRole(RoleDispatcher))
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys("k1:courier-1:courier, k2:ops:dispatcher|admin,")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(keys))
+	assert.Equal(t, []string{RoleDispatcher, RoleAdmin}, keys["k2"].Roles)
+
+	_, err = ParseAPIKeys("k1:courier-1")
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "k1")
+}
+
+func requestWithHeader(name string, value string) *http.Request {
+	r, _ := http.NewRequest("GET", "/orders", nil)
+	if name != "" && value != "" {
+		r.Header.Set(name, value)
+	}
+	return r
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, subject string, ttl time.Duration) string {
+	c := &claims{
+		Roles: []string{subjectRole(subject)},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+	s, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func subjectRole(subject string) string {
+	switch subject {
+	case "courier-1":
+		return RoleCourier
+	case "admin-1":
+		return RoleAdmin
+	}
+	return RoleDispatcher
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io/ioutil"
+	"strings"
+)
+
+// ParseAPIKeys parses comma separated "<key>:<principal id>:<role>[|<role>...]" entries.
+// Errors do not contain the keys, so they can be logged.
+func ParseAPIKeys(s string) (map[string]*Principal, error) {
+	keys := make(map[string]*Principal)
+	for i, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid API key entry %d, expected <key>:<id>:<roles>", i+1)
+		}
+		keys[parts[0]] = &Principal{ID: parts[1], Roles: strings.Split(parts[2], "|")}
+	}
+	return keys, nil
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key used to verify RS256 tokens
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPublicKeyFromPEM(b)
+}
@@ -0,0 +1,313 @@
+// Package config holds the settings of the service. They are loaded from the defaults, an optional YAML or TOML
+// file, the environment and command line flags, each source overriding the ones before, and validated once at
+// startup.
+package config
+
+import (
+	"fmt"
+	"geocoder"
+	"net/http"
+	"outbox"
+	"ratelimit"
+	rh "requesthandler"
+	"secret"
+	"strings"
+	"time"
+	"tracing"
+)
+
+const (
+	DispatchOff      = "off"
+	DispatchCreate   = "create"
+	DispatchPeriodic = "periodic"
+
+	// GRPCOff as gRPC listen address disables the gRPC server
+	GRPCOff = "off"
+
+	// the distance lookup of POST /orders and /quotes costs Google quota
+	defaultRateLimits = "*=20/s:40,POST /orders=2/s:10,POST /quotes=2/s:10"
+)
+
+// Config is the settings of the service. Every setting has a key in the file, "<section>.<key>", an env var and a
+// flag, the env var name in lower case with dashes, e.g. http.readTimeout, HTTP_READ_TIMEOUT and --http-read-timeout.
+// Secrets can also be read from a file, named by the key with a File suffix, the env var with a _FILE suffix or the
+// flag with a -file suffix, e.g. DB_PASSWORD_FILE. Secrets are redacted when printed.
+type Config struct {
+	HTTP     HTTP     `key:"http"`
+	GRPC     GRPC     `key:"grpc"`
+	Database Database `key:"database"`
+	Maps     Maps     `key:"maps"`
+	Geocoder Geocoder `key:"geocoder"`
+	Pricing  Pricing  `key:"pricing"`
+	Auth     Auth     `key:"auth"`
+	Orders   Orders   `key:"orders"`
+	Routes   Routes   `key:"routes"`
+	Zones    Zones    `key:"zones"`
+	Dispatch Dispatch `key:"dispatch"`
+	Offers   Offers   `key:"offers"`
+	Outbox   Outbox   `key:"outbox"`
+	Webhooks Webhooks `key:"webhooks"`
+	Tracing  Tracing  `key:"tracing"`
+	OpenAPI  OpenAPI  `key:"openapi"`
+	Secrets  Secrets  `key:"secrets"`
+}
+
+type HTTP struct {
+	ListenAddr     string        `key:"listenAddr" env:"LISTEN_ADDR"`
+	ReadTimeout    time.Duration `key:"readTimeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout   time.Duration `key:"writeTimeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout    time.Duration `key:"idleTimeout" env:"HTTP_IDLE_TIMEOUT"`
+	MaxHeaderBytes int           `key:"maxHeaderBytes" env:"HTTP_MAX_HEADER_BYTES"`
+	// how long in-flight requests are drained on shutdown
+	ShutdownTimeout time.Duration `key:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+	// requests allowed per client, see ratelimit.ParseConfig
+	RateLimits string `key:"rateLimits" env:"RATE_LIMITS"`
+}
+
+type GRPC struct {
+	// GRPCOff disables the gRPC server
+	ListenAddr string `key:"listenAddr" env:"GRPC_LISTEN_ADDR"`
+}
+
+type Database struct {
+	Host string `key:"host" env:"DB_HOST"`
+	Port int    `key:"port" env:"DB_PORT"`
+	User string `key:"user" env:"DB_USER"`
+	Name string `key:"name" env:"DB_NAME"`
+	// reloaded, new connections use the current password
+	Password *secret.Value `key:"password" env:"DB_PASSWORD"`
+	SSLMode  string        `key:"sslMode" env:"DB_SSLMODE"`
+}
+
+type Maps struct {
+	// Google API key, distances are 0 without it. Reloaded for the distance lookups, the geocoder keeps the key it
+	// was started with.
+	APIKey *secret.Value `key:"apiKey" env:"GOOGLE_MAP_API_KEY"`
+	// consecutive failures after which calls are suspended, and for how long
+	BreakerFailures int           `key:"breakerFailures" env:"DISTANCE_BREAKER_FAILURES"`
+	BreakerCooldown time.Duration `key:"breakerCooldown" env:"DISTANCE_BREAKER_COOLDOWN"`
+}
+
+type Geocoder struct {
+	// one of the geocoder providers
+	Provider        string `key:"provider" env:"GEOCODER"`
+	ReverseProvider string `key:"reverseProvider" env:"REVERSE_GEOCODER"`
+	NominatimURL    string `key:"nominatimUrl" env:"GEOCODER_NOMINATIM_URL"`
+	UserAgent       string `key:"userAgent" env:"GEOCODER_USER_AGENT"`
+	StubFile        string `key:"stubFile" env:"GEOCODER_STUB_FILE"`
+}
+
+type Pricing struct {
+	// orders are not priced without rules
+	RulesFile string `key:"rulesFile" env:"PRICING_RULES_FILE"`
+}
+
+type Auth struct {
+	// lets every request through, for local development only
+	Disabled bool `key:"disabled" env:"AUTH_DISABLED"`
+	// see auth.ParseAPIKeys
+	APIKeys               *secret.Value `key:"apiKeys" env:"AUTH_API_KEYS"`
+	JWTHS256Secret        *secret.Value `key:"jwtHs256Secret" env:"AUTH_JWT_HS256_SECRET"`
+	JWTRS256PublicKeyFile string        `key:"jwtRs256PublicKeyFile" env:"AUTH_JWT_RS256_PUBLIC_KEY_FILE"`
+}
+
+type Orders struct {
+	IdempotencyWindow      time.Duration `key:"idempotencyWindow" env:"IDEMPOTENCY_WINDOW"`
+	CourierLocationHistory int           `key:"courierLocationHistory" env:"COURIER_LOCATION_HISTORY"`
+	StreamHistory          int           `key:"streamHistory" env:"ORDER_STREAM_HISTORY"`
+}
+
+type Routes struct {
+	// 0 for no limit
+	MinDistance           int           `key:"minDistance" env:"ROUTE_MIN_DISTANCE"`
+	MaxDistance           int           `key:"maxDistance" env:"ROUTE_MAX_DISTANCE"`
+	MaxDuration           time.Duration `key:"maxDuration" env:"ROUTE_MAX_DURATION"`
+	RejectIdenticalPoints bool          `key:"rejectIdenticalPoints" env:"ROUTE_REJECT_IDENTICAL_POINTS"`
+}
+
+type Zones struct {
+	// seeds the database once
+	File    string        `key:"file" env:"SERVICE_AREAS_FILE"`
+	Refresh time.Duration `key:"refresh" env:"SERVICE_AREAS_REFRESH"`
+}
+
+type Dispatch struct {
+	// DispatchOff, or DispatchCreate and/or DispatchPeriodic
+	Mode           []string      `key:"mode" env:"DISPATCH_MODE"`
+	Interval       time.Duration `key:"interval" env:"DISPATCH_INTERVAL"`
+	QueueSize      int           `key:"queueSize" env:"DISPATCH_QUEUE_SIZE"`
+	Candidates     int           `key:"candidates" env:"DISPATCH_CANDIDATES"`
+	MaxLocationAge time.Duration `key:"maxLocationAge" env:"DISPATCH_MAX_LOCATION_AGE"`
+}
+
+type Offers struct {
+	// meters around the origin
+	Radius  int           `key:"radius" env:"OFFER_RADIUS"`
+	Timeout time.Duration `key:"timeout" env:"OFFER_TIMEOUT"`
+}
+
+type Outbox struct {
+	// outbox sink names
+	Sinks          []string      `key:"sinks" env:"OUTBOX_SINKS"`
+	Interval       time.Duration `key:"interval" env:"OUTBOX_INTERVAL"`
+	PublishTimeout time.Duration `key:"publishTimeout" env:"OUTBOX_PUBLISH_TIMEOUT"`
+	// may hold credentials
+	NATSURL           *secret.Value `key:"natsUrl" env:"NATS_URL"`
+	NATSSubjectPrefix string        `key:"natsSubjectPrefix" env:"NATS_SUBJECT_PREFIX"`
+	KafkaRESTURL      string        `key:"kafkaRestUrl" env:"KAFKA_REST_URL"`
+	KafkaTopic        string        `key:"kafkaTopic" env:"KAFKA_TOPIC"`
+}
+
+type Webhooks struct {
+	Interval            time.Duration `key:"interval" env:"WEBHOOK_INTERVAL"`
+	Timeout             time.Duration `key:"timeout" env:"WEBHOOK_TIMEOUT"`
+	AllowPrivateTargets bool          `key:"allowPrivateTargets" env:"WEBHOOK_ALLOW_PRIVATE_TARGETS"`
+	MaxAttempts         int           `key:"maxAttempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	Backoff             time.Duration `key:"backoff" env:"WEBHOOK_BACKOFF"`
+	MaxBackoff          time.Duration `key:"maxBackoff" env:"WEBHOOK_MAX_BACKOFF"`
+}
+
+type Tracing struct {
+	// one of the tracing exporters, the otlp exporter is configured through the OTEL_EXPORTER_OTLP_* env vars
+	Exporter string `key:"exporter" env:"OTEL_TRACES_EXPORTER"`
+}
+
+type OpenAPI struct {
+	ValidateRequests bool `key:"validateRequests" env:"OPENAPI_VALIDATE_REQUESTS"`
+	// buffers every response, for tests and staging
+	ValidateResponses bool `key:"validateResponses" env:"OPENAPI_VALIDATE_RESPONSES"`
+}
+
+type Secrets struct {
+	// how often the secret files of the database password and the Google API key are checked for changes
+	Refresh time.Duration `key:"refresh" env:"SECRETS_REFRESH"`
+}
+
+// Default returns the settings used when no source sets them
+func Default() *Config {
+	return &Config{
+		HTTP: HTTP{ListenAddr: ":8080", ReadTimeout: 10 * time.Second, WriteTimeout: 30 * time.Second,
+			IdleTimeout: 120 * time.Second, MaxHeaderBytes: http.DefaultMaxHeaderBytes, ShutdownTimeout: 30 * time.Second,
+			RateLimits: defaultRateLimits},
+		GRPC:     GRPC{ListenAddr: ":9090"},
+		Database: Database{Host: "db", Port: 5432, User: "postgres", Name: "postgres", Password: secret.New("password"), SSLMode: "disable"},
+		Maps:     Maps{APIKey: secret.New(""), BreakerFailures: 5, BreakerCooldown: 30 * time.Second},
+		Geocoder: Geocoder{Provider: geocoder.ProviderNone, ReverseProvider: geocoder.ProviderNone,
+			NominatimURL: geocoder.DefaultNominatimURL, UserAgent: "llmc"},
+		Auth: Auth{APIKeys: secret.New(""), JWTHS256Secret: secret.New("")},
+		Orders: Orders{IdempotencyWindow: rh.DefaultIdempotencyWindow, CourierLocationHistory: rh.DefaultCourierLocationHistory,
+			StreamHistory: rh.DefaultStreamHistory},
+		Routes: Routes{RejectIdenticalPoints: true},
+		Zones:  Zones{Refresh: time.Minute},
+		Dispatch: Dispatch{Mode: []string{DispatchOff}, Interval: 30 * time.Second, QueueSize: rh.DefaultDispatchQueueSize,
+			Candidates: rh.DefaultDispatchCandidates, MaxLocationAge: rh.DefaultDispatchMaxLocationAge},
+		Offers: Offers{Radius: rh.DefaultOfferRadius, Timeout: rh.DefaultOfferTimeout},
+		Outbox: Outbox{Sinks: []string{outbox.SinkWebhook}, Interval: time.Second, PublishTimeout: 10 * time.Second,
+			NATSURL: secret.New(""), NATSSubjectPrefix: "llmc.", KafkaTopic: "llmc.orders"},
+		Webhooks: Webhooks{Interval: 2 * time.Second, Timeout: 10 * time.Second, MaxAttempts: rh.DefaultWebhookMaxAttempts,
+			Backoff: rh.DefaultWebhookBackoff, MaxBackoff: rh.DefaultWebhookMaxBackoff},
+		Tracing: Tracing{Exporter: tracing.ExporterNone},
+		OpenAPI: OpenAPI{ValidateRequests: true},
+		Secrets: Secrets{Refresh: 30 * time.Second},
+	}
+}
+
+// Validate returns an error listing the settings which are invalid together
+func (c *Config) Validate() error {
+	var errs []string
+	invalid := func(key string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf("%s (%s): %s", key, envNames[key], fmt.Sprintf(format, args...)))
+	}
+
+	// ticker intervals
+	for _, i := range []struct {
+		key string
+		d   time.Duration
+	}{{"zones.refresh", c.Zones.Refresh}, {"dispatch.interval", c.Dispatch.Interval}, {"offers.timeout", c.Offers.Timeout},
+		{"outbox.interval", c.Outbox.Interval}, {"webhooks.interval", c.Webhooks.Interval}, {"webhooks.timeout", c.Webhooks.Timeout},
+		{"secrets.refresh", c.Secrets.Refresh}} {
+		if i.d <= 0 {
+			invalid(i.key, "must be positive")
+		}
+	}
+	if c.Database.Host == "" {
+		invalid("database.host", "is required")
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		invalid("database.port", "%d is not a port", c.Database.Port)
+	}
+	if _, err := ratelimit.ParseConfig(c.HTTP.RateLimits); err != nil {
+		invalid("http.rateLimits", "%v", err)
+	}
+	for _, p := range []struct{ key, provider string }{{"geocoder.provider", c.Geocoder.Provider},
+		{"geocoder.reverseProvider", c.Geocoder.ReverseProvider}} {
+		switch p.provider {
+		case geocoder.ProviderNone, geocoder.ProviderNominatim:
+		case geocoder.ProviderGoogle:
+			if c.Maps.APIKey.Get() == "" {
+				invalid(p.key, "%s requires maps.apiKey", p.provider)
+			}
+		case geocoder.ProviderStub:
+			if c.Geocoder.StubFile == "" {
+				invalid(p.key, "%s requires geocoder.stubFile", p.provider)
+			}
+		default:
+			invalid(p.key, "unknown provider %q", p.provider)
+		}
+	}
+	for _, mode := range c.Dispatch.Mode {
+		if mode != DispatchOff && mode != DispatchCreate && mode != DispatchPeriodic {
+			invalid("dispatch.mode", "unknown mode %q", mode)
+		}
+	}
+	for _, sink := range c.Outbox.Sinks {
+		switch sink {
+		case outbox.SinkWebhook, outbox.SinkLog:
+		case outbox.SinkNATS:
+			if c.Outbox.NATSURL.Get() == "" {
+				invalid("outbox.natsUrl", "is required for the %s sink", sink)
+			}
+		case outbox.SinkKafka:
+			if c.Outbox.KafkaRESTURL == "" {
+				invalid("outbox.kafkaRestUrl", "is required for the %s sink", sink)
+			}
+		default:
+			invalid("outbox.sinks", "unknown sink %q", sink)
+		}
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		invalid("tracing.exporter", "unknown exporter %q", c.Tracing.Exporter)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+}
+
+// Enabled is whether mode is one of the dispatch modes
+func (d Dispatch) Enabled(mode string) bool {
+	for _, m := range d.Mode {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// DSN is the connection string of the database with the current password, see
+// https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
+func (d Database) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=%s", dsnValue(d.Host), d.Port,
+		dsnValue(d.User), dsnValue(d.Name), dsnValue(d.Password.Get()), dsnValue(d.SSLMode))
+}
+
+// dsnValue quotes values which are empty or have spaces, quotes or backslashes
+func dsnValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, " '\\") {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"secret"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultValid(t *testing.T) {
+	assert.Nil(t, Default().Validate())
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, printConfig, err := Load(nil, env(nil))
+
+	assert.Nil(t, err)
+	assert.False(t, printConfig)
+	assert.Equal(t, Default(), c)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "llmc.yaml", `
+http:
+  listenAddr: ":1"
+  readTimeout: 5s
+database:
+  port: 5433
+dispatch:
+  mode: [create, periodic]
+`)
+
+	c, _, err := Load([]string{"--config", path, "--grpc-listen-addr=off"},
+		env(map[string]string{"LISTEN_ADDR": ":2", "GRPC_LISTEN_ADDR": ":3", "HTTP_WRITE_TIMEOUT": "1m"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ":2", c.HTTP.ListenAddr)
+	assert.Equal(t, 5*time.Second, c.HTTP.ReadTimeout)
+	assert.Equal(t, time.Minute, c.HTTP.WriteTimeout)
+	assert.Equal(t, 5433, c.Database.Port)
+	assert.Equal(t, []string{DispatchCreate, DispatchPeriodic}, c.Dispatch.Mode)
+	assert.Equal(t, GRPCOff, c.GRPC.ListenAddr)
+}
+
+func TestLoadFileFromEnv(t *testing.T) {
+	path := writeFile(t, "llmc.toml", `
+[outbox]
+sinks = ["log", "kafka"]
+kafkaRestUrl = "http://rest-proxy:8082"
+
+[routes]
+rejectIdenticalPoints = false
+maxDistance = 100000
+`)
+
+	c, _, err := Load(nil, env(map[string]string{FileEnv: path}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"log", "kafka"}, c.Outbox.Sinks)
+	assert.Equal(t, "http://rest-proxy:8082", c.Outbox.KafkaRESTURL)
+	assert.False(t, c.Routes.RejectIdenticalPoints)
+	assert.Equal(t, 100000, c.Routes.MaxDistance)
+}
+
+func TestLoadBoolFlags(t *testing.T) {
+	c, printConfig, err := Load([]string{"--auth-disabled", "--print-config", "--openapi-validate-requests=false"}, env(nil))
+
+	assert.Nil(t, err)
+	assert.True(t, printConfig)
+	assert.True(t, c.Auth.Disabled)
+	assert.False(t, c.OpenAPI.ValidateRequests)
+}
+
+func TestLoadInvalidValues(t *testing.T) {
+	_, _, err := Load([]string{"--offer-radius=far"}, env(map[string]string{"HTTP_READ_TIMEOUT": "5", "HTTP_MAX_HEADER_BYTES": "-1",
+		"DB_PASSWORD": "secret"}))
+
+	if assert.NotNil(t, err) {
+		for _, name := range []string{"HTTP_READ_TIMEOUT", "HTTP_MAX_HEADER_BYTES", "--offer-radius"} {
+			assert.Contains(t, err.Error(), name)
+		}
+	}
+}
+
+func TestLoadUnknownFileSetting(t *testing.T) {
+	path := writeFile(t, "llmc.yml", "http:\n  listenAdr: \":1\"\n")
+
+	_, _, err := Load([]string{"--config", path}, env(nil))
+
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unknown setting http.listenAdr")
+	}
+}
+
+func TestLoadUnknownFileFormat(t *testing.T) {
+	path := writeFile(t, "llmc.json", "{}")
+
+	_, _, err := Load([]string{"--config", path}, env(nil))
+
+	assert.NotNil(t, err)
+}
+
+func TestLoadUnknownFlag(t *testing.T) {
+	_, _, err := Load([]string{"--listen-adr", ":1"}, env(nil))
+
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.Outbox.Interval = 0
+	c.Geocoder.Provider = "google"
+	c.Outbox.Sinks = []string{"nats", "redis"}
+	c.Dispatch.Mode = []string{"always"}
+	c.Database.Port = 0
+
+	err := c.Validate()
+
+	if assert.NotNil(t, err) {
+		for _, s := range []string{"outbox.interval (OUTBOX_INTERVAL): must be positive", "geocoder.provider (GEOCODER): google requires maps.apiKey",
+			"outbox.natsUrl (NATS_URL)", `"redis"`, `"always"`, "database.port (DB_PORT)"} {
+			assert.Contains(t, err.Error(), s)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	c := Default()
+	c.Database.Password.Set("db-secret")
+	c.Maps.APIKey.Set("maps-secret")
+	var out bytes.Buffer
+
+	assert.Nil(t, c.Print(&out))
+
+	assert.NotContains(t, out.String(), "db-secret")
+	assert.NotContains(t, out.String(), "maps-secret")
+	assert.Contains(t, out.String(), "password: <redacted> # DB_PASSWORD")
+	assert.Contains(t, out.String(), "jwtHs256Secret: \"\" # AUTH_JWT_HS256_SECRET")
+}
+
+func TestPrintLoads(t *testing.T) {
+	c := Default()
+	c.Database.Password.Set("")
+	c.HTTP.ReadTimeout = 3 * time.Second
+	c.Outbox.Sinks = []string{"log", "webhook"}
+	var out bytes.Buffer
+	assert.Nil(t, c.Print(&out))
+
+	loaded, _, err := Load([]string{"--config", writeFile(t, "printed.yaml", out.String())}, env(nil))
+
+	assert.Nil(t, err)
+	assert.Equal(t, c, loaded)
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	password := writeFile(t, "db-password", "db-secret\n")
+	key := writeFile(t, "maps-key", "maps-secret")
+
+	c, _, err := Load([]string{"--google-map-api-key-file", key},
+		env(map[string]string{"DB_PASSWORD": "ignored", "DB_PASSWORD_FILE": password, "GOOGLE_MAP_API_KEY": "overridden"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "db-secret", c.Database.Password.Get())
+	assert.Equal(t, password, c.Database.Password.Path())
+	assert.Equal(t, "maps-secret", c.Maps.APIKey.Get())
+	var out bytes.Buffer
+	assert.Nil(t, c.Print(&out))
+	assert.NotContains(t, out.String(), "db-secret")
+	assert.Contains(t, out.String(), "passwordFile: "+password+" # DB_PASSWORD_FILE")
+	assert.Contains(t, out.String(), "apiKey: <redacted> # GOOGLE_MAP_API_KEY")
+}
+
+func TestLoadMissingSecretFile(t *testing.T) {
+	_, _, err := Load(nil, env(map[string]string{"DB_PASSWORD_FILE": filepath.Join(t.TempDir(), "missing")}))
+
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "DB_PASSWORD_FILE")
+	}
+}
+
+func TestDSN(t *testing.T) {
+	d := Database{Host: "db", Port: 5432, User: "postgres", Name: "orders", Password: secret.New(`it's a \secret`), SSLMode: "disable"}
+
+	assert.Equal(t, `host=db port=5432 user=postgres dbname=orders password='it\'s a \\secret' sslmode=disable`, d.DSN())
+	d.Password.Set("")
+	assert.True(t, strings.Contains(d.DSN(), "password='' "))
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
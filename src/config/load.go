@@ -0,0 +1,318 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"secret"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// env var naming the config file, when --config is not given
+	FileEnv = "CONFIG_FILE"
+	// suffixes of the env var, key and flag reading a secret from a file
+	fileEnvSuffix  = "_FILE"
+	fileKeySuffix  = "File"
+	fileFlagSuffix = "-file"
+)
+
+// envNames are the env vars of the settings by key
+var envNames = func() map[string]string {
+	names := map[string]string{}
+	for _, f := range fields(&Config{}) {
+		names[f.key] = f.env
+	}
+	return names
+}()
+
+// field is one setting of a Config
+type field struct {
+	// "<section>.<key>"
+	key    string
+	env    string
+	flag   string
+	secret bool
+	// whether the setting is the file of a secret
+	file  bool
+	value reflect.Value
+}
+
+var secretType = reflect.TypeOf(&secret.Value{})
+
+// fields returns the settings of c in declaration order, secrets followed by their file so it overrides them
+func fields(c *Config) []field {
+	var fs []field
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section, prefix := sections.Field(i), sections.Type().Field(i).Tag.Get("key")
+		for j := 0; j < section.NumField(); j++ {
+			f := section.Type().Field(j)
+			env := f.Tag.Get("env")
+			s := field{key: prefix + "." + f.Tag.Get("key"), env: env, flag: strings.ReplaceAll(strings.ToLower(env), "_", "-"),
+				secret: f.Type == secretType, value: section.Field(j)}
+			fs = append(fs, s)
+			if s.secret {
+				fs = append(fs, field{key: s.key + fileKeySuffix, env: s.env + fileEnvSuffix, flag: s.flag + fileFlagSuffix,
+					file: true, value: s.value})
+			}
+		}
+	}
+	return fs
+}
+
+// set parses s into the setting. Numbers and durations must not be negative, lists are comma separated. Secrets
+// are read from the file s names, empty for none.
+func (f field) set(s string) error {
+	switch v := f.value.Addr().Interface().(type) {
+	case **secret.Value:
+		if *v == nil {
+			*v = &secret.Value{}
+		}
+		if !f.file {
+			(*v).Set(s)
+		} else if s != "" {
+			return (*v).SetFile(s)
+		}
+	case *string:
+		*v = s
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		*v = n
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		*v = d
+	case *[]string:
+		*v = nil
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unsupported type of setting %s: %T", f.key, v))
+	}
+	return nil
+}
+
+// invalid describes a value of source which cannot be set, leaving the value of secrets out
+func (f field) invalid(source string, value string, err error) string {
+	if f.secret {
+		return fmt.Sprintf("%s: %v", source, err)
+	}
+	return fmt.Sprintf("%s %q: %v", source, value, err)
+}
+
+// flagValue records the value of a setting given as flag, to be set after the file and env vars
+type flagValue struct {
+	boolean bool
+	set     func(string)
+}
+
+func (v *flagValue) String() string {
+	return ""
+}
+
+func (v *flagValue) Set(s string) error {
+	v.set(s)
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.boolean
+}
+
+// Load returns the settings of the command line arguments args, over the env vars of lookupEnv, over the config
+// file of --config or CONFIG_FILE, over the defaults. The settings are validated. printConfig is whether
+// --print-config was given.
+func Load(args []string, lookupEnv func(string) (string, bool)) (c *Config, printConfig bool, err error) {
+	c = Default()
+	settings := fields(c)
+	fs := flag.NewFlagSet("llmc", flag.ContinueOnError)
+	path := fs.String("config", "", "YAML (.yaml, .yml) or TOML (.toml) file with the settings, overrides "+FileEnv)
+	fs.BoolVar(&printConfig, "print-config", false, "print the settings with the secrets redacted and exit")
+	flags := map[string]string{}
+	for _, f := range settings {
+		key := f.key
+		fs.Var(&flagValue{boolean: f.value.Kind() == reflect.Bool, set: func(s string) { flags[key] = s }}, f.flag,
+			fmt.Sprintf("%s, overrides %s", f.key, f.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+	if fs.NArg() > 0 {
+		return nil, false, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+
+	var errs []string
+	if *path == "" {
+		*path, _ = lookupEnv(FileEnv)
+	}
+	if *path != "" {
+		values, err := readFile(*path)
+		if err != nil {
+			return nil, false, fmt.Errorf("cannot read config file: %v", err)
+		}
+		for _, f := range settings {
+			if v, ok := values[f.key]; ok {
+				if err := f.set(v); err != nil {
+					errs = append(errs, f.invalid(*path+": "+f.key, v, err))
+				}
+				delete(values, f.key)
+			}
+		}
+		var unknown []string
+		for key := range values {
+			unknown = append(unknown, key)
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			errs = append(errs, fmt.Sprintf("%s: unknown setting %s", *path, key))
+		}
+	}
+	for _, f := range settings {
+		if v, ok := lookupEnv(f.env); ok {
+			if err := f.set(v); err != nil {
+				errs = append(errs, f.invalid(f.env, v, err))
+			}
+		}
+	}
+	for _, f := range settings {
+		if v, ok := flags[f.key]; ok {
+			if err := f.set(v); err != nil {
+				errs = append(errs, f.invalid("--"+f.flag, v, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, false, fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	if err := c.Validate(); err != nil {
+		return nil, false, err
+	}
+	return c, printConfig, nil
+}
+
+// readFile returns the values of the config file at path by "<section>.<key>", lists joined with commas
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	values := map[string]string{}
+	for name, section := range doc {
+		if section == nil {
+			continue
+		}
+		settings, ok := section.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is not a section", path, name)
+		}
+		for key, v := range settings {
+			s, err := fileValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s.%s %v", path, name, key, err)
+			}
+			values[name+"."+key] = s
+		}
+	}
+	return values, nil
+}
+
+// fileValue returns the scalar or list v as it would be given in an env var
+func fileValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("is a section, not a setting")
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// Print writes the settings to w as YAML config file, noting the env var of each, with the secrets which are set
+// redacted
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	var section *yaml.Node
+	prefix := ""
+	for _, f := range fields(c) {
+		name, key, _ := strings.Cut(f.key, ".")
+		if section == nil || name != prefix {
+			section, prefix = &yaml.Node{Kind: yaml.MappingNode}, name
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, section)
+		}
+		value := f.value.Interface()
+		switch v := value.(type) {
+		case *secret.Value:
+			if f.file {
+				value = v.Path()
+			} else {
+				value = v.String()
+			}
+		case time.Duration:
+			value = v.String()
+		}
+		v := &yaml.Node{}
+		if err := v.Encode(value); err != nil {
+			return err
+		}
+		v.LineComment = f.env
+		section.Content = append(section.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
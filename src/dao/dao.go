@@ -1,35 +1,127 @@
 package dao
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"entity"
 	"fmt"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"time"
 	//_ "github.com/jinzhu/gorm/dialects/mysql"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
 var db *gorm.DB
 
-func InitDB() {
+const (
+	contextKey = "dao:context"
+	// SQLSTATE of a unique constraint violation
+	uniqueViolation = "23505"
+	// key of the advisory lock held by the outbox relay
+	outboxRelayLock = 4711
+)
+
+// InitDB connects to the Postgres database of the connection string dsn returns. It is called for every new
+// connection, so a rotated password is used without a restart.
+func InitDB(dsn func() string) {
 	//db, err := gorm.Open("mysql", "user:password@tcp(db:3306)/db?charset=utf8mb4&parseTime=True")
-	d, err := gorm.Open("postgres", "host=db port=5432 user=postgres dbname=postgres password=password sslmode=disable")
+	sqlDB := sql.OpenDB(connector{dsn: dsn})
+	d, err := gorm.Open("postgres", sqlDB)
 	if err != nil {
+		sqlDB.Close()
 		panic(fmt.Sprintf("failed to connect database: %s", err.Error()))
 	}
 	db = d
 }
 
+// connector opens Postgres connections with the current connection string
+type connector struct {
+	dsn func() string
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	pc, err := pq.NewConnector(c.dsn())
+	if err != nil {
+		return nil, err
+	}
+	return pc.Connect(ctx)
+}
+
+func (c connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// IsUniqueViolation returns whether err is a Postgres unique constraint violation, e.g. a row with the key exists
+func IsUniqueViolation(err error) bool {
+	pe, ok := err.(*pq.Error)
+	return ok && pe.Code == uniqueViolation
+}
+
 func GetDB() *gorm.DB {
 	return db
 }
 
+// WithContext attaches ctx to db, so query callbacks can relate queries to the request
+func WithContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	if db == nil {
+		return nil
+	}
+	return db.Set(contextKey, ctx)
+}
+
+// ContextOf returns the context attached with WithContext, or background context
+func ContextOf(db *gorm.DB) context.Context {
+	if v, ok := db.Get(contextKey); ok {
+		if ctx, ok := v.(context.Context); ok {
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
 type DAO interface {
 	FindWithLimitAndOffset(db *gorm.DB, limit int, offset int, out *[]entity.Order)
+	FindFirstWithId(db *gorm.DB, id int, out *entity.Order)
 	FindFirstWithIdAndStatus(db *gorm.DB, status string, id int, out *entity.Order)
-	UpdateOrderStatus(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string) *gorm.DB
-	CreateOrder(db *gorm.DB, modelToCreate *entity.Order) *gorm.DB
+	UpdateOrderStatus(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events OrderEvents) *gorm.DB
+	CreateOrder(db *gorm.DB, modelToCreate *entity.Order, events OrderEvents) *gorm.DB
+	FindIdempotencyKey(db *gorm.DB, subject string, key string, out *entity.IdempotencyKey)
+	CreateIdempotencyKey(db *gorm.DB, modelToCreate *entity.IdempotencyKey) *gorm.DB
+	UpdateIdempotencyKey(db *gorm.DB, modelToUpdate *entity.IdempotencyKey) *gorm.DB
+	DeleteIdempotencyKey(db *gorm.DB, subject string, key string) *gorm.DB
+	Ping(ctx context.Context, db *gorm.DB) error
+	CountOrdersByStatus(db *gorm.DB) (map[string]int, error)
+	FindServiceAreas(db *gorm.DB, out *[]entity.ServiceArea) error
+	ReplaceServiceAreas(db *gorm.DB, areas []entity.ServiceArea) error
+	SeedServiceAreas(db *gorm.DB, areas []entity.ServiceArea) (bool, error)
+	FindOrdersWithStatus(db *gorm.DB, status string, limit int, out *[]entity.Order) error
+	CountOrdersByCourier(db *gorm.DB, status string) (map[string]int, error)
+	FindAvailableCouriers(db *gorm.DB, locatedSince time.Time, out *[]entity.Courier) error
+	FindCourier(db *gorm.DB, id string, out *entity.Courier)
+	FindCouriers(db *gorm.DB, out *[]entity.Courier) error
+	SaveCourier(db *gorm.DB, c *entity.Courier) error
+	AssignOrder(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events OrderEvents) *gorm.DB
+	SaveCourierLocation(db *gorm.DB, loc *entity.CourierLocation, historySize int) error
+	CreateWebhookSubscription(db *gorm.DB, modelToCreate *entity.WebhookSubscription) error
+	FindWebhookSubscriptions(db *gorm.DB, out *[]entity.WebhookSubscription) error
+	DeleteWebhookSubscription(db *gorm.DB, id uint64) (bool, error)
+	CreateWebhookDeliveries(db *gorm.DB, deliveries []entity.WebhookDelivery) error
+	FindDueWebhookDeliveries(db *gorm.DB, now time.Time, limit int, out *[]entity.WebhookDelivery) error
+	ClaimWebhookDelivery(db *gorm.DB, delivery *entity.WebhookDelivery, until time.Time) (bool, error)
+	UpdateWebhookDelivery(db *gorm.DB, modelToUpdate *entity.WebhookDelivery) error
+	FindWebhookDeliveries(db *gorm.DB, subscriptionID uint64, status string, limit int, offset int, out *[]entity.WebhookDelivery) error
+	RetryWebhookDelivery(db *gorm.DB, subscriptionID uint64, id uint64, now time.Time) (bool, error)
+	LockOutboxRelay(ctx context.Context, db *gorm.DB) (unlock func(), locked bool, err error)
+	FindOutboxEvents(db *gorm.DB, limit int, out *[]entity.OutboxEvent) error
+	MarkOutboxEventPublished(db *gorm.DB, id uint64, publishedTo string) error
+	DeleteOutboxEvent(db *gorm.DB, id uint64) error
 }
 
+// OrderEvents returns the outbox events recording a change of order, called with the order as saved
+type OrderEvents func(order *entity.Order) ([]entity.OutboxEvent, error)
+
 type GormDB struct {
 	DAO
 }
@@ -38,14 +130,426 @@ func (gdb *GormDB) FindWithLimitAndOffset(db *gorm.DB, limit int, offset int, ou
 	db.Limit(limit).Offset(offset).Find(out)
 }
 
+func (gdb *GormDB) FindFirstWithId(db *gorm.DB, id int, out *entity.Order) {
+	db.First(out, id)
+}
+
 func (gdb *GormDB) FindFirstWithIdAndStatus(db *gorm.DB, status string, id int, out *entity.Order) {
 	db.Where("status = ?", status).First(out, id)
 }
 
-func (gdb *GormDB) UpdateOrderStatus(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string) *gorm.DB {
-	return db.Model(modelToUpdate).Where("status = ?", oldStatus).Update("status", newStatus)
+// UpdateOrderStatus only updates when both the status and the version of modelToUpdate are still current,
+// bumping the version on success. The courier of modelToUpdate is saved along, and so are the events, in the same
+// transaction. Nothing is changed when no row is affected.
+func (gdb *GormDB) UpdateOrderStatus(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events OrderEvents) *gorm.DB {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx
+	}
+	return updateOrderStatus(tx, modelToUpdate, newStatus, oldStatus, events)
+}
+
+// AssignOrder is UpdateOrderStatus, but only when the courier of modelToUpdate is available and has less than its
+// capacity of orders in newStatus. The courier is locked meanwhile, so concurrent assignments to it cannot exceed
+// the capacity. Nothing is changed when no row is affected.
+func (gdb *GormDB) AssignOrder(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events OrderEvents) *gorm.DB {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx
+	}
+	var courier entity.Courier
+	res := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND available = ?", modelToUpdate.CourierID, true).First(&courier)
+	if res.RecordNotFound() {
+		tx.Rollback()
+		return &gorm.DB{}
+	}
+	if res.Error != nil {
+		tx.Rollback()
+		return res
+	}
+	var load int
+	if res := tx.Model(&entity.Order{}).Where("courier_id = ? AND status = ?", courier.ID, newStatus).Count(&load); res.Error != nil {
+		tx.Rollback()
+		return res
+	}
+	if load >= courier.Capacity {
+		tx.Rollback()
+		return &gorm.DB{}
+	}
+	return updateOrderStatus(tx, modelToUpdate, newStatus, oldStatus, events)
+}
+
+// updateOrderStatus does the conditional update of UpdateOrderStatus in tx, which is committed or rolled back
+func updateOrderStatus(tx *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events OrderEvents) *gorm.DB {
+	version, status := modelToUpdate.Version, modelToUpdate.Status
+	res := tx.Model(modelToUpdate).Where("status = ? AND version = ?", oldStatus, version).
+		Updates(map[string]interface{}{"status": newStatus, "courier_id": modelToUpdate.CourierID, "version": gorm.Expr("version + 1")})
+	if res.Error != nil || res.RowsAffected == 0 {
+		tx.Rollback()
+		modelToUpdate.Version, modelToUpdate.Status = version, status
+		return res
+	}
+	modelToUpdate.Version, modelToUpdate.Status = version+1, newStatus
+	if err := commitWithEvents(tx, modelToUpdate, events); err != nil {
+		modelToUpdate.Version, modelToUpdate.Status = version, status
+		res.RowsAffected = 0
+		_ = res.AddError(err)
+	}
+	return res
+}
+
+// CreateOrder creates modelToCreate with its events in one transaction
+func (gdb *GormDB) CreateOrder(db *gorm.DB, modelToCreate *entity.Order, events OrderEvents) *gorm.DB {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx
+	}
+	res := tx.Create(modelToCreate)
+	if res.Error != nil {
+		tx.Rollback()
+		return res
+	}
+	if err := commitWithEvents(tx, modelToCreate, events); err != nil {
+		modelToCreate.ID = 0
+		res.RowsAffected = 0
+		_ = res.AddError(err)
+	}
+	return res
 }
 
-func (gdb *GormDB) CreateOrder(db *gorm.DB, modelToCreate *entity.Order) *gorm.DB {
+// commitWithEvents adds the events of order to the outbox and commits tx, rolling it back on error
+func commitWithEvents(tx *gorm.DB, order *entity.Order, events OrderEvents) error {
+	if events != nil {
+		evs, err := events(order)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		for i := range evs {
+			if err := tx.Create(&evs[i]).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (gdb *GormDB) FindIdempotencyKey(db *gorm.DB, subject string, key string, out *entity.IdempotencyKey) {
+	db.Where("subject = ? AND key = ?", subject, key).First(out)
+}
+
+func (gdb *GormDB) CreateIdempotencyKey(db *gorm.DB, modelToCreate *entity.IdempotencyKey) *gorm.DB {
 	return db.Create(modelToCreate)
 }
+
+func (gdb *GormDB) UpdateIdempotencyKey(db *gorm.DB, modelToUpdate *entity.IdempotencyKey) *gorm.DB {
+	return db.Model(modelToUpdate).Updates(map[string]interface{}{
+		"status_code": modelToUpdate.StatusCode, "content_type": modelToUpdate.ContentType,
+		"headers": modelToUpdate.Headers, "response_body": modelToUpdate.ResponseBody})
+}
+
+func (gdb *GormDB) DeleteIdempotencyKey(db *gorm.DB, subject string, key string) *gorm.DB {
+	return db.Where("subject = ? AND key = ?", subject, key).Delete(&entity.IdempotencyKey{})
+}
+
+func (gdb *GormDB) Ping(ctx context.Context, db *gorm.DB) error {
+	return db.DB().PingContext(ctx)
+}
+
+func (gdb *GormDB) CountOrdersByStatus(db *gorm.DB) (map[string]int, error) {
+	rows, err := db.Model(&entity.Order{}).Select("status, count(*)").Group("status").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+func (gdb *GormDB) FindServiceAreas(db *gorm.DB, out *[]entity.ServiceArea) error {
+	return db.Order("position").Find(out).Error
+}
+
+// ReplaceServiceAreas swaps all service areas for areas in one transaction, marking them seeded so later starts do
+// not seed over the replacement
+func (gdb *GormDB) ReplaceServiceAreas(db *gorm.DB, areas []entity.ServiceArea) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if _, err := markSetting(tx, entity.SettingServiceAreasSeeded); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := replaceServiceAreas(tx, areas); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// SeedServiceAreas stores areas unless the service areas were seeded or replaced before, by any instance. It
+// returns whether areas were stored.
+func (gdb *GormDB) SeedServiceAreas(db *gorm.DB, areas []entity.ServiceArea) (bool, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	marked, err := markSetting(tx, entity.SettingServiceAreasSeeded)
+	if err != nil || !marked {
+		tx.Rollback()
+		return false, err
+	}
+	// stored before the flag existed, keep them
+	var stored int
+	if err := tx.Model(&entity.ServiceArea{}).Count(&stored).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if stored > 0 {
+		return false, tx.Commit().Error
+	}
+	if err := replaceServiceAreas(tx, areas); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit().Error
+}
+
+func replaceServiceAreas(tx *gorm.DB, areas []entity.ServiceArea) error {
+	if err := tx.Delete(&entity.ServiceArea{}).Error; err != nil {
+		return err
+	}
+	for i := range areas {
+		if err := tx.Create(&areas[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markSetting sets the flag name, returning false when it was set already
+func markSetting(db *gorm.DB, name string) (bool, error) {
+	res := db.Set("gorm:insert_option", "ON CONFLICT (name) DO NOTHING").Create(&entity.Setting{Name: name, Value: "true"})
+	return res.RowsAffected > 0, res.Error
+}
+
+// FindOrdersWithStatus returns the oldest orders with status first
+func (gdb *GormDB) FindOrdersWithStatus(db *gorm.DB, status string, limit int, out *[]entity.Order) error {
+	return db.Where("status = ?", status).Order("id").Limit(limit).Find(out).Error
+}
+
+func (gdb *GormDB) CountOrdersByCourier(db *gorm.DB, status string) (map[string]int, error) {
+	rows, err := db.Model(&entity.Order{}).Select("courier_id, count(*)").Where("status = ? AND courier_id <> ''", status).
+		Group("courier_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var courierID string
+		var n int
+		if err := rows.Scan(&courierID, &n); err != nil {
+			return nil, err
+		}
+		counts[courierID] = n
+	}
+	return counts, rows.Err()
+}
+
+// FindAvailableCouriers returns the available couriers located since locatedSince
+func (gdb *GormDB) FindAvailableCouriers(db *gorm.DB, locatedSince time.Time, out *[]entity.Courier) error {
+	return db.Where("available = ? AND located_at >= ?", true, locatedSince).Find(out).Error
+}
+
+func (gdb *GormDB) FindCourier(db *gorm.DB, id string, out *entity.Courier) {
+	db.Where("id = ?", id).First(out)
+}
+
+func (gdb *GormDB) FindCouriers(db *gorm.DB, out *[]entity.Courier) error {
+	return db.Order("id").Find(out).Error
+}
+
+// SaveCourier creates c, or updates the capacity and availability of the existing courier keeping its location
+func (gdb *GormDB) SaveCourier(db *gorm.DB, c *entity.Courier) error {
+	return db.Set("gorm:insert_option", "ON CONFLICT (id) DO UPDATE SET capacity = EXCLUDED.capacity, available = EXCLUDED.available, updated_at = EXCLUDED.updated_at").
+		Create(c).Error
+}
+
+// SaveCourierLocation adds loc to the history of the courier, keeping the latest historySize, and makes it the
+// position of the courier unless a later one is known. Unknown couriers are created available.
+func (gdb *GormDB) SaveCourierLocation(db *gorm.DB, loc *entity.CourierLocation, historySize int) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := saveCourierLocation(tx, loc, historySize); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func saveCourierLocation(tx *gorm.DB, loc *entity.CourierLocation, historySize int) error {
+	if err := tx.Create(loc).Error; err != nil {
+		return err
+	}
+
+	res := tx.Model(&entity.Courier{}).Where("id = ? AND (located_at IS NULL OR located_at < ?)", loc.CourierID, loc.LocatedAt).
+		Updates(map[string]interface{}{"lat": loc.Lat, "long": loc.Long, "accuracy": loc.Accuracy, "located_at": loc.LocatedAt})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// either a late ping or a new courier
+		var n int
+		if err := tx.Model(&entity.Courier{}).Where("id = ?", loc.CourierID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			c := &entity.Courier{ID: loc.CourierID, Capacity: 1, Available: true, Lat: loc.Lat, Long: loc.Long,
+				Accuracy: loc.Accuracy, LocatedAt: &loc.LocatedAt}
+			if err := tx.Create(c).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	// the oldest location kept, everything older goes through the (courier_id, located_at) index
+	var oldest entity.CourierLocation
+	res = tx.Where("courier_id = ?", loc.CourierID).Order("located_at desc, id desc").Offset(historySize - 1).Limit(1).Find(&oldest)
+	if res.RecordNotFound() {
+		return nil
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	return tx.Where("courier_id = ? AND (located_at < ? OR (located_at = ? AND id < ?))", loc.CourierID, oldest.LocatedAt, oldest.LocatedAt, oldest.ID).
+		Delete(&entity.CourierLocation{}).Error
+}
+
+func (gdb *GormDB) CreateWebhookSubscription(db *gorm.DB, modelToCreate *entity.WebhookSubscription) error {
+	return db.Create(modelToCreate).Error
+}
+
+func (gdb *GormDB) FindWebhookSubscriptions(db *gorm.DB, out *[]entity.WebhookSubscription) error {
+	return db.Order("id").Find(out).Error
+}
+
+// DeleteWebhookSubscription deletes the subscription with its deliveries, false when there is no such subscription
+func (gdb *GormDB) DeleteWebhookSubscription(db *gorm.DB, id uint64) (bool, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	if err := tx.Where("subscription_id = ?", id).Delete(&entity.WebhookDelivery{}).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	res := tx.Where("id = ?", id).Delete(&entity.WebhookSubscription{})
+	if res.Error != nil {
+		tx.Rollback()
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, tx.Commit().Error
+}
+
+// CreateWebhookDeliveries skips deliveries of an event already recorded for the subscription
+func (gdb *GormDB) CreateWebhookDeliveries(db *gorm.DB, deliveries []entity.WebhookDelivery) error {
+	insert := db.Set("gorm:insert_option", "ON CONFLICT (subscription_id, event_id) DO NOTHING")
+	for i := range deliveries {
+		if err := insert.Create(&deliveries[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindDueWebhookDeliveries returns the pending deliveries whose next attempt is due at now, the longest due first
+func (gdb *GormDB) FindDueWebhookDeliveries(db *gorm.DB, now time.Time, limit int, out *[]entity.WebhookDelivery) error {
+	return db.Where("status = ? AND next_attempt_at <= ?", entity.WebhookPending, now).Order("next_attempt_at").Limit(limit).Find(out).Error
+}
+
+// ClaimWebhookDelivery counts an attempt of delivery and holds off other attempts until then. It is false when
+// the delivery was attempted or changed meanwhile, e.g. by another instance.
+func (gdb *GormDB) ClaimWebhookDelivery(db *gorm.DB, delivery *entity.WebhookDelivery, until time.Time) (bool, error) {
+	attempts := delivery.Attempts
+	res := db.Model(delivery).Where("status = ? AND attempts = ?", entity.WebhookPending, attempts).
+		Updates(map[string]interface{}{"attempts": attempts + 1, "next_attempt_at": until})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	delivery.Attempts = attempts + 1
+	return true, nil
+}
+
+// UpdateWebhookDelivery saves the outcome of an attempt
+func (gdb *GormDB) UpdateWebhookDelivery(db *gorm.DB, modelToUpdate *entity.WebhookDelivery) error {
+	return db.Model(modelToUpdate).Updates(map[string]interface{}{
+		"status": modelToUpdate.Status, "next_attempt_at": modelToUpdate.NextAttemptAt, "last_status": modelToUpdate.LastStatus,
+		"last_error": modelToUpdate.LastError, "delivered_at": modelToUpdate.DeliveredAt}).Error
+}
+
+// FindWebhookDeliveries returns the deliveries of a subscription, the latest first, all statuses when status is empty
+func (gdb *GormDB) FindWebhookDeliveries(db *gorm.DB, subscriptionID uint64, status string, limit int, offset int, out *[]entity.WebhookDelivery) error {
+	q := db.Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	return q.Order("id desc").Limit(limit).Offset(offset).Find(out).Error
+}
+
+// RetryWebhookDelivery makes a dead delivery pending again with fresh attempts, false when there is no such dead
+// delivery
+func (gdb *GormDB) RetryWebhookDelivery(db *gorm.DB, subscriptionID uint64, id uint64, now time.Time) (bool, error) {
+	res := db.Model(&entity.WebhookDelivery{}).Where("id = ? AND subscription_id = ? AND status = ?", id, subscriptionID, entity.WebhookDead).
+		Updates(map[string]interface{}{"status": entity.WebhookPending, "attempts": 0, "next_attempt_at": now})
+	return res.RowsAffected > 0, res.Error
+}
+
+// LockOutboxRelay takes the lock letting a single relay publish at a time, so events are published in order.
+// Nothing is locked when another relay holds it. The lock is held by a connection of its own until unlock.
+func (gdb *GormDB) LockOutboxRelay(ctx context.Context, db *gorm.DB) (func(), bool, error) {
+	conn, err := db.DB().Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", outboxRelayLock).Scan(&locked); err != nil || !locked {
+		conn.Close()
+		return nil, false, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", outboxRelayLock); err != nil {
+			// a pooled connection would keep holding the lock, discard it instead
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, true, nil
+}
+
+// FindOutboxEvents finds up to limit outbox events, oldest first
+func (gdb *GormDB) FindOutboxEvents(db *gorm.DB, limit int, out *[]entity.OutboxEvent) error {
+	return db.Order("id").Limit(limit).Find(out).Error
+}
+
+// MarkOutboxEventPublished records the sinks the event was published to
+func (gdb *GormDB) MarkOutboxEventPublished(db *gorm.DB, id uint64, publishedTo string) error {
+	return db.Model(&entity.OutboxEvent{}).Where("id = ?", id).Update("published_to", publishedTo).Error
+}
+
+func (gdb *GormDB) DeleteOutboxEvent(db *gorm.DB, id uint64) error {
+	return db.Where("id = ?", id).Delete(&entity.OutboxEvent{}).Error
+}
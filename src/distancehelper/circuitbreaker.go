@@ -0,0 +1,61 @@
+package distancehelper
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("distance provider circuit is open")
+
+// CircuitBreaker stops calls to the provider for Cooldown after MaxFailures consecutive failures.
+// Once the cooldown passed, calls are let through again and the first failure reopens the circuit.
+// A nil breaker never opens.
+type CircuitBreaker struct {
+	MaxFailures int
+	Cooldown    time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func NewCircuitBreaker(maxFailures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{MaxFailures: maxFailures, Cooldown: cooldown}
+}
+
+// Allow returns whether a call may be made
+func (cb *CircuitBreaker) Allow() bool {
+	return !cb.Open()
+}
+
+// Open returns whether calls are currently rejected
+func (cb *CircuitBreaker) Open() bool {
+	if cb == nil {
+		return false
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.failures >= cb.MaxFailures && time.Since(cb.openedAt) < cb.Cooldown
+}
+
+func (cb *CircuitBreaker) Success() {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures = 0
+}
+
+func (cb *CircuitBreaker) Failure() {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	if cb.failures >= cb.MaxFailures {
+		cb.openedAt = time.Now()
+	}
+}
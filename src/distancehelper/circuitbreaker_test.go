@@ -0,0 +1,63 @@
+package distancehelper
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"secret"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterMaxFailures(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Hour)
+
+	cb.Failure()
+	assert.True(t, cb.Allow())
+	cb.Failure()
+	assert.False(t, cb.Allow())
+	assert.True(t, cb.Open())
+}
+
+func TestBreakerSuccessResets(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Hour)
+
+	cb.Failure()
+	cb.Success()
+	cb.Failure()
+
+	assert.True(t, cb.Allow())
+}
+
+func TestBreakerHalfOpenAfterCooldown(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Millisecond)
+	cb.Failure()
+	assert.True(t, cb.Open())
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, cb.Allow())
+
+	// a failed trial call opens the circuit again
+	cb.Failure()
+	assert.True(t, cb.Open())
+}
+
+func TestNilBreaker(t *testing.T) {
+	var cb *CircuitBreaker
+	cb.Failure()
+
+	assert.True(t, cb.Allow())
+	assert.False(t, cb.Open())
+}
+
+func TestDistanceWithOpenCircuit(t *testing.T) {
+	h := &GMapHelper{Breaker: NewCircuitBreaker(1, time.Hour), APIKey: secret.New("A")}
+
+	_, err := h.GetRoute(context.Background(), req, mockInterfaces(getNormalResponse(), errors.New("")))
+	assert.NotNil(t, err)
+	assert.True(t, h.CircuitOpen())
+
+	route, err := h.GetRoute(context.Background(), req, mockInterfaces(getNormalResponse(), nil))
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, -1, route.DistanceMeters)
+}
@@ -3,17 +3,20 @@ package distancehelper
 import (
 	"context"
 	"fmt"
-	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"googlemaps.github.io/maps"
-	"os"
+	"logging"
+	"metrics"
 	"request"
+	"secret"
 	"strings"
+	"time"
 )
 
-const (
-	distNoKey  = 0
-	apiKeyName = "GOOGLE_MAP_API_KEY"
-)
+const distNoKey = 0
 
 type GMap interface {
 	GetClient(apiKey string) (GMapClient, error)
@@ -34,20 +37,48 @@ func (m *GMapClientReal) DistanceMatrix(ctx context.Context, r *maps.DistanceMat
 }
 
 type MapHelper interface {
-	GetDistanceMeters(co *request.PlaceOrderRequest, gm GMap) (int, error)
+	GetRoute(ctx context.Context, co *request.PlaceOrderRequest, gm GMap) (Route, error)
 }
-type GMapHelper struct{ MapHelper }
 
-func init() {
-	if _, present := os.LookupEnv(apiKeyName); !present {
-		log.Warn(fmt.Sprintf("Google API key is not set, distance will always be %d.", distNoKey))
-	}
+// Route is the driving route between the points of an order. DistanceMeters is -1 when there is none.
+type Route struct {
+	DistanceMeters int
+	Duration       time.Duration
+}
+type GMapHelper struct {
+	MapHelper
+	Breaker *CircuitBreaker
+	// Google API key, read for every lookup as it is reloaded. Without it distance is always distNoKey.
+	APIKey *secret.Value
 }
 
-func (gh *GMapHelper) GetDistanceMeters(co *request.PlaceOrderRequest, gm GMap) (int, error) {
-	key, present := os.LookupEnv(apiKeyName)
-	if !present {
-		return 0, nil
+// ProviderStatus is implemented by map helpers which can report whether they are able to serve requests
+type ProviderStatus interface {
+	Configured() bool
+	CircuitOpen() bool
+}
+
+func (gh *GMapHelper) Configured() bool {
+	return gh.APIKey.Get() != ""
+}
+
+func (gh *GMapHelper) CircuitOpen() bool {
+	return gh.Breaker.Open()
+}
+
+func (gh *GMapHelper) GetRoute(ctx context.Context, co *request.PlaceOrderRequest, gm GMap) (Route, error) {
+	key := gh.APIKey.Get()
+	if key == "" {
+		return Route{DistanceMeters: distNoKey}, nil
+	}
+	ctx, span := otel.Tracer("distancehelper").Start(ctx, "distance.GetRoute", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	start := time.Now()
+	if !gh.Breaker.Allow() {
+		span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+		metrics.ObserveDistanceCall(start, metrics.DistanceRejected)
+		return Route{DistanceMeters: -1}, ErrCircuitOpen
 	}
 
 	// create client
@@ -59,14 +90,22 @@ func (gh *GMapHelper) GetDistanceMeters(co *request.PlaceOrderRequest, gm GMap)
 	// get distance
 	r := &maps.DistanceMatrixRequest{Origins: []string{strings.Join(co.Origin, ",")},
 		Destinations: []string{strings.Join(co.Destination, ",")}}
-	dist, err := c.DistanceMatrix(context.Background(), r)
+	dist, err := c.DistanceMatrix(ctx, r)
 	if err != nil {
-		log.Errorf("Google map API problem: %v", err)
-		return -1, err
+		logging.FromContext(ctx).Errorf("Google map API problem: %v", err)
+		metrics.ObserveDistanceCall(start, metrics.DistanceError)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		gh.Breaker.Failure()
+		return Route{DistanceMeters: -1}, err
 	}
-	if dist.Rows[0].Elements[0].Status != "OK" {
-		return -1, nil
+	metrics.ObserveDistanceCall(start, metrics.DistanceOK)
+	gh.Breaker.Success()
+	e := dist.Rows[0].Elements[0]
+	span.SetAttributes(attribute.String("distance.status", e.Status))
+	if e.Status != "OK" {
+		return Route{DistanceMeters: -1}, nil
 	}
 
-	return dist.Rows[0].Elements[0].Distance.Meters, nil
+	return Route{DistanceMeters: e.Distance.Meters, Duration: e.Duration}, nil
 }
@@ -6,8 +6,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"googlemaps.github.io/maps"
-	"os"
 	"request"
+	"secret"
 	"testing"
 	"time"
 )
@@ -33,61 +33,57 @@ func (m *GMapMock) GetClient(apiKey string) (GMapClient, error) {
 }
 
 var req = &request.PlaceOrderRequest{Origin: []string{"22.2802", "114.184919"}, Destination: []string{"25.052192", "121.522333"}}
-var gh = &GMapHelper{}
+var gh = &GMapHelper{APIKey: secret.New("A")}
 
 func TestDistanceWithNoKeyAndEmptyRequest(t *testing.T) {
-	os.Remove(apiKeyName)
-	d, err := gh.GetDistanceMeters(&request.PlaceOrderRequest{}, &GMapMock{})
-	if d != 0 || err != nil {
-		t.Errorf("Incorrect distance: got %d, expected 0; err: %v", d, err)
+	route, err := (&GMapHelper{}).GetRoute(context.Background(), &request.PlaceOrderRequest{}, &GMapMock{})
+	if route.DistanceMeters != 0 || err != nil {
+		t.Errorf("Incorrect distance: got %d, expected 0; err: %v", route.DistanceMeters, err)
 	}
 }
 
 func TestDistanceWithNoKeyAndNonEmptyRequest(t *testing.T) {
-	os.Remove(apiKeyName)
-	d, err := gh.GetDistanceMeters(req, &GMapMock{})
-	if d != 0 || err != nil {
-		t.Errorf("Incorrect distance: got %d, expected 0; err: %v", d, err)
+	route, err := (&GMapHelper{}).GetRoute(context.Background(), req, &GMapMock{})
+	if route.DistanceMeters != 0 || err != nil {
+		t.Errorf("Incorrect distance: got %d, expected 0; err: %v", route.DistanceMeters, err)
 	}
 }
 
 func TestEmptyAPIKey(t *testing.T) {
-	os.Setenv(apiKeyName, "")
+	h := &GMapHelper{APIKey: secret.New("")}
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("The code did not panic")
-		}
-	}()
+	route, err := h.GetRoute(context.Background(), req, &GMapMock{})
 
-	// The following is the code under test
-	gh.GetDistanceMeters(req, &GMapMock{})
+	assert.False(t, h.Configured())
+	assert.Nil(t, err)
+	assert.Equal(t, distNoKey, route.DistanceMeters)
 }
 
 func TestHappyFlow(t *testing.T) {
-	os.Setenv(apiKeyName, "A")
+	route, _ := gh.GetRoute(context.Background(), req, mockInterfaces(getNormalResponse(), nil))
+
+	assert.Equal(t, 1049, route.DistanceMeters)
+}
 
-	d, _ := gh.GetDistanceMeters(req, mockInterfaces(getNormalResponse(), nil))
+func TestRouteDuration(t *testing.T) {
+	route, err := gh.GetRoute(context.Background(), req, mockInterfaces(getNormalResponse(), nil))
 
-	assert.Equal(t, 1049, d)
+	assert.Nil(t, err)
+	assert.Equal(t, Route{DistanceMeters: 1049, Duration: 416 * time.Second}, route)
 }
 
 func TestGMapAPIError(t *testing.T) {
-	os.Setenv(apiKeyName, "A")
-
-	d, err := gh.GetDistanceMeters(req, mockInterfaces(getNormalResponse(), errors.New("")))
+	route, err := gh.GetRoute(context.Background(), req, mockInterfaces(getNormalResponse(), errors.New("")))
 
 	assert.NotNil(t, err)
-	assert.Equal(t, -1, d)
+	assert.Equal(t, -1, route.DistanceMeters)
 }
 
 func TestGMapReturnNotOK(t *testing.T) {
-	os.Setenv(apiKeyName, "A")
-
-	d, err := gh.GetDistanceMeters(req, mockInterfaces(getErrorResponse(), nil))
+	route, err := gh.GetRoute(context.Background(), req, mockInterfaces(getErrorResponse(), nil))
 
 	assert.Nil(t, err)
-	assert.Equal(t, -1, d)
+	assert.Equal(t, -1, route.DistanceMeters)
 }
 
 func mockInterfaces(expected *maps.DistanceMatrixResponse, err error) GMap {
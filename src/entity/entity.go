@@ -3,13 +3,148 @@ package entity
 import "time"
 
 type Order struct {
-	ID          uint64    `gorm:"primary_key" json:"id"`
-	Distance    int       `gorm:"not null" json:"distance"`
-	Status      string    `gorm:"type:varchar(10);not null" json:"status"`
+	ID        uint64 `gorm:"primary_key" json:"id"`
+	Distance  int    `gorm:"not null" json:"distance"`
+	Status    string `gorm:"type:varchar(10);not null" json:"status"`
+	Version   uint64 `gorm:"not null;default:1" json:"version"`
+	CourierID string `gorm:"type:varchar(64)" json:"courierId,omitempty"`
+	// the caller who placed the order, customers only get to see their own orders
+	CreatedBy   string    `gorm:"type:varchar(64);index" json:"createdBy,omitempty"`
 	OriginsLat  string    `json:"-"`
 	OriginsLong string    `json:"-"`
 	DestLat     string    `json:"-"`
 	DestLong    string    `json:"-"`
 	CreatedAt   time.Time `json:"-"`
 	UpdatedAt   time.Time `json:"-"`
+
+	// of the route in seconds
+	Duration int `gorm:"not null;default:0" json:"duration"`
+	// in minor units of Currency, e.g. cents, 0 when pricing is not configured
+	Price    int64  `gorm:"not null;default:0" json:"price"`
+	Currency string `gorm:"type:varchar(3)" json:"currency,omitempty"`
+
+	// as given by the customer, when the points were not placed as coordinates
+	OriginAddress string `gorm:"type:varchar(512)" json:"originAddress,omitempty"`
+	OriginPlaceID string `gorm:"type:varchar(255)" json:"originPlaceId,omitempty"`
+	DestAddress   string `gorm:"type:varchar(512)" json:"destinationAddress,omitempty"`
+	DestPlaceID   string `gorm:"type:varchar(255)" json:"destinationPlaceId,omitempty"`
+
+	// as resolved by the geocoder or the reverse geocoder
+	OriginFormattedAddress string `gorm:"type:varchar(512)" json:"originFormattedAddress,omitempty"`
+	DestFormattedAddress   string `gorm:"type:varchar(512)" json:"destinationFormattedAddress,omitempty"`
+
+	// service area of the origin, empty without service areas
+	Zone string `gorm:"type:varchar(64)" json:"zone,omitempty"`
+
+	// last known location of the courier, only filled for order detail
+	CourierLocation *Location `gorm:"-" json:"courierLocation,omitempty"`
+}
+
+type IdempotencyKey struct {
+	Subject      string `gorm:"primary_key;type:varchar(255)"`
+	Key          string `gorm:"primary_key;type:varchar(255)"`
+	RequestHash  string `gorm:"type:varchar(64);not null"`
+	StatusCode   int    `gorm:"not null"`
+	ContentType  string `gorm:"type:varchar(255)"`
+	Headers      string `gorm:"type:text"`
+	ResponseBody string `gorm:"type:text"`
+	CreatedAt    time.Time
+}
+
+// ServiceArea is a zone orders are accepted in, Geometry is a GeoJSON Polygon or MultiPolygon
+type ServiceArea struct {
+	Name      string `gorm:"primary_key;type:varchar(64)"`
+	Position  int    `gorm:"not null"`
+	Geometry  string `gorm:"type:text;not null"`
+	UpdatedAt time.Time
+}
+
+// Setting is a named flag or value shared by all instances, e.g. SettingServiceAreasSeeded
+type Setting struct {
+	Name      string `gorm:"primary_key;type:varchar(64)"`
+	Value     string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+// SettingServiceAreasSeeded is set once the service areas were seeded or replaced, from then on the database has
+// the service areas even when there are none
+const SettingServiceAreasSeeded = "service_areas_seeded"
+
+// Courier takes orders, at most Capacity at a time. Their last known location is used for auto-dispatch.
+type Courier struct {
+	ID        string     `gorm:"primary_key;type:varchar(64)" json:"id"`
+	Capacity  int        `gorm:"not null;default:1" json:"capacity"`
+	Available bool       `gorm:"not null" json:"available"`
+	Lat       float64    `json:"lat"`
+	Long      float64    `json:"lng"`
+	Accuracy  float64    `json:"accuracy,omitempty"`
+	LocatedAt *time.Time `json:"locatedAt,omitempty"`
+	CreatedAt time.Time  `json:"-"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+// CourierLocation is a GPS ping of a courier, only the latest ones are kept
+type CourierLocation struct {
+	ID        uint64    `gorm:"primary_key"`
+	CourierID string    `gorm:"type:varchar(64);not null;index:idx_courier_location_time"`
+	Lat       float64   `gorm:"not null"`
+	Long      float64   `gorm:"not null"`
+	Accuracy  float64   `gorm:"not null"`
+	LocatedAt time.Time `gorm:"not null;index:idx_courier_location_time"`
+	CreatedAt time.Time
+}
+
+// Location is where a courier was at LocatedAt, Accuracy is in meters
+type Location struct {
+	Lat       float64   `json:"lat"`
+	Long      float64   `json:"lng"`
+	Accuracy  float64   `json:"accuracy,omitempty"`
+	LocatedAt time.Time `json:"locatedAt"`
+}
+
+// WebhookSubscription receives the events listed in Events, a comma separated list, signed with Secret
+type WebhookSubscription struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	URL       string    `gorm:"type:varchar(2048);not null" json:"url"`
+	Secret    string    `gorm:"type:varchar(255);not null" json:"-"`
+	Events    string    `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead"
+)
+
+// WebhookDelivery is an event to be sent to a subscription, retried until delivered or dead
+type WebhookDelivery struct {
+	ID             uint64 `gorm:"primary_key" json:"id"`
+	SubscriptionID uint64 `gorm:"not null;unique_index:idx_webhook_delivery_event" json:"subscriptionId"`
+	// the outbox event delivered, each is delivered once per subscription
+	EventID uint64 `gorm:"not null;unique_index:idx_webhook_delivery_event" json:"eventId"`
+	Event   string `gorm:"type:varchar(64);not null" json:"event"`
+	Payload string `gorm:"type:text;not null" json:"payload"`
+	// WebhookPending, WebhookDelivered or WebhookDead
+	Status        string     `gorm:"type:varchar(16);not null;index:idx_webhook_delivery_due" json:"status"`
+	Attempts      int        `gorm:"not null" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_webhook_delivery_due" json:"nextAttemptAt"`
+	LastStatus    int        `json:"lastStatus,omitempty"`
+	LastError     string     `gorm:"type:varchar(1024)" json:"lastError,omitempty"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// OutboxEvent is written in the transaction of the change it records, and deleted once relayed to all sinks
+type OutboxEvent struct {
+	ID   uint64 `gorm:"primary_key"`
+	Type string `gorm:"type:varchar(64);not null"`
+	// of the changed entity, e.g. the order id
+	Key     string `gorm:"type:varchar(64);not null"`
+	Payload string `gorm:"type:text;not null"`
+	// comma separated sinks which accepted the event already, so they do not get it again
+	PublishedTo string `gorm:"type:varchar(255);not null;default:''"`
+	CreatedAt   time.Time
 }
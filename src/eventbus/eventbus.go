@@ -0,0 +1,140 @@
+// Package eventbus passes events between the handlers of this process, keeping the latest ones so subscribers can
+// resume where they left off
+package eventbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is identified by "<epoch>-<sequence>", the epoch telling the events of different processes apart
+type Event struct {
+	ID     string
+	Type   string
+	Status string
+	Data   []byte
+
+	seq uint64
+}
+
+// Bus fans published events out to the subscriptions. Subscribers too slow to keep up are dropped, they resume
+// from the kept events when subscribing again. A nil Bus drops every event, its subscriptions are closed.
+type Bus struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	history []Event
+	size    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// New returns a bus keeping the latest history events
+func New(history int) *Bus {
+	return &Bus{epoch: strconv.FormatInt(time.Now().UnixNano(), 36), size: history, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscription receives the events published after it was made
+type Subscription struct {
+	bus *Bus
+	c   chan Event
+}
+
+// Events is closed when the subscription was dropped or closed
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Close ends the subscription, it can be called more than once
+func (s *Subscription) Close() {
+	if s.bus == nil {
+		return
+	}
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+// Publish sends an event to every subscription
+func (b *Bus) Publish(typ string, status string, data []byte) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.seq++
+	e := Event{ID: fmt.Sprintf("%s-%d", b.epoch, b.seq), Type: typ, Status: status, Data: data, seq: b.seq}
+	if b.size > 0 {
+		if len(b.history) == b.size {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, e)
+	}
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe returns a subscription buffering up to buffer events, and the kept events published after lastID.
+// It is false when lastID is set but the events after it are not all kept, e.g. it is too old or from before a
+// restart, so the subscriber may have missed some.
+func (b *Bus) Subscribe(lastID string, buffer int) (*Subscription, []Event, bool) {
+	s := &Subscription{bus: b, c: make(chan Event, buffer)}
+	if b == nil {
+		close(s.c)
+		return s, nil, lastID == ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.c)
+	} else {
+		b.subs[s] = struct{}{}
+	}
+	if lastID == "" {
+		return s, nil, true
+	}
+
+	i := strings.LastIndex(lastID, "-")
+	seq, err := strconv.ParseUint(lastID[i+1:], 10, 64)
+	if i < 0 || err != nil || lastID[:i] != b.epoch || seq > b.seq || seq+uint64(len(b.history)) < b.seq {
+		return s, nil, false
+	}
+	var missed []Event
+	for _, e := range b.history {
+		if e.seq > seq {
+			missed = append(missed, e)
+		}
+	}
+	return s, missed, true
+}
+
+// Close ends every subscription, e.g. to let streaming requests finish on shutdown. Later events are dropped.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+func (b *Bus) drop(s *Subscription) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
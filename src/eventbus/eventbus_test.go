@@ -0,0 +1,104 @@
+package eventbus
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	b := New(10)
+	s, missed, ok := b.Subscribe("", 10)
+	defer s.Close()
+
+	b.Publish("order.created", "UNASSIGNED", []byte(`{"id":1}`))
+
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+	e := <-s.Events()
+	assert.Equal(t, "order.created", e.Type)
+	assert.Equal(t, "UNASSIGNED", e.Status)
+	assert.Equal(t, `{"id":1}`, string(e.Data))
+	assert.Equal(t, b.epoch+"-1", e.ID)
+}
+
+func TestResume(t *testing.T) {
+	b := New(3)
+	for i := 0; i < 5; i++ {
+		b.Publish("order.created", "UNASSIGNED", nil)
+	}
+
+	_, missed, ok := b.Subscribe(b.epoch+"-3", 10)
+	assert.True(t, ok)
+	assert.Equal(t, []string{b.epoch + "-4", b.epoch + "-5"}, ids(missed))
+
+	_, missed, ok = b.Subscribe(b.epoch+"-2", 10)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(missed))
+
+	_, missed, ok = b.Subscribe(b.epoch+"-5", 10)
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+}
+
+func TestResumeLost(t *testing.T) {
+	b := New(3)
+	for i := 0; i < 5; i++ {
+		b.Publish("order.created", "UNASSIGNED", nil)
+	}
+
+	for _, lastID := range []string{b.epoch + "-1", b.epoch + "-6", "other-3", "3", "x"} {
+		_, missed, ok := b.Subscribe(lastID, 10)
+		assert.False(t, ok, lastID)
+		assert.Empty(t, missed, lastID)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := New(10)
+	slow, _, _ := b.Subscribe("", 1)
+	fast, _, _ := b.Subscribe("", 10)
+
+	b.Publish("order.created", "UNASSIGNED", nil)
+	b.Publish("order.taken", "TAKEN", nil)
+
+	assert.Equal(t, "order.created", (<-slow.Events()).Type)
+	_, open := <-slow.Events()
+	assert.False(t, open)
+	assert.Equal(t, 2, len(fast.Events()))
+	slow.Close()
+}
+
+func TestClose(t *testing.T) {
+	b := New(10)
+	s, _, _ := b.Subscribe("", 10)
+
+	b.Close()
+	b.Publish("order.created", "UNASSIGNED", nil)
+
+	_, open := <-s.Events()
+	assert.False(t, open)
+	late, _, _ := b.Subscribe("", 10)
+	_, open = <-late.Events()
+	assert.False(t, open)
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish("order.created", "UNASSIGNED", nil)
+
+	s, missed, resumed := b.Subscribe("1-1", 10)
+	_, open := <-s.Events()
+	assert.False(t, open)
+	assert.Empty(t, missed)
+	assert.False(t, resumed)
+	s.Close()
+	b.Close()
+}
+
+func ids(events []Event) []string {
+	res := make([]string, len(events))
+	for i, e := range events {
+		res[i] = e.ID
+	}
+	return res
+}
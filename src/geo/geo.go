@@ -0,0 +1,112 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type Point struct {
+	Lat  float64
+	Long float64
+}
+
+const earthRadiusMeters = 6371008.8
+
+// Distance returns the great circle distance between a and b in meters
+func Distance(a Point, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLong := lat2-lat1, (b.Long-a.Long)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// ParsePoint reads a [latitude, longitude] pair as used by order requests
+func ParsePoint(p []string) (Point, error) {
+	if len(p) != 2 {
+		return Point{}, fmt.Errorf("expected [latitude, longitude], got %d values", len(p))
+	}
+	lat, err := strconv.ParseFloat(p[0], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid latitude %q", p[0])
+	}
+	long, err := strconv.ParseFloat(p[1], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid longitude %q", p[1])
+	}
+	return Point{Lat: lat, Long: long}, nil
+}
+
+// Geometry is a GeoJSON Polygon or MultiPolygon. Positions are [longitude, latitude], as GeoJSON has them.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+
+	polygons [][][][2]float64
+}
+
+func (g *Geometry) UnmarshalJSON(b []byte) error {
+	type geometry Geometry
+	if err := json.Unmarshal(b, (*geometry)(g)); err != nil {
+		return err
+	}
+	switch g.Type {
+	case "Polygon":
+		var p [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return fmt.Errorf("invalid Polygon coordinates: %v", err)
+		}
+		g.polygons = [][][][2]float64{p}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &g.polygons); err != nil {
+			return fmt.Errorf("invalid MultiPolygon coordinates: %v", err)
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %q, expected Polygon or MultiPolygon", g.Type)
+	}
+	for _, p := range g.polygons {
+		if len(p) == 0 {
+			return fmt.Errorf("polygon without rings")
+		}
+		for _, ring := range p {
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return fmt.Errorf("polygon rings must be closed and have at least 4 positions")
+			}
+		}
+	}
+	return nil
+}
+
+// Contains returns whether p lies inside the outer ring of any polygon, and not in one of its holes
+func (g *Geometry) Contains(p Point) bool {
+	for _, polygon := range g.polygons {
+		if !inRing(polygon[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if inRing(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ray casting, crossing edges to the east of p
+func inRing(ring [][2]float64, p Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > p.Lat) != (yj > p.Lat) && p.Long < (xj-xi)*(p.Lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,80 @@
+package geo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// central Hong Kong with a hole around the peak tram
+const polygon = `{"type": "Polygon", "coordinates": [
+	[[114.15, 22.27], [114.19, 22.27], [114.19, 22.29], [114.15, 22.29], [114.15, 22.27]],
+	[[114.16, 22.275], [114.165, 22.275], [114.165, 22.28], [114.16, 22.28], [114.16, 22.275]]]}`
+
+func TestContains(t *testing.T) {
+	var g Geometry
+	if err := json.Unmarshal([]byte(polygon), &g); err != nil {
+		t.Fatalf("Cannot parse polygon: %v", err)
+	}
+
+	r := map[Point]bool{
+		{Lat: 22.2802, Long: 114.184919}: true,
+		{Lat: 22.2775, Long: 114.1625}:   false, // hole
+		{Lat: 22.30, Long: 114.17}:       false,
+		{Lat: 22.28, Long: 114.20}:       false,
+	}
+	for k, v := range r {
+		if g.Contains(k) != v {
+			t.Errorf("Contains %v returns %v, expects %v", k, !v, v)
+		}
+	}
+}
+
+func TestMultiPolygon(t *testing.T) {
+	var g Geometry
+	err := json.Unmarshal([]byte(`{"type": "MultiPolygon", "coordinates": [
+		[[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]],
+		[[[10, 10], [11, 10], [11, 11], [10, 11], [10, 10]]]]}`), &g)
+	if err != nil {
+		t.Fatalf("Cannot parse multi polygon: %v", err)
+	}
+	if !g.Contains(Point{Lat: 10.5, Long: 10.5}) || g.Contains(Point{Lat: 5, Long: 5}) {
+		t.Errorf("Unexpected containment")
+	}
+}
+
+func TestInvalidGeometry(t *testing.T) {
+	for _, s := range []string{
+		`{"type": "Point", "coordinates": [1, 2]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1]]]}`, // not closed
+		`{"type": "Polygon", "coordinates": []}`,
+		`{"type": "Polygon", "coordinates": "a"}`,
+	} {
+		var g Geometry
+		if err := json.Unmarshal([]byte(s), &g); err == nil {
+			t.Errorf("Expected error for %s", s)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Central to Taipei 101 is about 810 km as the crow flies
+	d := Distance(Point{Lat: 22.2802, Long: 114.184919}, Point{Lat: 25.033964, Long: 121.564468})
+	if d < 805000 || d > 815000 {
+		t.Errorf("Unexpected distance %f", d)
+	}
+	if d := Distance(Point{Lat: 1, Long: 1}, Point{Lat: 1, Long: 1}); d != 0 {
+		t.Errorf("Expected 0, got %f", d)
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	p, err := ParsePoint([]string{"22.2802", "114.184919"})
+	if err != nil || p != (Point{Lat: 22.2802, Long: 114.184919}) {
+		t.Errorf("Unexpected point %v, err %v", p, err)
+	}
+	for _, s := range [][]string{{"1"}, {"a", "1"}, {"1", "b"}} {
+		if _, err := ParsePoint(s); err == nil {
+			t.Errorf("Expected error for %v", s)
+		}
+	}
+}
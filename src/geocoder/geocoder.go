@@ -0,0 +1,68 @@
+package geocoder
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+)
+
+const (
+	ProviderNone      = "none"
+	ProviderGoogle    = "google"
+	ProviderNominatim = "nominatim"
+	ProviderStub      = "stub"
+)
+
+// ErrNotFound is returned when the provider has no result for the query
+var ErrNotFound = errors.New("address not found")
+
+// Query is either a free-text Address or a provider specific PlaceID
+type Query struct {
+	Address string
+	PlaceID string
+}
+
+type Location struct {
+	Lat     float64
+	Long    float64
+	Address string
+}
+
+// LatLong returns the coordinates the way orders are placed, as decimal strings
+func (l *Location) LatLong() []string {
+	return []string{strconv.FormatFloat(l.Lat, 'f', -1, 64), strconv.FormatFloat(l.Long, 'f', -1, 64)}
+}
+
+type Geocoder interface {
+	Geocode(ctx context.Context, q Query) (*Location, error)
+}
+
+// ReverseGeocoder finds the formatted address of coordinates
+type ReverseGeocoder interface {
+	ReverseGeocode(ctx context.Context, lat float64, long float64) (*Location, error)
+}
+
+// Provider is implemented by every geocoder of this package
+type Provider interface {
+	Geocoder
+	ReverseGeocoder
+}
+
+// traced records the lookup of provider as a client span named op
+func traced(ctx context.Context, op string, provider string, lookup func(ctx context.Context) (*Location, error), attrs ...attribute.KeyValue) (*Location, error) {
+	ctx, span := otel.Tracer("geocoder").Start(ctx, "geocoder."+op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("geocoder.provider", provider))...))
+	defer span.End()
+
+	loc, err := lookup(ctx)
+	if err != nil && err != ErrNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(attribute.Bool("geocoder.found", loc != nil))
+	return loc, err
+}
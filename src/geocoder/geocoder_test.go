@@ -0,0 +1,181 @@
+package geocoder
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"googlemaps.github.io/maps"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type GoogleClientMock struct {
+	mock.Mock
+}
+
+func (m *GoogleClientMock) Geocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	args := m.Called(ctx, r)
+	return args.Get(0).([]maps.GeocodingResult), args.Error(1)
+}
+
+func (m *GoogleClientMock) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	args := m.Called(ctx, r)
+	return args.Get(0).([]maps.GeocodingResult), args.Error(1)
+}
+
+var googleResult = []maps.GeocodingResult{{FormattedAddress: "Central, Hong Kong",
+	Geometry: maps.AddressGeometry{Location: maps.LatLng{Lat: 22.2802, Lng: 114.184919}}}}
+
+func TestGoogleAddress(t *testing.T) {
+	c := &GoogleClientMock{}
+	c.On("Geocode", mock.Anything, &maps.GeocodingRequest{Address: "Central"}).Return(googleResult, nil)
+
+	l, err := (&Google{Client: c}).Geocode(context.Background(), Query{Address: "Central"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"22.2802", "114.184919"}, l.LatLong())
+	assert.Equal(t, "Central, Hong Kong", l.Address)
+}
+
+func TestGooglePlaceID(t *testing.T) {
+	c := &GoogleClientMock{}
+	c.On("ReverseGeocode", mock.Anything, &maps.GeocodingRequest{PlaceID: "abc"}).Return(googleResult, nil)
+
+	l, err := (&Google{Client: c}).Geocode(context.Background(), Query{PlaceID: "abc"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 22.2802, l.Lat)
+}
+
+func TestGoogleNotFound(t *testing.T) {
+	c := &GoogleClientMock{}
+	c.On("Geocode", mock.Anything, mock.Anything).Return([]maps.GeocodingResult{}, nil)
+	c.On("ReverseGeocode", mock.Anything, mock.Anything).Return([]maps.GeocodingResult(nil), errors.New("maps: NOT_FOUND - "))
+	g := &Google{Client: c}
+
+	_, err := g.Geocode(context.Background(), Query{Address: "nowhere"})
+	assert.Equal(t, ErrNotFound, err)
+	_, err = g.Geocode(context.Background(), Query{PlaceID: "unknown"})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestGoogleError(t *testing.T) {
+	c := &GoogleClientMock{}
+	c.On("Geocode", mock.Anything, mock.Anything).Return([]maps.GeocodingResult(nil), errors.New("maps: OVER_QUERY_LIMIT - "))
+
+	_, err := (&Google{Client: c}).Geocode(context.Background(), Query{Address: "Central"})
+
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+}
+
+func TestGoogleReverse(t *testing.T) {
+	c := &GoogleClientMock{}
+	c.On("ReverseGeocode", mock.Anything, &maps.GeocodingRequest{LatLng: &maps.LatLng{Lat: 22.2802, Lng: 114.184919}}).Return(googleResult, nil)
+	c.On("ReverseGeocode", mock.Anything, mock.Anything).Return([]maps.GeocodingResult{}, nil)
+	g := &Google{Client: c}
+
+	l, err := g.ReverseGeocode(context.Background(), 22.2802, 114.184919)
+	assert.Nil(t, err)
+	assert.Equal(t, "Central, Hong Kong", l.Address)
+
+	_, err = g.ReverseGeocode(context.Background(), 0, 0)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestNominatimReverse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/reverse", r.URL.Path)
+		if r.URL.Query().Get("lat") == "22.2802" && r.URL.Query().Get("lon") == "114.184919" {
+			_, _ = w.Write([]byte(`{"lat": "22.2802", "lon": "114.184919", "display_name": "Central, Hong Kong"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"error": "Unable to geocode"}`))
+	}))
+	defer srv.Close()
+	n := NewNominatim(srv.URL, "")
+
+	l, err := n.ReverseGeocode(context.Background(), 22.2802, 114.184919)
+	assert.Nil(t, err)
+	assert.Equal(t, "Central, Hong Kong", l.Address)
+
+	_, err = n.ReverseGeocode(context.Background(), 0, 0)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestNominatim(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+"?"+r.URL.RawQuery)
+		assert.Equal(t, "llmc-test", r.Header.Get("User-Agent"))
+		switch r.URL.Query().Get("q") + r.URL.Query().Get("osm_ids") {
+		case "Central", "N1":
+			_, _ = w.Write([]byte(`[{"lat": "22.2802", "lon": "114.184919", "display_name": "Central, Hong Kong"}]`))
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	n := NewNominatim(srv.URL+"/", "llmc-test")
+
+	l, err := n.Geocode(context.Background(), Query{Address: "Central"})
+	assert.Nil(t, err)
+	assert.Equal(t, &Location{Lat: 22.2802, Long: 114.184919, Address: "Central, Hong Kong"}, l)
+
+	l, err = n.Geocode(context.Background(), Query{PlaceID: "N1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 114.184919, l.Long)
+
+	_, err = n.Geocode(context.Background(), Query{Address: "nowhere"})
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = n.Geocode(context.Background(), Query{Address: "broken"})
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrNotFound, err)
+
+	assert.Equal(t, "/search?format=jsonv2&limit=1&q=Central", paths[0])
+	assert.Equal(t, "/lookup?format=jsonv2&osm_ids=N1", paths[1])
+}
+
+func TestLoadStub(t *testing.T) {
+	f, _ := ioutil.TempFile("", "places*.json")
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString(`{"1 Main St": {"lat": 1.5, "long": 2}, "place:abc": {"lat": 3, "long": 4, "address": "Somewhere"}}`)
+	_ = f.Close()
+
+	s, err := LoadStub(f.Name())
+	assert.Nil(t, err)
+
+	l, err := s.Geocode(context.Background(), Query{Address: " 1 main st"})
+	assert.Nil(t, err)
+	assert.Equal(t, &Location{Lat: 1.5, Long: 2, Address: " 1 main st"}, l)
+
+	l, err = s.Geocode(context.Background(), Query{PlaceID: "abc"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Somewhere", l.Address)
+
+	_, err = s.Geocode(context.Background(), Query{Address: "abc"})
+	assert.Equal(t, ErrNotFound, err)
+
+	l, err = s.ReverseGeocode(context.Background(), 3, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, "Somewhere", l.Address)
+
+	l, err = s.ReverseGeocode(context.Background(), 1.5, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "1 main st", l.Address)
+
+	_, err = s.ReverseGeocode(context.Background(), 1, 1)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestLoadStubMissing(t *testing.T) {
+	_, err := LoadStub("/nonexistent/places.json")
+	assert.NotNil(t, err)
+}
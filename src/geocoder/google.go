@@ -0,0 +1,65 @@
+package geocoder
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"googlemaps.github.io/maps"
+	"strings"
+)
+
+type GoogleClient interface {
+	Geocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error)
+	ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error)
+}
+
+// Google resolves addresses with the Geocoding API, and place ids by reverse geocoding them
+type Google struct {
+	Client GoogleClient
+}
+
+func NewGoogle(apiKey string) (*Google, error) {
+	c, err := maps.NewClient(maps.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return &Google{Client: c}, nil
+}
+
+func (g *Google) Geocode(ctx context.Context, q Query) (*Location, error) {
+	return traced(ctx, "Geocode", ProviderGoogle, func(ctx context.Context) (*Location, error) {
+		var res []maps.GeocodingResult
+		var err error
+		if q.PlaceID != "" {
+			res, err = g.Client.ReverseGeocode(ctx, &maps.GeocodingRequest{PlaceID: q.PlaceID})
+		} else {
+			res, err = g.Client.Geocode(ctx, &maps.GeocodingRequest{Address: q.Address})
+		}
+		if err != nil {
+			// unknown place ids are reported as an error status
+			if strings.HasPrefix(err.Error(), "maps: NOT_FOUND") {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		return googleLocation(res)
+	}, attribute.Bool("geocoder.place_id", q.PlaceID != ""))
+}
+
+func (g *Google) ReverseGeocode(ctx context.Context, lat float64, long float64) (*Location, error) {
+	return traced(ctx, "ReverseGeocode", ProviderGoogle, func(ctx context.Context) (*Location, error) {
+		res, err := g.Client.ReverseGeocode(ctx, &maps.GeocodingRequest{LatLng: &maps.LatLng{Lat: lat, Lng: long}})
+		if err != nil {
+			return nil, err
+		}
+		return googleLocation(res)
+	})
+}
+
+// the first result is the most specific one
+func googleLocation(res []maps.GeocodingResult) (*Location, error) {
+	if len(res) == 0 {
+		return nil, ErrNotFound
+	}
+	l := res[0].Geometry.Location
+	return &Location{Lat: l.Lat, Long: l.Lng, Address: res[0].FormattedAddress}, nil
+}
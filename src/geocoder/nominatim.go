@@ -0,0 +1,113 @@
+package geocoder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// Nominatim resolves addresses with the /search endpoint of a Nominatim compatible server. Place ids are OSM ids
+// such as "N240109189", resolved with /lookup.
+type Nominatim struct {
+	BaseURL string
+	// sent as User-Agent, the public server requires one identifying the application
+	UserAgent string
+	Client    *http.Client
+}
+
+func NewNominatim(baseURL string, userAgent string) *Nominatim {
+	return &Nominatim{BaseURL: strings.TrimSuffix(baseURL, "/"), UserAgent: userAgent, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type nominatimPlace struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+}
+
+func (n *Nominatim) Geocode(ctx context.Context, q Query) (*Location, error) {
+	return traced(ctx, "Geocode", ProviderNominatim, func(ctx context.Context) (*Location, error) {
+		params := url.Values{"format": {"jsonv2"}}
+		endpoint := "/search"
+		if q.PlaceID != "" {
+			endpoint = "/lookup"
+			params.Set("osm_ids", q.PlaceID)
+		} else {
+			params.Set("q", q.Address)
+			params.Set("limit", "1")
+		}
+
+		var places []nominatimPlace
+		if err := n.get(ctx, endpoint, params, &places); err != nil {
+			return nil, err
+		}
+		if len(places) == 0 {
+			return nil, ErrNotFound
+		}
+		return places[0].location()
+	}, attribute.Bool("geocoder.place_id", q.PlaceID != ""))
+}
+
+func (n *Nominatim) ReverseGeocode(ctx context.Context, lat float64, long float64) (*Location, error) {
+	return traced(ctx, "ReverseGeocode", ProviderNominatim, func(ctx context.Context) (*Location, error) {
+		params := url.Values{"format": {"jsonv2"},
+			"lat": {strconv.FormatFloat(lat, 'f', -1, 64)}, "lon": {strconv.FormatFloat(long, 'f', -1, 64)}}
+
+		// a point without address is answered with {"error": "Unable to geocode"}
+		var place struct {
+			nominatimPlace
+			Error string `json:"error"`
+		}
+		if err := n.get(ctx, "/reverse", params, &place); err != nil {
+			return nil, err
+		}
+		if place.Error != "" {
+			return nil, ErrNotFound
+		}
+		return place.location()
+	})
+}
+
+func (n *Nominatim) get(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, n.BaseURL+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if n.UserAgent != "" {
+		req.Header.Set("User-Agent", n.UserAgent)
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nominatim: unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("nominatim: invalid response: %v", err)
+	}
+	return nil
+}
+
+func (p *nominatimPlace) location() (*Location, error) {
+	lat, err := strconv.ParseFloat(p.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim: invalid latitude %q", p.Lat)
+	}
+	long, err := strconv.ParseFloat(p.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim: invalid longitude %q", p.Lon)
+	}
+	return &Location{Lat: lat, Long: long, Address: p.DisplayName}, nil
+}
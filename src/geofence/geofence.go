@@ -0,0 +1,184 @@
+package geofence
+
+import (
+	"encoding/json"
+	"fmt"
+	"geo"
+	"io"
+	"os"
+	"sync"
+)
+
+const maxNameLength = 64
+
+// Zone is a named service area
+type Zone struct {
+	Name string
+	Area *geo.Geometry
+}
+
+// Zones are the service areas orders are accepted in. Without zones, orders are accepted anywhere.
+// Safe for concurrent use.
+type Zones struct {
+	mu    sync.RWMutex
+	zones []Zone
+}
+
+// Locate returns the name of the first zone containing p, and false when it is outside every zone.
+// Without zones, every point is inside. A nil Zones has no zones.
+func (z *Zones) Locate(p geo.Point) (string, bool) {
+	if z == nil {
+		return "", true
+	}
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	if len(z.zones) == 0 {
+		return "", true
+	}
+	for _, zone := range z.zones {
+		if zone.Area.Contains(p) {
+			return zone.Name, true
+		}
+	}
+	return "", false
+}
+
+func (z *Zones) List() []Zone {
+	if z == nil {
+		return nil
+	}
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return append([]Zone(nil), z.zones...)
+}
+
+// Replace swaps in zones as returned by Parse or Load
+func (z *Zones) Replace(zones []Zone) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.zones = append([]Zone(nil), zones...)
+}
+
+// FeatureCollection is the GeoJSON form of zones, each a Feature with a "name" property
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   *geo.Geometry          `json:"geometry"`
+}
+
+// FieldError locates a problem of a feature collection, e.g. features[1].properties.name
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Parse reads zones from a GeoJSON feature collection. A collection which is JSON but no valid set of zones
+// is reported as FieldErrors.
+func Parse(in io.Reader) ([]Zone, []FieldError, error) {
+	var raw struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.NewDecoder(in).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	var errs []FieldError
+	if raw.Type != "FeatureCollection" {
+		errs = append(errs, FieldError{"type", "must be FeatureCollection"})
+	}
+
+	var zones []Zone
+	names := map[string]bool{}
+	for i, b := range raw.Features {
+		path := fmt.Sprintf("features[%d]", i)
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(b, &fields); err != nil || fields == nil {
+			errs = append(errs, FieldError{path, "must be a Feature object"})
+			continue
+		}
+		var typ string
+		if json.Unmarshal(fields["type"], &typ) != nil || typ != "Feature" {
+			errs = append(errs, FieldError{path + ".type", "must be Feature"})
+		}
+		area, areaErr := parseGeometry(path+".geometry", fields["geometry"])
+		if areaErr != nil {
+			errs = append(errs, *areaErr)
+		}
+		var properties map[string]interface{}
+		if raw, ok := fields["properties"]; ok && json.Unmarshal(raw, &properties) != nil {
+			errs = append(errs, FieldError{path + ".properties", "must be an object"})
+		}
+		name, _ := properties["name"].(string)
+		switch {
+		case name == "":
+			errs = append(errs, FieldError{path + ".properties.name", "is required"})
+		case len(name) > maxNameLength:
+			errs = append(errs, FieldError{path + ".properties.name", fmt.Sprintf("must be at most %d characters", maxNameLength)})
+		case names[name]:
+			errs = append(errs, FieldError{path + ".properties.name", fmt.Sprintf("%q is used twice", name)})
+		}
+		names[name] = true
+		zones = append(zones, Zone{Name: name, Area: area})
+	}
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+	return zones, nil, nil
+}
+
+// parseGeometry reads the geometry at path, which is required
+func parseGeometry(path string, raw json.RawMessage) (*geo.Geometry, *FieldError) {
+	var typ struct {
+		Type string `json:"type"`
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, &FieldError{path, "is required"}
+	}
+	if json.Unmarshal(raw, &typ) != nil {
+		return nil, &FieldError{path, "must be a geometry object"}
+	}
+	if typ.Type != "Polygon" && typ.Type != "MultiPolygon" {
+		return nil, &FieldError{path + ".type", "must be Polygon or MultiPolygon"}
+	}
+	var g geo.Geometry
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return nil, &FieldError{path + ".coordinates", "must be polygons of closed rings with at least 4 [longitude, latitude] positions"}
+	}
+	return &g, nil
+}
+
+// Load reads zones from a GeoJSON file
+func Load(path string) ([]Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zones, errs, err := Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &errs[0]
+	}
+	return zones, nil
+}
+
+// Collection returns the GeoJSON form of zones
+func Collection(zones []Zone) *FeatureCollection {
+	fc := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, z := range zones {
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Properties: map[string]interface{}{"name": z.Name}, Geometry: z.Area})
+	}
+	return fc
+}
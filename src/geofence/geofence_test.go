@@ -0,0 +1,115 @@
+package geofence
+
+import (
+	"encoding/json"
+	"geo"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const collection = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"name": "central"}, "geometry": {"type": "Polygon", "coordinates": [[[114.15, 22.27], [114.19, 22.27], [114.19, 22.29], [114.15, 22.29], [114.15, 22.27]]]}},
+	{"type": "Feature", "properties": {"name": "island"}, "geometry": {"type": "Polygon", "coordinates": [[[114.1, 22.2], [114.3, 22.2], [114.3, 22.3], [114.1, 22.3], [114.1, 22.2]]]}}
+]}`
+
+func TestLocate(t *testing.T) {
+	zones, errs, err := Parse(strings.NewReader(collection))
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("Cannot parse zones: %v %v", err, errs)
+	}
+	z := &Zones{}
+	z.Replace(zones)
+
+	r := map[geo.Point]string{
+		{Lat: 22.2802, Long: 114.184919}: "central", // first zone wins
+		{Lat: 22.25, Long: 114.25}:       "island",
+		{Lat: 22.40, Long: 114.10}:       "",
+	}
+	for k, v := range r {
+		name, ok := z.Locate(k)
+		if name != v || ok != (v != "") {
+			t.Errorf("Locate %v returns %q %v, expects %q", k, name, ok, v)
+		}
+	}
+}
+
+func TestLocateWithoutZones(t *testing.T) {
+	var nilZones *Zones
+	for _, z := range []*Zones{nilZones, {}} {
+		if name, ok := z.Locate(geo.Point{Lat: 1, Long: 1}); name != "" || !ok {
+			t.Errorf("Expected every point to be inside without zones")
+		}
+	}
+	if nilZones.List() != nil {
+		t.Errorf("Expected no zones")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, errs, err := Parse(strings.NewReader(`{"type": "Feature", "features": [
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}},
+		{"type": "Feature", "properties": {"name": "a"}},
+		{"type": "Feature", "properties": {"name": "a"}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}},
+		{"type": "Feature", "properties": {"name": "b"}, "geometry": {"type": "Point", "coordinates": [0, 0]}},
+		{"type": "Feature", "properties": {"name": "c"}, "geometry": {"type": "Polygon", "coordinates": [[["0", "0"]]]}},
+		{"type": 1, "properties": [], "geometry": "Polygon"},
+		[]
+	]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	expected := "type features[0].properties.name features[1].geometry features[2].properties.name features[3].geometry.type " +
+		"features[4].geometry.coordinates features[5].type features[5].geometry features[5].properties features[5].properties.name features[6]"
+	if strings.Join(fields, " ") != expected {
+		t.Errorf("Expected errors of %s, got %v", expected, errs)
+	}
+	// the decoder's errors are not passed on
+	for _, e := range errs {
+		if strings.Contains(e.Message, "json") || strings.Contains(e.Message, "unmarshal") {
+			t.Errorf("Unexpected message %q of %s", e.Message, e.Field)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	if _, _, err := Parse(strings.NewReader(`{"type": `)); err == nil {
+		t.Errorf("Expected error")
+	}
+}
+
+func TestLoadAndCollection(t *testing.T) {
+	f, _ := ioutil.TempFile("", "zones*.json")
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString(collection)
+	_ = f.Close()
+
+	zones, err := Load(f.Name())
+	if err != nil || len(zones) != 2 {
+		t.Fatalf("Cannot load zones: %v", err)
+	}
+
+	// the collection reads back as the same zones
+	b, _ := json.Marshal(Collection(zones))
+	again, errs, err := Parse(strings.NewReader(string(b)))
+	if err != nil || len(errs) > 0 || len(again) != 2 || again[1].Name != "island" || !again[1].Area.Contains(geo.Point{Lat: 22.25, Long: 114.25}) {
+		t.Errorf("Collection %s does not read back: %v %v", b, err, errs)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	f, _ := ioutil.TempFile("", "zones*.json")
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString(`{"type": "FeatureCollection", "features": [{"type": "Feature"}]}`)
+	_ = f.Close()
+
+	if _, err := Load(f.Name()); err == nil {
+		t.Errorf("Expected error")
+	}
+}
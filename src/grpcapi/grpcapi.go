@@ -0,0 +1,263 @@
+// Package grpcapi serves the order endpoints over gRPC by calling the REST routes in process, so both APIs share the
+// validation, authentication, rate limits, idempotency and error codes
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"entity"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"io"
+	"net/http"
+	"net/url"
+	"orderpb"
+	"responseutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorDomain is the domain of the ErrorInfo details, whose reason is the problem code of the REST API
+const ErrorDomain = "llmc"
+
+// forwardedMetadata are passed on as the request headers of the same name
+var forwardedMetadata = []string{"authorization", "x-api-key", "idempotency-key", "x-request-id"}
+
+// Server implements the OrderService on top of Handler, which serves the REST routes
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+	Handler http.Handler
+}
+
+// NewServer returns a gRPC server with the OrderService on h and reflection enabled
+func NewServer(h http.Handler) *grpc.Server {
+	s := grpc.NewServer()
+	orderpb.RegisterOrderServiceServer(s, &Server{Handler: h})
+	reflection.Register(s)
+	return s
+}
+
+func (s *Server) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error) {
+	body := map[string]interface{}{}
+	if p := point(req.GetOrigin()); p != nil {
+		body["origin"] = p
+	}
+	if p := point(req.GetDestination()); p != nil {
+		body["destination"] = p
+	}
+	var order entity.Order
+	if err := s.call(ctx, http.MethodPost, "/orders", nil, body, &order); err != nil {
+		return nil, err
+	}
+	return toOrder(&order), nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	var order entity.Order
+	if err := s.call(ctx, http.MethodGet, orderPath(req.GetId()), nil, nil, &order); err != nil {
+		return nil, err
+	}
+	return toOrder(&order), nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	q := url.Values{}
+	if req.GetPage() != 0 {
+		q.Set("page", strconv.Itoa(int(req.GetPage())))
+	}
+	if req.GetLimit() != 0 {
+		q.Set("limit", strconv.Itoa(int(req.GetLimit())))
+	}
+	var orders []entity.Order
+	if err := s.call(ctx, http.MethodGet, "/orders?"+q.Encode(), nil, nil, &orders); err != nil {
+		return nil, err
+	}
+	res := &orderpb.ListOrdersResponse{Orders: make([]*orderpb.Order, len(orders))}
+	for i := range orders {
+		res.Orders[i] = toOrder(&orders[i])
+	}
+	return res, nil
+}
+
+// TakeOrder returns the order as taken, which PATCH /orders/:id does not answer with
+func (s *Server) TakeOrder(ctx context.Context, req *orderpb.TakeOrderRequest) (*orderpb.Order, error) {
+	header := http.Header{}
+	if req.GetVersion() != 0 {
+		header.Set("If-Match", fmt.Sprintf(`"%d"`, req.GetVersion()))
+	}
+	if err := s.call(ctx, http.MethodPatch, orderPath(req.GetId()), header, map[string]string{"status": "TAKEN"}, nil); err != nil {
+		return nil, err
+	}
+	return s.GetOrder(ctx, &orderpb.GetOrderRequest{Id: req.GetId()})
+}
+
+func (s *Server) WatchOrders(req *orderpb.WatchOrdersRequest, stream orderpb.OrderService_WatchOrdersServer) error {
+	q := url.Values{}
+	if len(req.GetStatuses()) > 0 {
+		q.Set("status", strings.Join(req.GetStatuses(), ","))
+	}
+	if req.GetLastEventId() != "" {
+		q.Set("lastEventId", req.GetLastEventId())
+	}
+	r, err := newRequest(stream.Context(), http.MethodGet, "/orders/stream?"+q.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	w := &eventWriter{header: http.Header{}, send: stream.Send}
+	s.Handler.ServeHTTP(w, r)
+	if w.status != http.StatusOK {
+		return problemError(w.status, w.buf.Bytes())
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return stream.Context().Err()
+}
+
+// call serves method and target with the JSON of body, decoding the response into out unless nil. Responses other
+// than 2xx are returned as the status error of the problem.
+func (s *Server) call(ctx context.Context, method string, target string, header http.Header, body interface{}, out interface{}) error {
+	r, err := newRequest(ctx, method, target, header, body)
+	if err != nil {
+		return err
+	}
+	w := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+	s.Handler.ServeHTTP(w, r)
+	if w.status < 200 || w.status > 299 {
+		return problemError(w.status, w.body.Bytes())
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(w.body.Bytes(), out); err != nil {
+		return status.Errorf(codes.Internal, "cannot decode response: %v", err)
+	}
+	return nil
+}
+
+// newRequest builds the REST request of a call, with the forwarded metadata of ctx as headers and the peer as
+// remote address for the rate limits
+func newRequest(ctx context.Context, method string, target string, header http.Header, body interface{}) (*http.Request, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "cannot encode request: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, name := range forwardedMetadata {
+		for _, v := range md.Get(name) {
+			r.Header.Add(name, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r, nil
+}
+
+// problemError converts a problem response into a status error, with the problem code as ErrorInfo reason and the
+// field errors as BadRequest details
+func problemError(code int, body []byte) error {
+	var p responseutil.Problem
+	if err := json.Unmarshal(body, &p); err != nil || p.Code == "" {
+		return status.Error(grpcCode(code), http.StatusText(code))
+	}
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+	st := status.New(grpcCode(code), msg)
+	info := &errdetails.ErrorInfo{Reason: p.Code, Domain: ErrorDomain}
+	if p.RequestID != "" {
+		info.Metadata = map[string]string{"requestId": p.RequestID}
+	}
+	details := []protoadapt.MessageV1{info}
+	if len(p.Errors) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, e := range p.Errors {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: e.Field, Description: e.Detail, Reason: e.Code})
+		}
+		details = append(details, br)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcCode maps the HTTP status of a problem to the closest gRPC code
+func grpcCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+func orderPath(id uint64) string {
+	return "/orders/" + strconv.FormatUint(id, 10)
+}
+
+// point is the REST form of p, nil when it is not set
+func point(p *orderpb.Point) interface{} {
+	switch {
+	case p.GetCoordinates() != nil:
+		return []string{p.GetCoordinates().GetLat(), p.GetCoordinates().GetLng()}
+	case p.GetAddress() != "":
+		return map[string]string{"address": p.GetAddress()}
+	case p.GetPlaceId() != "":
+		return map[string]string{"placeId": p.GetPlaceId()}
+	}
+	return nil
+}
+
+func toOrder(o *entity.Order) *orderpb.Order {
+	res := &orderpb.Order{Id: o.ID, Distance: int32(o.Distance), Status: o.Status, Version: o.Version, CourierId: o.CourierID,
+		CreatedBy: o.CreatedBy, Duration: int32(o.Duration), Price: o.Price, Currency: o.Currency,
+		OriginAddress: o.OriginAddress, OriginPlaceId: o.OriginPlaceID, DestinationAddress: o.DestAddress, DestinationPlaceId: o.DestPlaceID,
+		OriginFormattedAddress: o.OriginFormattedAddress, DestinationFormattedAddress: o.DestFormattedAddress, Zone: o.Zone}
+	if l := o.CourierLocation; l != nil {
+		res.CourierLocation = &orderpb.Location{Lat: l.Lat, Lng: l.Long, Accuracy: l.Accuracy, LocatedAt: l.LocatedAt.Format(time.RFC3339Nano)}
+	}
+	return res
+}
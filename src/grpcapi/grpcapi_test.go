@@ -0,0 +1,185 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"net/http"
+	"orderpb"
+	"responseutil"
+	"testing"
+)
+
+// newClient serves the OrderService on router in memory
+func newClient(t *testing.T, router *httprouter.Router) orderpb.OrderServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer(router)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return orderpb.NewOrderServiceClient(conn)
+}
+
+func TestCreateOrder(t *testing.T) {
+	router := httprouter.New()
+	router.POST("/orders", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"origin": ["22.2802", "114.184919"], "destination": {"address": "1 Queen's Road"}}`, string(body))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "key-1", r.Header.Get("Idempotency-Key"))
+		_, _ = io.WriteString(w, `{"id": 1, "distance": 73, "status": "UNASSIGNED", "version": 1, "destinationAddress": "1 Queen's Road"}`)
+	})
+	client := newClient(t, router)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token", "idempotency-key", "key-1")
+
+	order, err := client.CreateOrder(ctx, &orderpb.CreateOrderRequest{
+		Origin:      &orderpb.Point{Point: &orderpb.Point_Coordinates{Coordinates: &orderpb.Coordinates{Lat: "22.2802", Lng: "114.184919"}}},
+		Destination: &orderpb.Point{Point: &orderpb.Point_Address{Address: "1 Queen's Road"}}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), order.GetId())
+	assert.Equal(t, int32(73), order.GetDistance())
+	assert.Equal(t, "UNASSIGNED", order.GetStatus())
+	assert.Equal(t, "1 Queen's Road", order.GetDestinationAddress())
+}
+
+func TestCreateOrderProblem(t *testing.T) {
+	router := httprouter.New()
+	router.POST("/orders", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, "Invalid order request",
+			responseutil.FieldError{Field: "origin", Code: "required", Detail: "is required"}))
+	})
+	client := newClient(t, router)
+
+	_, err := client.CreateOrder(context.Background(), &orderpb.CreateOrderRequest{})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "Invalid order request", st.Message())
+	var reason string
+	var fields []string
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			reason = d.GetReason()
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				fields = append(fields, v.GetField()+" "+v.GetReason())
+			}
+		}
+	}
+	assert.Equal(t, responseutil.CodeValidationFailed, reason)
+	assert.Equal(t, []string{"origin required"}, fields)
+}
+
+func TestGetOrderErrorCodes(t *testing.T) {
+	for httpStatus, code := range map[int]codes.Code{http.StatusUnauthorized: codes.Unauthenticated, http.StatusForbidden: codes.PermissionDenied,
+		http.StatusNotFound: codes.NotFound, http.StatusTooManyRequests: codes.ResourceExhausted, http.StatusServiceUnavailable: codes.Unavailable} {
+		router := httprouter.New()
+		router.GET("/orders/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			responseutil.WriteError(w, r, httpStatus, "some_code", "failed")
+		})
+		client := newClient(t, router)
+
+		_, err := client.GetOrder(context.Background(), &orderpb.GetOrderRequest{Id: 1})
+
+		assert.Equal(t, code, status.Code(err), "for %d", httpStatus)
+	}
+}
+
+func TestTakeOrder(t *testing.T) {
+	router := httprouter.New()
+	router.PATCH("/orders/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		assert.Equal(t, "7", ps.ByName("id"))
+		assert.Equal(t, `"2"`, r.Header.Get("If-Match"))
+		_, _ = io.WriteString(w, `{"Status": "SUCCESS"}`)
+	})
+	router.GET("/orders/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		_, _ = fmt.Fprintf(w, `{"id": %s, "status": "TAKEN", "version": 3, "courierId": "c1"}`, ps.ByName("id"))
+	})
+	client := newClient(t, router)
+
+	order, err := client.TakeOrder(context.Background(), &orderpb.TakeOrderRequest{Id: 7, Version: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "TAKEN", order.GetStatus())
+	assert.Equal(t, "c1", order.GetCourierId())
+	assert.Equal(t, uint64(3), order.GetVersion())
+}
+
+func TestListOrders(t *testing.T) {
+	router := httprouter.New()
+	router.GET("/orders", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		assert.Equal(t, "2", r.URL.Query().Get("page"))
+		assert.Equal(t, "10", r.URL.Query().Get("limit"))
+		_, _ = io.WriteString(w, `[{"id": 1}, {"id": 2}]`)
+	})
+	client := newClient(t, router)
+
+	res, err := client.ListOrders(context.Background(), &orderpb.ListOrdersRequest{Page: 2, Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(res.GetOrders()))
+	assert.Equal(t, uint64(2), res.GetOrders()[1].GetId())
+}
+
+func TestWatchOrders(t *testing.T) {
+	router := httprouter.New()
+	router.GET("/orders/stream", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		assert.Equal(t, "UNASSIGNED", r.URL.Query().Get("status"))
+		assert.Equal(t, "e-1", r.URL.Query().Get("lastEventId"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "retry: 3000\n\nevent: reset\ndata: {}\n\n")
+		order, _ := json.Marshal(map[string]interface{}{"id": 5, "status": "UNASSIGNED"})
+		// written in pieces like a slow stream
+		_, _ = fmt.Fprintf(w, "id: e-2\nevent: order.created\n")
+		_, _ = fmt.Fprintf(w, "data: %s\n\n: heartbeat\n\n", order)
+	})
+	client := newClient(t, router)
+
+	stream, err := client.WatchOrders(context.Background(), &orderpb.WatchOrdersRequest{Statuses: []string{"UNASSIGNED"}, LastEventId: "e-1"})
+	assert.Nil(t, err)
+
+	ev, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "reset", ev.GetType())
+	assert.Nil(t, ev.GetOrder())
+	ev, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "e-2", ev.GetId())
+	assert.Equal(t, "order.created", ev.GetType())
+	assert.Equal(t, uint64(5), ev.GetOrder().GetId())
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWatchOrdersProblem(t *testing.T) {
+	router := httprouter.New()
+	router.GET("/orders/stream", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		responseutil.WriteError(w, r, http.StatusForbidden, responseutil.CodeForbidden, "Requires role dispatcher")
+	})
+	client := newClient(t, router)
+
+	stream, err := client.WatchOrders(context.Background(), &orderpb.WatchOrdersRequest{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,107 @@
+package grpcapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"entity"
+	"net/http"
+	"orderpb"
+	"strings"
+)
+
+// responseRecorder keeps the response of a unary call
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// streamReset is sent when the events after the last event id are not all kept
+const streamReset = "reset"
+
+// eventWriter sends the Server-Sent Events of the order stream as they are written. Other responses are kept in buf.
+type eventWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+	send   func(*orderpb.OrderEvent) error
+	// of send, ending the stream
+	err error
+}
+
+func (w *eventWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *eventWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *eventWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf.Write(b)
+	if w.status != http.StatusOK {
+		return len(b), nil
+	}
+	for {
+		i := bytes.Index(w.buf.Bytes(), []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		frame := string(w.buf.Next(i + 2))
+		if w.err = w.sendFrame(frame); w.err != nil {
+			return 0, w.err
+		}
+	}
+	return len(b), nil
+}
+
+// FlushError lets the stream handler stop once sending failed
+func (w *eventWriter) FlushError() error {
+	return w.err
+}
+
+// sendFrame sends one event, skipping the retry field and comments
+func (w *eventWriter) sendFrame(frame string) error {
+	var ev orderpb.OrderEvent
+	var data string
+	for _, line := range strings.Split(strings.TrimRight(frame, "\n"), "\n") {
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			ev.Id = value
+		case "event":
+			ev.Type = value
+		case "data":
+			data = value
+		}
+	}
+	if ev.Type == "" {
+		return nil
+	}
+	if ev.Type != streamReset {
+		var order entity.Order
+		if err := json.Unmarshal([]byte(data), &order); err != nil {
+			return err
+		}
+		ev.Order = toOrder(&order)
+	}
+	return w.send(&ev)
+}
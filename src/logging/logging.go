@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"responseutil"
+	"time"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	requestIDMaxLength = 128
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// Middleware assigns a request id, or propagates the one sent by the client, and logs each request once completed.
+// Handlers get a logger carrying the request id through FromContext.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		entry := log.WithField("request_id", id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		ctx = context.WithValue(ctx, loggerKey, entry)
+
+		sw := responseutil.NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		entry.WithFields(log.Fields{
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"status":     sw.Status,
+			"latency_ms": float64(time.Since(start)) / float64(time.Millisecond),
+			"bytes":      sw.Bytes,
+		}).Info("request completed")
+	})
+}
+
+// FromContext returns the request scoped logger, or the standard logger outside of a request
+func FromContext(ctx context.Context) *log.Entry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(loggerKey).(*log.Entry); ok {
+			return entry
+		}
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// RequestID returns the id assigned by Middleware, or empty string outside of a request
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// only accept printable ascii ids of limited length, so clients cannot inject into the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewarePropagatesRequestID(t *testing.T) {
+	id := testMiddleware(t, "abc-123")
+
+	if id != "abc-123" {
+		t.Errorf("Expected request id abc-123, got %s", id)
+	}
+}
+
+func TestMiddlewareAssignsRequestID(t *testing.T) {
+	r := map[string]string{
+		"missing":   "",
+		"too long":  strings.Repeat("a", requestIDMaxLength+1),
+		"non ascii": "abc\ndef",
+	}
+
+	for k, v := range r {
+		id := testMiddleware(t, v)
+		if id == "" || id == v {
+			t.Errorf("Expected new request id for %s id, got %q", k, id)
+		}
+	}
+}
+
+func TestFromContextOutsideRequest(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	if FromContext(r.Context()) == nil {
+		t.Errorf("Expected standard logger")
+	}
+	if RequestID(r.Context()) != "" {
+		t.Errorf("Expected no request id")
+	}
+}
+
+// returns the request id seen by the handler, after checking it matches the response header
+func testMiddleware(t *testing.T, id string) string {
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		if FromContext(r.Context()).Data["request_id"] != seen {
+			t.Errorf("Expected logger with request id %s, got %v", seen, FromContext(r.Context()).Data)
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	r, _ := http.NewRequest("GET", "/orders", nil)
+	if id != "" {
+		r.Header.Set(RequestIDHeader, id)
+	}
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	if w.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("Expected response header %s, got %s", seen, w.Header().Get(RequestIDHeader))
+	}
+	return seen
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+const startTimeKey = "metrics:start_time"
+
+// RegisterGormCallbacks records the latency of every query made through db
+func RegisterGormCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	cb.Create().Before("gorm:begin_transaction").Register("metrics:before_create", before)
+	cb.Create().After("gorm:commit_or_rollback_transaction").Register("metrics:after_create", after("create"))
+	cb.Query().Before("gorm:query").Register("metrics:before_query", before)
+	cb.Query().After("gorm:after_query").Register("metrics:after_query", after("query"))
+	cb.Update().Before("gorm:begin_transaction").Register("metrics:before_update", before)
+	cb.Update().After("gorm:commit_or_rollback_transaction").Register("metrics:after_update", after("update"))
+	cb.Delete().Before("gorm:begin_transaction").Register("metrics:before_delete", before)
+	cb.Delete().After("gorm:commit_or_rollback_transaction").Register("metrics:after_delete", after("delete"))
+	cb.RowQuery().Before("gorm:row_query").Register("metrics:before_row_query", before)
+	cb.RowQuery().After("gorm:row_query").Register("metrics:after_row_query", after("row_query"))
+}
+
+func before(scope *gorm.Scope) {
+	scope.Set(startTimeKey, time.Now())
+}
+
+func after(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if v, ok := scope.Get(startTimeKey); ok {
+			if start, ok := v.(time.Time); ok {
+				dbDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+			}
+		}
+	}
+}
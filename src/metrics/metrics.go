@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"responseutil"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "llmc_http_requests_total",
+		Help: "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llmc_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	distanceRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "llmc_distance_provider_requests_total",
+		Help: "Number of distance provider calls by result (ok, error, rejected).",
+	}, []string{"result"})
+	distanceDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "llmc_distance_provider_request_duration_seconds",
+		Help:    "Latency of distance provider calls.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llmc_db_query_duration_seconds",
+		Help:    "Latency of DB queries by operation.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	ordersDesc = prometheus.NewDesc("llmc_orders", "Number of orders by status.", []string{"status"}, nil)
+)
+
+const (
+	DistanceOK       = "ok"
+	DistanceError    = "error"
+	DistanceRejected = "rejected"
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, distanceRequests, distanceDuration, dbDuration)
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Instrument records count and latency of h under the route pattern, so path parameters do not explode the labels
+func Instrument(route string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		sw := responseutil.NewStatusWriter(w)
+		h(sw, r, ps)
+		status := strconv.Itoa(sw.Status)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveDistanceCall records a distance provider call started at start with the given result
+func ObserveDistanceCall(start time.Time, result string) {
+	distanceRequests.WithLabelValues(result).Inc()
+	if result != DistanceRejected {
+		distanceDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+// OrderCounter returns the number of orders per status
+type OrderCounter func() (map[string]int, error)
+
+type orderCollector struct {
+	count OrderCounter
+}
+
+// RegisterOrderCounter exposes the result of count as a gauge per status, queried on every scrape
+func RegisterOrderCounter(count OrderCounter) {
+	prometheus.MustRegister(&orderCollector{count})
+}
+
+func (oc *orderCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ordersDesc
+}
+
+func (oc *orderCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := oc.count()
+	if err != nil {
+		log.Errorf("Cannot count orders for metrics: %v", err)
+		return
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(ordersDesc, prometheus.GaugeValue, float64(n), status)
+	}
+}
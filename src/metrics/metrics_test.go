@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstrument(t *testing.T) {
+	h := Instrument("/orders/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("/orders/:id", "GET", "404"))
+
+	r, _ := http.NewRequest("GET", "/orders/10", nil)
+	h(httptest.NewRecorder(), r, nil)
+
+	after := testutil.ToFloat64(httpRequests.WithLabelValues("/orders/:id", "GET", "404"))
+	if after-before != 1 {
+		t.Errorf("Expected counter to increase by 1, got %v", after-before)
+	}
+}
+
+func TestObserveDistanceCall(t *testing.T) {
+	before := testutil.ToFloat64(distanceRequests.WithLabelValues(DistanceError))
+
+	ObserveDistanceCall(time.Now(), DistanceError)
+
+	if testutil.ToFloat64(distanceRequests.WithLabelValues(DistanceError))-before != 1 {
+		t.Errorf("Expected error counter to increase by 1")
+	}
+}
+
+func TestOrderCollector(t *testing.T) {
+	c := &orderCollector{func() (map[string]int, error) {
+		return map[string]int{"UNASSIGNED": 3, "TAKEN": 1}, nil
+	}}
+	expected := `
+# HELP llmc_orders Number of orders by status.
+# TYPE llmc_orders gauge
+llmc_orders{status="TAKEN"} 1
+llmc_orders{status="UNASSIGNED"} 3
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOrderCollectorError(t *testing.T) {
+	c := &orderCollector{func() (map[string]int, error) { return nil, errors.New("db down") }}
+
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("Expected no metrics, got %d", n)
+	}
+}
+
+var _ prometheus.Collector = &orderCollector{}
@@ -0,0 +1,38 @@
+// Package openapi serves the OpenAPI 3 description of the REST API and validates requests against it. openapi.json
+// is maintained by hand along with the handlers, and the requesthandler tests check the handler responses against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"responseutil"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec returns the OpenAPI document as JSON
+func Spec() []byte {
+	return spec
+}
+
+// Load parses the OpenAPI document and checks it is valid
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse openapi.json: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi.json: %v", err)
+	}
+	return doc, nil
+}
+
+// Handle serves the OpenAPI document at GET /openapi.json
+func Handle(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	responseutil.WriteRawResponse(w, "application/json", spec, http.StatusOK)
+}
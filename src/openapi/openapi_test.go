@@ -0,0 +1,30 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotNil(t, doc.Paths.Find("/orders/{id}").Patch)
+}
+
+func TestHandle(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	Handle(w, httptest.NewRequest("GET", "/openapi.json", nil), nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var doc map[string]interface{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+}
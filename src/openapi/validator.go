@@ -0,0 +1,242 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"logging"
+	"net/http"
+	"responseutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	// reason of kin-openapi for request bodies of a content type the operation does not take
+	invalidContentType = "header Content-Type has unexpected value"
+	// reason suffix of kin-openapi for properties not in the schema
+	unsupportedProperty = " is unsupported"
+	eventStream         = "text/event-stream"
+)
+
+var (
+	// the handlers authenticate, defaults are left for them to apply
+	requestOptions = &openapi3filter.Options{MultiError: true, SkipSettingDefaults: true,
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}
+	responseOptions = &openapi3filter.Options{MultiError: true, IncludeResponseStatus: true}
+)
+
+// Validator checks requests against the OpenAPI document before they reach the handlers, answering the ones which
+// do not match with a problem listing the invalid fields. Requests of routes missing from the document are let
+// through.
+type Validator struct {
+	router routers.Router
+	// whether requests are validated
+	Requests bool
+	// whether responses are validated, replacing the ones not matching the document with a 500 invalid_response
+	// problem. Responses are buffered for it, so it is meant for tests and staging.
+	Responses bool
+}
+
+func NewValidator(requests bool, responses bool) (*Validator, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{router: router, Requests: requests, Responses: responses}, nil
+}
+
+// Middleware validates the requests of h and, with Responses, its responses. A nil Validator validates nothing.
+func (v *Validator) Middleware(h httprouter.Handle) httprouter.Handle {
+	if v == nil || !v.Requests && !v.Responses {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		route, params, err := v.router.FindRoute(r)
+		if err != nil {
+			h(w, r, ps)
+			return
+		}
+		in := &openapi3filter.RequestValidationInput{Request: r, PathParams: params, Route: route, Options: requestOptions}
+		if v.Requests {
+			if err := openapi3filter.ValidateRequest(r.Context(), in); err != nil {
+				responseutil.WriteProblem(w, r, requestProblem(err))
+				return
+			}
+		}
+		// streams and WebSockets cannot be buffered
+		if !v.Responses || streamed(route.Operation) {
+			h(w, r, ps)
+			return
+		}
+
+		buf := &bufferedWriter{header: w.Header().Clone(), status: http.StatusOK}
+		h(buf, r, ps)
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{RequestValidationInput: in,
+			Status: buf.status, Header: buf.header, Body: io.NopCloser(bytes.NewReader(buf.body.Bytes())), Options: responseOptions})
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Response %d of %s %s does not match the OpenAPI document: %v", buf.status, r.Method, route.Path, err)
+			responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusInternalServerError, responseutil.CodeInvalidResponse,
+				"Response does not match the OpenAPI document", fieldErrors("", err)...))
+			return
+		}
+		for k, vs := range buf.header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(buf.status)
+		_, _ = w.Write(buf.body.Bytes())
+	}
+}
+
+// requestProblem is the problem answered for a request not matching the document. The code is the one the
+// handlers answer for the part of the request with the first error: path parameters are ids, then come the query
+// parameters, headers and body.
+func requestProblem(err error) *responseutil.Problem {
+	var errs []responseutil.FieldError
+	code, detail := "", ""
+	for _, err := range flatten(err) {
+		var re *openapi3filter.RequestError
+		if code == "" {
+			code, detail = responseutil.CodeValidationFailed, "Request does not match the API description"
+			if errors.As(err, &re) && re.Parameter != nil && re.Parameter.In == openapi3.ParameterInPath {
+				code, detail = responseutil.CodeInvalidID, "Invalid path parameters"
+			} else if re != nil && re.Parameter != nil && re.Parameter.In == openapi3.ParameterInQuery {
+				code, detail = responseutil.CodeInvalidQuery, "Invalid query parameters"
+			}
+		}
+		if !errors.As(err, &re) {
+			errs = append(errs, fieldErrors("", err)...)
+			continue
+		}
+		var pe *openapi3filter.ParseError
+		switch {
+		case re.RequestBody != nil && strings.HasPrefix(re.Reason, invalidContentType):
+			return responseutil.NewProblem(http.StatusUnsupportedMediaType, responseutil.CodeUnsupportedMediaType, "Content-Type must be application/json")
+		case re.RequestBody != nil && errors.As(re.Err, &pe):
+			return responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not valid JSON")
+		case re.RequestBody != nil && re.Err == openapi3filter.ErrInvalidRequired:
+			errs = append(errs, responseutil.FieldError{Field: "body", Code: "required", Detail: "is required"})
+		case re.RequestBody != nil:
+			errs = append(errs, fieldErrors("", re.Err)...)
+		case re.Parameter != nil && re.Err == openapi3filter.ErrInvalidRequired:
+			errs = append(errs, responseutil.FieldError{Field: re.Parameter.Name, Code: "required", Detail: "is required"})
+		case re.Parameter != nil && errors.As(re.Err, &pe):
+			errs = append(errs, responseutil.FieldError{Field: re.Parameter.Name, Code: "type", Detail: "has an invalid format"})
+		case re.Parameter != nil:
+			errs = append(errs, fieldErrors(re.Parameter.Name, re.Err)...)
+		default:
+			errs = append(errs, responseutil.FieldError{Code: "invalid", Detail: re.Error()})
+		}
+	}
+	return responseutil.NewProblem(http.StatusBadRequest, code, detail, errs...)
+}
+
+// fieldErrors are the schema errors of err, with the path of the values below prefix as field
+func fieldErrors(prefix string, err error) []responseutil.FieldError {
+	var errs []responseutil.FieldError
+	for _, err := range flatten(err) {
+		var se *openapi3.SchemaError
+		var re *openapi3filter.ResponseError
+		switch {
+		case errors.As(err, &se):
+			errs = append(errs, schemaFieldError(prefix, se))
+		case errors.As(err, &re) && re.Err != nil:
+			errs = append(errs, fieldErrors(prefix, re.Err)...)
+		default:
+			errs = append(errs, responseutil.FieldError{Field: prefix, Code: "invalid", Detail: err.Error()})
+		}
+	}
+	return errs
+}
+
+// flatten returns the errors of nested multi errors. errors.As is not used as it finds multi errors wrapped in the
+// request errors.
+func flatten(err error) []error {
+	me, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range me {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// fieldPath joins the JSON pointer segments the way the handlers name fields, e.g. features[1].properties.name
+func fieldPath(prefix string, pointer []string) string {
+	path := prefix
+	for _, s := range pointer {
+		if _, err := strconv.Atoi(s); err == nil {
+			path += "[" + s + "]"
+		} else if path == "" {
+			path = s
+		} else {
+			path += "." + s
+		}
+	}
+	return path
+}
+
+// schemaFieldError names the field and the schema keyword a value breaks. Properties not in the schema are reported
+// by kin-openapi on their object, they are named like the handlers name them.
+func schemaFieldError(prefix string, se *openapi3.SchemaError) responseutil.FieldError {
+	field := fieldPath(prefix, se.JSONPointer())
+	if se.SchemaField == "properties" && strings.HasPrefix(se.Reason, "property ") && strings.HasSuffix(se.Reason, unsupportedProperty) {
+		name, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(se.Reason, "property "), unsupportedProperty))
+		if err == nil {
+			return responseutil.FieldError{Field: fieldPath(field, []string{name}), Code: "unknown", Detail: se.Reason}
+		}
+	}
+	code := se.SchemaField
+	if code == "" {
+		code = "invalid"
+	}
+	return responseutil.FieldError{Field: field, Code: code, Detail: se.Reason}
+}
+
+// streamed is whether op answers with an event stream or a WebSocket
+func streamed(op *openapi3.Operation) bool {
+	if op.Responses.Value(strconv.Itoa(http.StatusSwitchingProtocols)) != nil {
+		return true
+	}
+	for _, res := range op.Responses.Map() {
+		if res.Value != nil && res.Value.Content.Get(eventStream) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter keeps a response until it is validated
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if !b.wrote {
+		b.status = status
+		b.wrote = true
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,168 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"responseutil"
+	"strings"
+	"testing"
+)
+
+const placeOrder = `{"origin": ["22.3", "114.1"], "destination": "Central, Hong Kong"}`
+
+func TestValidatorPassesValidRequest(t *testing.T) {
+	var body string
+	h := validate(t, true, false, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	w := serve(h, jsonRequest("POST", "/orders", placeOrder))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, placeOrder, body)
+}
+
+func TestValidatorRejectsInvalidBody(t *testing.T) {
+	h := validate(t, true, false, unreachable(t))
+
+	w := serve(h, jsonRequest("POST", "/orders", `{"origin": ["22.3", "114.1"], "weight": 2}`))
+
+	p := problem(t, w, http.StatusBadRequest, responseutil.CodeValidationFailed)
+	assert.Contains(t, p.Errors, responseutil.FieldError{Field: "weight", Code: "unknown", Detail: `property "weight" is unsupported`})
+	assert.Contains(t, p.Errors, responseutil.FieldError{Field: "destination", Code: "required", Detail: `property "destination" is missing`})
+}
+
+func TestValidatorRejectsNestedField(t *testing.T) {
+	h := validate(t, true, false, unreachable(t))
+
+	w := serve(h, jsonRequest("POST", "/orders", `{"origin": ["22.3", "114.1"], "destination": {"address": 1}}`))
+
+	p := problem(t, w, http.StatusBadRequest, responseutil.CodeValidationFailed)
+	assert.Equal(t, []responseutil.FieldError{{Field: "destination", Code: "oneOf", Detail: `value doesn't match any schema from "oneOf"`}}, p.Errors)
+}
+
+func TestValidatorRejectsInvalidJSON(t *testing.T) {
+	h := validate(t, true, false, unreachable(t))
+
+	w := serve(h, jsonRequest("POST", "/orders", `{"origin":`))
+
+	problem(t, w, http.StatusBadRequest, responseutil.CodeInvalidJSON)
+}
+
+func TestValidatorRejectsContentType(t *testing.T) {
+	h := validate(t, true, false, unreachable(t))
+	r := httptest.NewRequest("POST", "/orders", strings.NewReader(placeOrder))
+	r.Header.Set("Content-Type", "text/plain")
+
+	w := serve(h, r)
+
+	problem(t, w, http.StatusUnsupportedMediaType, responseutil.CodeUnsupportedMediaType)
+}
+
+func TestValidatorRejectsInvalidParameter(t *testing.T) {
+	h := validate(t, true, false, unreachable(t))
+
+	w := serve(h, httptest.NewRequest("GET", "/orders?limit=0", nil))
+
+	p := problem(t, w, http.StatusBadRequest, responseutil.CodeInvalidQuery)
+	if assert.Len(t, p.Errors, 1) {
+		assert.Equal(t, "limit", p.Errors[0].Field)
+		assert.Equal(t, "minimum", p.Errors[0].Code)
+	}
+
+	w = serve(h, httptest.NewRequest("GET", "/orders?limit=ten", nil))
+
+	p = problem(t, w, http.StatusBadRequest, responseutil.CodeInvalidQuery)
+	assert.Equal(t, []responseutil.FieldError{{Field: "limit", Code: "type", Detail: "has an invalid format"}}, p.Errors)
+
+	w = serve(h, jsonRequest("PATCH", "/orders/one", `{"status": "TAKEN"}`))
+
+	p = problem(t, w, http.StatusBadRequest, responseutil.CodeInvalidID)
+	assert.Equal(t, "id", p.Errors[0].Field)
+}
+
+func TestValidatorPassesUndocumentedRoute(t *testing.T) {
+	h := validate(t, true, true, func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	w := serve(h, httptest.NewRequest("GET", "/teapot", nil))
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestValidatorPassesValidResponse(t *testing.T) {
+	h := validate(t, false, true, func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.Header().Set("ETag", `"1"`)
+		responseutil.WriteRawResponse(w, "application/json", []byte(`[]`), http.StatusOK)
+	})
+
+	w := serve(h, httptest.NewRequest("GET", "/orders", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, `[]`, w.Body.String())
+}
+
+func TestValidatorReplacesInvalidResponse(t *testing.T) {
+	h := validate(t, false, true, func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.Header().Set("ETag", `"1"`)
+		responseutil.WriteRawResponse(w, "application/json", []byte(`[{"id": 1}]`), http.StatusOK)
+	})
+
+	w := serve(h, httptest.NewRequest("GET", "/orders", nil))
+
+	p := problem(t, w, http.StatusInternalServerError, responseutil.CodeInvalidResponse)
+	assert.NotEmpty(t, p.Errors)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
+func TestNilValidator(t *testing.T) {
+	var v *Validator
+	called := false
+
+	v.Middleware(func(http.ResponseWriter, *http.Request, httprouter.Params) { called = true })(nil, nil, nil)
+
+	assert.True(t, called)
+}
+
+func validate(t *testing.T, requests bool, responses bool, h httprouter.Handle) httprouter.Handle {
+	v, err := NewValidator(requests, responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.Middleware(h)
+}
+
+func unreachable(t *testing.T) httprouter.Handle {
+	return func(http.ResponseWriter, *http.Request, httprouter.Params) {
+		t.Error("invalid request reached the handler")
+	}
+}
+
+func serve(h httprouter.Handle, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h(w, r, nil)
+	return w
+}
+
+func jsonRequest(method string, target string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func problem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) *responseutil.Problem {
+	assert.Equal(t, status, w.Code)
+	assert.Equal(t, responseutil.ProblemContentType, w.Header().Get("Content-Type"))
+	var p responseutil.Problem
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, code, p.Code)
+	return &p
+}
@@ -0,0 +1,936 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: order.proto
+
+// The gRPC API of the order service, mirroring the REST endpoints: the same validation, roles, rate limits and
+// error codes apply. Credentials are sent as the "authorization" (Bearer JWT) or "x-api-key" metadata, and
+// CreateOrder takes an "idempotency-key" like POST /orders.
+
+package orderpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Point is given as coordinates, a free-text address or a geocoder place id
+type Point struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Point:
+	//
+	//	*Point_Coordinates
+	//	*Point_Address
+	//	*Point_PlaceId
+	Point         isPoint_Point `protobuf_oneof:"point"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Point) Reset() {
+	*x = Point{}
+	mi := &file_order_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Point) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Point) ProtoMessage() {}
+
+func (x *Point) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Point.ProtoReflect.Descriptor instead.
+func (*Point) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Point) GetPoint() isPoint_Point {
+	if x != nil {
+		return x.Point
+	}
+	return nil
+}
+
+func (x *Point) GetCoordinates() *Coordinates {
+	if x != nil {
+		if x, ok := x.Point.(*Point_Coordinates); ok {
+			return x.Coordinates
+		}
+	}
+	return nil
+}
+
+func (x *Point) GetAddress() string {
+	if x != nil {
+		if x, ok := x.Point.(*Point_Address); ok {
+			return x.Address
+		}
+	}
+	return ""
+}
+
+func (x *Point) GetPlaceId() string {
+	if x != nil {
+		if x, ok := x.Point.(*Point_PlaceId); ok {
+			return x.PlaceId
+		}
+	}
+	return ""
+}
+
+type isPoint_Point interface {
+	isPoint_Point()
+}
+
+type Point_Coordinates struct {
+	Coordinates *Coordinates `protobuf:"bytes,1,opt,name=coordinates,proto3,oneof"`
+}
+
+type Point_Address struct {
+	Address string `protobuf:"bytes,2,opt,name=address,proto3,oneof"`
+}
+
+type Point_PlaceId struct {
+	PlaceId string `protobuf:"bytes,3,opt,name=place_id,json=placeId,proto3,oneof"`
+}
+
+func (*Point_Coordinates) isPoint_Point() {}
+
+func (*Point_Address) isPoint_Point() {}
+
+func (*Point_PlaceId) isPoint_Point() {}
+
+// Coordinates are decimal degrees as strings, e.g. "22.2802"
+type Coordinates struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Lat           string                 `protobuf:"bytes,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lng           string                 `protobuf:"bytes,2,opt,name=lng,proto3" json:"lng,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Coordinates) Reset() {
+	*x = Coordinates{}
+	mi := &file_order_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Coordinates) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coordinates) ProtoMessage() {}
+
+func (x *Coordinates) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coordinates.ProtoReflect.Descriptor instead.
+func (*Coordinates) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Coordinates) GetLat() string {
+	if x != nil {
+		return x.Lat
+	}
+	return ""
+}
+
+func (x *Coordinates) GetLng() string {
+	if x != nil {
+		return x.Lng
+	}
+	return ""
+}
+
+type CreateOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Origin        *Point                 `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Destination   *Point                 `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateOrderRequest) Reset() {
+	*x = CreateOrderRequest{}
+	mi := &file_order_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateOrderRequest) ProtoMessage() {}
+
+func (x *CreateOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateOrderRequest.ProtoReflect.Descriptor instead.
+func (*CreateOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateOrderRequest) GetOrigin() *Point {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *CreateOrderRequest) GetDestination() *Point {
+	if x != nil {
+		return x.Destination
+	}
+	return nil
+}
+
+type GetOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOrderRequest) Reset() {
+	*x = GetOrderRequest{}
+	mi := &file_order_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOrderRequest) ProtoMessage() {}
+
+func (x *GetOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOrderRequest.ProtoReflect.Descriptor instead.
+func (*GetOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetOrderRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListOrdersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// from 1, the first page when not set
+	Page int32 `protobuf:"varint,1,opt,name=page,proto3" json:"page,omitempty"`
+	// all orders when not set
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListOrdersRequest) Reset() {
+	*x = ListOrdersRequest{}
+	mi := &file_order_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListOrdersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListOrdersRequest) ProtoMessage() {}
+
+func (x *ListOrdersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListOrdersRequest.ProtoReflect.Descriptor instead.
+func (*ListOrdersRequest) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListOrdersRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListOrdersRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListOrdersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Orders        []*Order               `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListOrdersResponse) Reset() {
+	*x = ListOrdersResponse{}
+	mi := &file_order_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListOrdersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListOrdersResponse) ProtoMessage() {}
+
+func (x *ListOrdersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListOrdersResponse.ProtoReflect.Descriptor instead.
+func (*ListOrdersResponse) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListOrdersResponse) GetOrders() []*Order {
+	if x != nil {
+		return x.Orders
+	}
+	return nil
+}
+
+type TakeOrderRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// only taken while the order still has this version, like If-Match, any version when not set
+	Version       uint64 `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TakeOrderRequest) Reset() {
+	*x = TakeOrderRequest{}
+	mi := &file_order_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TakeOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TakeOrderRequest) ProtoMessage() {}
+
+func (x *TakeOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TakeOrderRequest.ProtoReflect.Descriptor instead.
+func (*TakeOrderRequest) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TakeOrderRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *TakeOrderRequest) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type WatchOrdersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// only streams orders changed to these statuses, UNASSIGNED or TAKEN, all when empty
+	Statuses []string `protobuf:"bytes,1,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	// resumes after the event of this id
+	LastEventId   string `protobuf:"bytes,2,opt,name=last_event_id,json=lastEventId,proto3" json:"last_event_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchOrdersRequest) Reset() {
+	*x = WatchOrdersRequest{}
+	mi := &file_order_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchOrdersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchOrdersRequest) ProtoMessage() {}
+
+func (x *WatchOrdersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchOrdersRequest.ProtoReflect.Descriptor instead.
+func (*WatchOrdersRequest) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchOrdersRequest) GetStatuses() []string {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+func (x *WatchOrdersRequest) GetLastEventId() string {
+	if x != nil {
+		return x.LastEventId
+	}
+	return ""
+}
+
+type OrderEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// order.created or order.taken, or reset when the events after last_event_id are not all kept and orders should
+	// be reloaded
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	// not set for reset
+	Order         *Order `protobuf:"bytes,3,opt,name=order,proto3" json:"order,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderEvent) Reset() {
+	*x = OrderEvent{}
+	mi := &file_order_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderEvent) ProtoMessage() {}
+
+func (x *OrderEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderEvent.ProtoReflect.Descriptor instead.
+func (*OrderEvent) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *OrderEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *OrderEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *OrderEvent) GetOrder() *Order {
+	if x != nil {
+		return x.Order
+	}
+	return nil
+}
+
+type Order struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// of the route in meters
+	Distance  int32  `protobuf:"varint,2,opt,name=distance,proto3" json:"distance,omitempty"`
+	Status    string `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Version   uint64 `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	CourierId string `protobuf:"bytes,5,opt,name=courier_id,json=courierId,proto3" json:"courier_id,omitempty"`
+	CreatedBy string `protobuf:"bytes,6,opt,name=created_by,json=createdBy,proto3" json:"created_by,omitempty"`
+	// of the route in seconds
+	Duration int32 `protobuf:"varint,7,opt,name=duration,proto3" json:"duration,omitempty"`
+	// in minor units of currency, e.g. cents, 0 when pricing is not configured
+	Price                       int64  `protobuf:"varint,8,opt,name=price,proto3" json:"price,omitempty"`
+	Currency                    string `protobuf:"bytes,9,opt,name=currency,proto3" json:"currency,omitempty"`
+	OriginAddress               string `protobuf:"bytes,10,opt,name=origin_address,json=originAddress,proto3" json:"origin_address,omitempty"`
+	OriginPlaceId               string `protobuf:"bytes,11,opt,name=origin_place_id,json=originPlaceId,proto3" json:"origin_place_id,omitempty"`
+	DestinationAddress          string `protobuf:"bytes,12,opt,name=destination_address,json=destinationAddress,proto3" json:"destination_address,omitempty"`
+	DestinationPlaceId          string `protobuf:"bytes,13,opt,name=destination_place_id,json=destinationPlaceId,proto3" json:"destination_place_id,omitempty"`
+	OriginFormattedAddress      string `protobuf:"bytes,14,opt,name=origin_formatted_address,json=originFormattedAddress,proto3" json:"origin_formatted_address,omitempty"`
+	DestinationFormattedAddress string `protobuf:"bytes,15,opt,name=destination_formatted_address,json=destinationFormattedAddress,proto3" json:"destination_formatted_address,omitempty"`
+	Zone                        string `protobuf:"bytes,16,opt,name=zone,proto3" json:"zone,omitempty"`
+	// last known location of the courier, only set by GetOrder
+	CourierLocation *Location `protobuf:"bytes,17,opt,name=courier_location,json=courierLocation,proto3" json:"courier_location,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Order) Reset() {
+	*x = Order{}
+	mi := &file_order_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Order) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Order) ProtoMessage() {}
+
+func (x *Order) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Order.ProtoReflect.Descriptor instead.
+func (*Order) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Order) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Order) GetDistance() int32 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *Order) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Order) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Order) GetCourierId() string {
+	if x != nil {
+		return x.CourierId
+	}
+	return ""
+}
+
+func (x *Order) GetCreatedBy() string {
+	if x != nil {
+		return x.CreatedBy
+	}
+	return ""
+}
+
+func (x *Order) GetDuration() int32 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *Order) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Order) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Order) GetOriginAddress() string {
+	if x != nil {
+		return x.OriginAddress
+	}
+	return ""
+}
+
+func (x *Order) GetOriginPlaceId() string {
+	if x != nil {
+		return x.OriginPlaceId
+	}
+	return ""
+}
+
+func (x *Order) GetDestinationAddress() string {
+	if x != nil {
+		return x.DestinationAddress
+	}
+	return ""
+}
+
+func (x *Order) GetDestinationPlaceId() string {
+	if x != nil {
+		return x.DestinationPlaceId
+	}
+	return ""
+}
+
+func (x *Order) GetOriginFormattedAddress() string {
+	if x != nil {
+		return x.OriginFormattedAddress
+	}
+	return ""
+}
+
+func (x *Order) GetDestinationFormattedAddress() string {
+	if x != nil {
+		return x.DestinationFormattedAddress
+	}
+	return ""
+}
+
+func (x *Order) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *Order) GetCourierLocation() *Location {
+	if x != nil {
+		return x.CourierLocation
+	}
+	return nil
+}
+
+type Location struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Lat   float64                `protobuf:"fixed64,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lng   float64                `protobuf:"fixed64,2,opt,name=lng,proto3" json:"lng,omitempty"`
+	// in meters
+	Accuracy float64 `protobuf:"fixed64,3,opt,name=accuracy,proto3" json:"accuracy,omitempty"`
+	// RFC 3339
+	LocatedAt     string `protobuf:"bytes,4,opt,name=located_at,json=locatedAt,proto3" json:"located_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_order_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_order_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_order_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Location) GetLat() float64 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *Location) GetLng() float64 {
+	if x != nil {
+		return x.Lng
+	}
+	return 0
+}
+
+func (x *Location) GetAccuracy() float64 {
+	if x != nil {
+		return x.Accuracy
+	}
+	return 0
+}
+
+func (x *Location) GetLocatedAt() string {
+	if x != nil {
+		return x.LocatedAt
+	}
+	return ""
+}
+
+var File_order_proto protoreflect.FileDescriptor
+
+const file_order_proto_rawDesc = "" +
+	"\n" +
+	"\vorder.proto\x12\allmc.v1\"\x83\x01\n" +
+	"\x05Point\x128\n" +
+	"\vcoordinates\x18\x01 \x01(\v2\x14.llmc.v1.CoordinatesH\x00R\vcoordinates\x12\x1a\n" +
+	"\aaddress\x18\x02 \x01(\tH\x00R\aaddress\x12\x1b\n" +
+	"\bplace_id\x18\x03 \x01(\tH\x00R\aplaceIdB\a\n" +
+	"\x05point\"1\n" +
+	"\vCoordinates\x12\x10\n" +
+	"\x03lat\x18\x01 \x01(\tR\x03lat\x12\x10\n" +
+	"\x03lng\x18\x02 \x01(\tR\x03lng\"n\n" +
+	"\x12CreateOrderRequest\x12&\n" +
+	"\x06origin\x18\x01 \x01(\v2\x0e.llmc.v1.PointR\x06origin\x120\n" +
+	"\vdestination\x18\x02 \x01(\v2\x0e.llmc.v1.PointR\vdestination\"!\n" +
+	"\x0fGetOrderRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"=\n" +
+	"\x11ListOrdersRequest\x12\x12\n" +
+	"\x04page\x18\x01 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"<\n" +
+	"\x12ListOrdersResponse\x12&\n" +
+	"\x06orders\x18\x01 \x03(\v2\x0e.llmc.v1.OrderR\x06orders\"<\n" +
+	"\x10TakeOrderRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x04R\aversion\"T\n" +
+	"\x12WatchOrdersRequest\x12\x1a\n" +
+	"\bstatuses\x18\x01 \x03(\tR\bstatuses\x12\"\n" +
+	"\rlast_event_id\x18\x02 \x01(\tR\vlastEventId\"V\n" +
+	"\n" +
+	"OrderEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12$\n" +
+	"\x05order\x18\x03 \x01(\v2\x0e.llmc.v1.OrderR\x05order\"\xf3\x04\n" +
+	"\x05Order\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1a\n" +
+	"\bdistance\x18\x02 \x01(\x05R\bdistance\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\x04R\aversion\x12\x1d\n" +
+	"\n" +
+	"courier_id\x18\x05 \x01(\tR\tcourierId\x12\x1d\n" +
+	"\n" +
+	"created_by\x18\x06 \x01(\tR\tcreatedBy\x12\x1a\n" +
+	"\bduration\x18\a \x01(\x05R\bduration\x12\x14\n" +
+	"\x05price\x18\b \x01(\x03R\x05price\x12\x1a\n" +
+	"\bcurrency\x18\t \x01(\tR\bcurrency\x12%\n" +
+	"\x0eorigin_address\x18\n" +
+	" \x01(\tR\roriginAddress\x12&\n" +
+	"\x0forigin_place_id\x18\v \x01(\tR\roriginPlaceId\x12/\n" +
+	"\x13destination_address\x18\f \x01(\tR\x12destinationAddress\x120\n" +
+	"\x14destination_place_id\x18\r \x01(\tR\x12destinationPlaceId\x128\n" +
+	"\x18origin_formatted_address\x18\x0e \x01(\tR\x16originFormattedAddress\x12B\n" +
+	"\x1ddestination_formatted_address\x18\x0f \x01(\tR\x1bdestinationFormattedAddress\x12\x12\n" +
+	"\x04zone\x18\x10 \x01(\tR\x04zone\x12<\n" +
+	"\x10courier_location\x18\x11 \x01(\v2\x11.llmc.v1.LocationR\x0fcourierLocation\"i\n" +
+	"\bLocation\x12\x10\n" +
+	"\x03lat\x18\x01 \x01(\x01R\x03lat\x12\x10\n" +
+	"\x03lng\x18\x02 \x01(\x01R\x03lng\x12\x1a\n" +
+	"\baccuracy\x18\x03 \x01(\x01R\baccuracy\x12\x1d\n" +
+	"\n" +
+	"located_at\x18\x04 \x01(\tR\tlocatedAt2\xc2\x02\n" +
+	"\fOrderService\x12:\n" +
+	"\vCreateOrder\x12\x1b.llmc.v1.CreateOrderRequest\x1a\x0e.llmc.v1.Order\x124\n" +
+	"\bGetOrder\x12\x18.llmc.v1.GetOrderRequest\x1a\x0e.llmc.v1.Order\x12E\n" +
+	"\n" +
+	"ListOrders\x12\x1a.llmc.v1.ListOrdersRequest\x1a\x1b.llmc.v1.ListOrdersResponse\x126\n" +
+	"\tTakeOrder\x12\x19.llmc.v1.TakeOrderRequest\x1a\x0e.llmc.v1.Order\x12A\n" +
+	"\vWatchOrders\x12\x1b.llmc.v1.WatchOrdersRequest\x1a\x13.llmc.v1.OrderEvent0\x01B\x13Z\x11./orderpb;orderpbb\x06proto3"
+
+var (
+	file_order_proto_rawDescOnce sync.Once
+	file_order_proto_rawDescData []byte
+)
+
+func file_order_proto_rawDescGZIP() []byte {
+	file_order_proto_rawDescOnce.Do(func() {
+		file_order_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_order_proto_rawDesc), len(file_order_proto_rawDesc)))
+	})
+	return file_order_proto_rawDescData
+}
+
+var file_order_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_order_proto_goTypes = []any{
+	(*Point)(nil),              // 0: llmc.v1.Point
+	(*Coordinates)(nil),        // 1: llmc.v1.Coordinates
+	(*CreateOrderRequest)(nil), // 2: llmc.v1.CreateOrderRequest
+	(*GetOrderRequest)(nil),    // 3: llmc.v1.GetOrderRequest
+	(*ListOrdersRequest)(nil),  // 4: llmc.v1.ListOrdersRequest
+	(*ListOrdersResponse)(nil), // 5: llmc.v1.ListOrdersResponse
+	(*TakeOrderRequest)(nil),   // 6: llmc.v1.TakeOrderRequest
+	(*WatchOrdersRequest)(nil), // 7: llmc.v1.WatchOrdersRequest
+	(*OrderEvent)(nil),         // 8: llmc.v1.OrderEvent
+	(*Order)(nil),              // 9: llmc.v1.Order
+	(*Location)(nil),           // 10: llmc.v1.Location
+}
+var file_order_proto_depIdxs = []int32{
+	1,  // 0: llmc.v1.Point.coordinates:type_name -> llmc.v1.Coordinates
+	0,  // 1: llmc.v1.CreateOrderRequest.origin:type_name -> llmc.v1.Point
+	0,  // 2: llmc.v1.CreateOrderRequest.destination:type_name -> llmc.v1.Point
+	9,  // 3: llmc.v1.ListOrdersResponse.orders:type_name -> llmc.v1.Order
+	9,  // 4: llmc.v1.OrderEvent.order:type_name -> llmc.v1.Order
+	10, // 5: llmc.v1.Order.courier_location:type_name -> llmc.v1.Location
+	2,  // 6: llmc.v1.OrderService.CreateOrder:input_type -> llmc.v1.CreateOrderRequest
+	3,  // 7: llmc.v1.OrderService.GetOrder:input_type -> llmc.v1.GetOrderRequest
+	4,  // 8: llmc.v1.OrderService.ListOrders:input_type -> llmc.v1.ListOrdersRequest
+	6,  // 9: llmc.v1.OrderService.TakeOrder:input_type -> llmc.v1.TakeOrderRequest
+	7,  // 10: llmc.v1.OrderService.WatchOrders:input_type -> llmc.v1.WatchOrdersRequest
+	9,  // 11: llmc.v1.OrderService.CreateOrder:output_type -> llmc.v1.Order
+	9,  // 12: llmc.v1.OrderService.GetOrder:output_type -> llmc.v1.Order
+	5,  // 13: llmc.v1.OrderService.ListOrders:output_type -> llmc.v1.ListOrdersResponse
+	9,  // 14: llmc.v1.OrderService.TakeOrder:output_type -> llmc.v1.Order
+	8,  // 15: llmc.v1.OrderService.WatchOrders:output_type -> llmc.v1.OrderEvent
+	11, // [11:16] is the sub-list for method output_type
+	6,  // [6:11] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_order_proto_init() }
+func file_order_proto_init() {
+	if File_order_proto != nil {
+		return
+	}
+	file_order_proto_msgTypes[0].OneofWrappers = []any{
+		(*Point_Coordinates)(nil),
+		(*Point_Address)(nil),
+		(*Point_PlaceId)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_order_proto_rawDesc), len(file_order_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_order_proto_goTypes,
+		DependencyIndexes: file_order_proto_depIdxs,
+		MessageInfos:      file_order_proto_msgTypes,
+	}.Build()
+	File_order_proto = out.File
+	file_order_proto_goTypes = nil
+	file_order_proto_depIdxs = nil
+}
@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// KafkaREST produces events to Topic through the REST API v2 of a Kafka REST proxy, e.g. Confluent REST Proxy or
+// Redpanda. Records are keyed by the event key, so the events of an order stay in order on one partition.
+type KafkaREST struct {
+	URL    string
+	Topic  string
+	Client *http.Client
+}
+
+func NewKafkaREST(baseURL string, topic string, timeout time.Duration) *KafkaREST {
+	return &KafkaREST{URL: strings.TrimSuffix(baseURL, "/"), Topic: topic, Client: &http.Client{Timeout: timeout}}
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaOffsets struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (k *KafkaREST) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(&kafkaRecords{Records: []kafkaRecord{{Key: e.Key, Value: e.Payload}}})
+	if err != nil {
+		return fmt.Errorf("kafka: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, k.URL+"/topics/"+url.PathEscape(k.Topic), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("kafka: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka: unexpected status %d: %s", resp.StatusCode, snippet)
+	}
+	var offsets kafkaOffsets
+	if err := json.NewDecoder(resp.Body).Decode(&offsets); err != nil {
+		return fmt.Errorf("kafka: invalid response: %v", err)
+	}
+	for _, o := range offsets.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("kafka: error %d: %s", *o.ErrorCode, o.Error)
+		}
+	}
+	return nil
+}
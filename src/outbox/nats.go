@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS publishes events on the subject Subject followed by the event type, e.g. llmc.order.created, speaking the
+// NATS client protocol. An event is accepted once the server answered the PING sent after it. When the server
+// supports headers the outbox id is sent as Nats-Msg-Id, which JetStream streams deduplicate by.
+type NATS struct {
+	// nats://[user:password@|token@]host:port
+	URL     string
+	Subject string
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	headers bool
+}
+
+func NewNATS(url string, subject string, timeout time.Duration) *NATS {
+	return &NATS{URL: url, Subject: subject, Timeout: timeout}
+}
+
+type natsInfo struct {
+	Headers bool `json:"headers"`
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	Headers  bool   `json:"headers"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+func (n *NATS) Publish(ctx context.Context, e Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := n.publish(e); err != nil {
+		// start over with a new connection
+		_ = n.conn.Close()
+		n.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (n *NATS) connect(ctx context.Context) error {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		// the URL is left out, it may hold credentials
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("nats: invalid URL: %v", err)
+	}
+	d := net.Dialer{Timeout: n.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("nats: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(n.Timeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		_ = conn.Close()
+		return fmt.Errorf("nats: no INFO from server: %q %v", line, err)
+	}
+	var info natsInfo
+	_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+
+	c := natsConnect{Name: "llmc", Lang: "go", Version: "1", Headers: info.Headers}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			c.User, c.Pass = u.User.Username(), pass
+		} else {
+			c.Token = u.User.Username()
+		}
+	}
+	b, _ := json.Marshal(&c)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", b); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("nats: %v", err)
+	}
+	n.conn, n.r, n.headers = conn, r, info.Headers
+	return nil
+}
+
+func (n *NATS) publish(e Event) error {
+	_ = n.conn.SetDeadline(time.Now().Add(n.Timeout))
+	subject := n.Subject + e.Type
+	w := bufio.NewWriter(n.conn)
+	if n.headers {
+		hdr := "NATS/1.0\r\nNats-Msg-Id: " + strconv.FormatUint(e.ID, 10) + "\r\n\r\n"
+		_, _ = fmt.Fprintf(w, "HPUB %s %d %d\r\n%s", subject, len(hdr), len(hdr)+len(e.Payload), hdr)
+	} else {
+		_, _ = fmt.Fprintf(w, "PUB %s %d\r\n", subject, len(e.Payload))
+	}
+	_, _ = w.Write(e.Payload)
+	_, _ = w.WriteString("\r\nPING\r\n")
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("nats: %v", err)
+	}
+
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("nats: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("nats: %v", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates need no answer
+	}
+}
+
+// Close closes the connection to the server
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
@@ -0,0 +1,62 @@
+// Package outbox publishes the events relayed from the outbox table to sinks
+package outbox
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	SinkWebhook = "webhook"
+	SinkLog     = "log"
+	SinkNATS    = "nats"
+	SinkKafka   = "kafka"
+)
+
+// Event is a change recorded in the outbox. The same event may be published more than once, consumers tell
+// duplicates by ID.
+type Event struct {
+	ID   uint64
+	Type string
+	// of the changed entity, e.g. the order id, events of the same key are published in order
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Sink receives the events of the outbox. Publish returns only after the event is accepted, an error has the event
+// published again later.
+type Sink interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// SinkFunc adapts a function to a Sink
+type SinkFunc func(ctx context.Context, e Event) error
+
+func (f SinkFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// Named labels the errors of Sink with Name
+type Named struct {
+	Name string
+	Sink Sink
+}
+
+func (n Named) Publish(ctx context.Context, e Event) error {
+	if err := n.Sink.Publish(ctx, e); err != nil {
+		return fmt.Errorf("%s: %v", n.Name, err)
+	}
+	return nil
+}
+
+// Log writes the events to the log, e.g. for shipping by the log collector
+type Log struct{}
+
+func (Log) Publish(_ context.Context, e Event) error {
+	log.WithField("event_id", e.ID).WithField("event_type", e.Type).WithField("event_key", e.Key).
+		WithField("payload", string(e.Payload)).Info("Event published")
+	return nil
+}
@@ -0,0 +1,73 @@
+package pricing
+
+import (
+	"geo"
+	"math"
+	"time"
+)
+
+// Trip is what a fee is computed for
+type Trip struct {
+	DistanceMeters int
+	Duration       time.Duration
+	Origin         geo.Point
+	Destination    geo.Point
+	// when the order is placed, for the surcharges
+	At time.Time
+}
+
+// Quote is the fee of a trip and how it came about. Amounts are in minor units of Currency.
+type Quote struct {
+	Currency     string   `json:"currency"`
+	Amount       int64    `json:"amount"`
+	BaseFare     int64    `json:"baseFare"`
+	DistanceFare int64    `json:"distanceFare"`
+	DurationFare int64    `json:"durationFare"`
+	Multiplier   float64  `json:"multiplier"`
+	Surcharges   []string `json:"surcharges,omitempty"`
+	Zone         string   `json:"zone,omitempty"`
+	MinimumFare  bool     `json:"minimumFare,omitempty"`
+}
+
+// Price computes the fee: base, distance and duration fares, multiplied by every surcharge due and by the
+// highest multiplier of the zones origin and destination are in, but at least the minimum fare
+func (r *Rules) Price(t Trip) *Quote {
+	q := &Quote{
+		Currency:     r.Currency,
+		BaseFare:     r.BaseFare,
+		DistanceFare: round(float64(r.PerKm) * float64(t.DistanceMeters) / 1000),
+		DurationFare: round(float64(r.PerMinute) * t.Duration.Minutes()),
+		Multiplier:   1,
+	}
+
+	at := t.At.In(r.location)
+	for i := range r.Surcharges {
+		if s := &r.Surcharges[i]; s.applies(at) {
+			q.Multiplier *= s.Multiplier
+			q.Surcharges = append(q.Surcharges, s.Name)
+		}
+	}
+	zoneMultiplier := 0.0
+	for _, z := range r.Zones {
+		if z.Multiplier > zoneMultiplier && (z.Area.Contains(t.Origin) || z.Area.Contains(t.Destination)) {
+			zoneMultiplier = z.Multiplier
+			q.Zone = z.Name
+		}
+	}
+	if q.Zone != "" {
+		q.Multiplier *= zoneMultiplier
+	}
+	// 1.2 * 1.5 is not quite 1.8 in floating point
+	q.Multiplier = math.Round(q.Multiplier*1e6) / 1e6
+
+	q.Amount = round(float64(q.BaseFare+q.DistanceFare+q.DurationFare) * q.Multiplier)
+	if q.Amount < r.MinimumFare {
+		q.Amount = r.MinimumFare
+		q.MinimumFare = true
+	}
+	return q
+}
+
+func round(f float64) int64 {
+	return int64(math.Round(f))
+}
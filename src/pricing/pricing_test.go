@@ -0,0 +1,116 @@
+package pricing
+
+import (
+	"geo"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const rules = `{
+	"currency": "HKD", "baseFare": 1000, "perKm": 400, "perMinute": 50, "minimumFare": 2500,
+	"timeZone": "Asia/Hong_Kong",
+	"surcharges": [
+		{"name": "night", "from": "22:00", "to": "06:00", "multiplier": 1.5},
+		{"name": "weekend", "from": "00:00", "to": "24:00", "days": ["Sat", "sun"], "multiplier": 1.2}
+	],
+	"zones": [
+		{"name": "central", "multiplier": 1.1, "area": {"type": "Polygon", "coordinates": [[[114.15, 22.27], [114.19, 22.27], [114.19, 22.29], [114.15, 22.29], [114.15, 22.27]]]}},
+		{"name": "airport", "multiplier": 1.3, "area": {"type": "Polygon", "coordinates": [[[113.90, 22.29], [113.95, 22.29], [113.95, 22.33], [113.90, 22.33], [113.90, 22.29]]]}}
+	]
+}`
+
+var (
+	central  = geo.Point{Lat: 22.2802, Long: 114.184919}
+	airport  = geo.Point{Lat: 22.308, Long: 113.918}
+	outside  = geo.Point{Lat: 22.40, Long: 114.10}
+	hk, _    = time.LoadLocation("Asia/Hong_Kong")
+	weekday  = time.Date(2026, 10, 14, 12, 0, 0, 0, hk) // Wednesday
+	saturday = time.Date(2026, 10, 17, 12, 0, 0, 0, hk)
+)
+
+func TestPrice(t *testing.T) {
+	r := loadRules(t, rules)
+
+	tests := []struct {
+		name  string
+		trip  Trip
+		quote Quote
+	}{
+		{"plain", Trip{DistanceMeters: 10000, Duration: 20 * time.Minute, Origin: outside, Destination: outside, At: weekday},
+			Quote{Currency: "HKD", Amount: 6000, BaseFare: 1000, DistanceFare: 4000, DurationFare: 1000, Multiplier: 1}},
+		{"minimum", Trip{DistanceMeters: 1000, Duration: time.Minute, Origin: outside, Destination: outside, At: weekday},
+			Quote{Currency: "HKD", Amount: 2500, BaseFare: 1000, DistanceFare: 400, DurationFare: 50, Multiplier: 1, MinimumFare: true}},
+		{"highest zone", Trip{DistanceMeters: 10000, Duration: 20 * time.Minute, Origin: central, Destination: airport, At: weekday},
+			Quote{Currency: "HKD", Amount: 7800, BaseFare: 1000, DistanceFare: 4000, DurationFare: 1000, Multiplier: 1.3, Zone: "airport"}},
+		{"weekend", Trip{DistanceMeters: 10000, Duration: 20 * time.Minute, Origin: outside, Destination: outside, At: saturday},
+			Quote{Currency: "HKD", Amount: 7200, BaseFare: 1000, DistanceFare: 4000, DurationFare: 1000, Multiplier: 1.2, Surcharges: []string{"weekend"}}},
+		// Friday night's surcharge still applies Saturday morning, and the weekend one because it is Saturday
+		{"night after midnight", Trip{DistanceMeters: 10000, Duration: 20 * time.Minute, Origin: outside, Destination: outside, At: saturday.Add(-8 * time.Hour)},
+			Quote{Currency: "HKD", Amount: 10800, BaseFare: 1000, DistanceFare: 4000, DurationFare: 1000, Multiplier: 1.8, Surcharges: []string{"night", "weekend"}}},
+		// hours are in the time zone of the rules
+		{"night in UTC", Trip{DistanceMeters: 10000, Duration: 20 * time.Minute, Origin: outside, Destination: outside, At: time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC)},
+			Quote{Currency: "HKD", Amount: 9000, BaseFare: 1000, DistanceFare: 4000, DurationFare: 1000, Multiplier: 1.5, Surcharges: []string{"night"}}},
+	}
+
+	for _, test := range tests {
+		q := r.Price(test.trip)
+		if !quoteEqual(q, &test.quote) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.quote, *q)
+		}
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, s := range []string{
+		`{"baseFare": 1}`,
+		`{"currency": "HKD", "perKm": -1}`,
+		`{"currency": "HKD", "timeZone": "Nowhere/Else"}`,
+		`{"currency": "HKD", "surcharges": [{"name": "night", "from": "22", "to": "06:00", "multiplier": 1.5}]}`,
+		`{"currency": "HKD", "surcharges": [{"name": "night", "from": "22:00", "to": "06:00", "days": ["Funday"], "multiplier": 1.5}]}`,
+		`{"currency": "HKD", "surcharges": [{"name": "night", "from": "22:00", "to": "06:00"}]}`,
+		`{"currency": "HKD", "zones": [{"name": "x", "multiplier": 1.1}]}`,
+		`{"currency": "HKD", "zones": [{"name": "x", "multiplier": 1.1, "area": {"type": "Point", "coordinates": [1, 2]}}]}`,
+		`{"currency": "HKD", "perMile": 1}`,
+	} {
+		if _, err := LoadRules(writeRules(t, s)); err == nil {
+			t.Errorf("Expected error for %s", s)
+		}
+	}
+}
+
+func TestLoadRulesMissing(t *testing.T) {
+	if _, err := LoadRules("/nonexistent/pricing.json"); err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+}
+
+func loadRules(t *testing.T, s string) *Rules {
+	r, err := LoadRules(writeRules(t, s))
+	if err != nil {
+		t.Fatalf("Cannot load rules: %v", err)
+	}
+	return r
+}
+
+func writeRules(t *testing.T, s string) string {
+	f, _ := ioutil.TempFile("", "pricing*.json")
+	_, _ = f.WriteString(s)
+	_ = f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func quoteEqual(a *Quote, b *Quote) bool {
+	if len(a.Surcharges) != len(b.Surcharges) {
+		return false
+	}
+	for i := range a.Surcharges {
+		if a.Surcharges[i] != b.Surcharges[i] {
+			return false
+		}
+	}
+	return a.Currency == b.Currency && a.Amount == b.Amount && a.BaseFare == b.BaseFare && a.DistanceFare == b.DistanceFare &&
+		a.DurationFare == b.DurationFare && a.Multiplier == b.Multiplier && a.Zone == b.Zone && a.MinimumFare == b.MinimumFare
+}
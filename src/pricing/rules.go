@@ -0,0 +1,146 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"geo"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Rules configure the fee. Amounts are in minor units of Currency, e.g. cents.
+type Rules struct {
+	Currency    string `json:"currency"`
+	BaseFare    int64  `json:"baseFare"`
+	PerKm       int64  `json:"perKm"`
+	PerMinute   int64  `json:"perMinute"`
+	MinimumFare int64  `json:"minimumFare"`
+	// IANA name of the zone the surcharge hours are given in, UTC when empty
+	TimeZone   string      `json:"timeZone"`
+	Surcharges []Surcharge `json:"surcharges"`
+	Zones      []Zone      `json:"zones"`
+
+	location *time.Location
+}
+
+// Surcharge multiplies the fee of orders placed between From and To, e.g. 22:00 and 06:00, on Days such as "sat"
+// (every day when empty)
+type Surcharge struct {
+	Name       string   `json:"name"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Days       []string `json:"days"`
+	Multiplier float64  `json:"multiplier"`
+
+	from, to time.Duration
+	days     map[time.Weekday]bool
+}
+
+// Zone multiplies the fee of orders with origin or destination in Area
+type Zone struct {
+	Name       string        `json:"name"`
+	Multiplier float64       `json:"multiplier"`
+	Area       *geo.Geometry `json:"area"`
+}
+
+var weekdays = map[string]time.Weekday{"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday}
+
+// LoadRules reads the rules from a JSON file
+func LoadRules(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRules(f)
+}
+
+// ParseRules reads the rules as JSON
+func ParseRules(in io.Reader) (*Rules, error) {
+	var r Rules
+	dec := json.NewDecoder(in)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, err
+	}
+	if err := r.compile(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// compile validates the rules and prepares them for pricing
+func (r *Rules) compile() error {
+	if r.Currency == "" {
+		return fmt.Errorf("currency is required")
+	}
+	if r.BaseFare < 0 || r.PerKm < 0 || r.PerMinute < 0 || r.MinimumFare < 0 {
+		return fmt.Errorf("fares must not be negative")
+	}
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid timeZone: %v", err)
+	}
+	r.location = loc
+
+	for i := range r.Surcharges {
+		s := &r.Surcharges[i]
+		if s.Multiplier <= 0 {
+			return fmt.Errorf("surcharge %q: multiplier must be positive", s.Name)
+		}
+		if s.from, err = parseClock(s.From); err != nil {
+			return fmt.Errorf("surcharge %q: invalid from: %v", s.Name, err)
+		}
+		if s.to, err = parseClock(s.To); err != nil {
+			return fmt.Errorf("surcharge %q: invalid to: %v", s.Name, err)
+		}
+		s.days = map[time.Weekday]bool{}
+		for _, d := range s.Days {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return fmt.Errorf("surcharge %q: invalid day %q", s.Name, d)
+			}
+			s.days[wd] = true
+		}
+	}
+	for _, z := range r.Zones {
+		if z.Multiplier <= 0 {
+			return fmt.Errorf("zone %q: multiplier must be positive", z.Name)
+		}
+		if z.Area == nil {
+			return fmt.Errorf("zone %q: area is required", z.Name)
+		}
+	}
+	return nil
+}
+
+// applies returns whether the surcharge is due at t. A window ending before it starts spans midnight, and
+// belongs to the day it started.
+func (s *Surcharge) applies(t time.Time) bool {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	day := t.Weekday()
+	var in bool
+	if s.from <= s.to {
+		in = clock >= s.from && clock < s.to
+	} else if clock >= s.from {
+		in = true
+	} else if clock < s.to {
+		in = true
+		day = (day + 6) % 7
+	}
+	return in && (len(s.days) == 0 || s.days[day])
+}
+
+func parseClock(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
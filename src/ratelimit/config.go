@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"fmt"
+	"golang.org/x/time/rate"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRoute is the key of the limit applied to routes without their own limit
+const DefaultRoute = "*"
+
+// Limit refills Rate tokens per second up to Burst
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate == rate.Inf
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g/s (burst %d)", float64(l.Rate), l.Burst)
+}
+
+func (l Limit) timeToFill(tokens float64) time.Duration {
+	missing := float64(l.Burst) - tokens
+	if missing <= 0 || l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(missing / float64(l.Rate) * float64(time.Second))
+}
+
+// Config holds the limits by "<METHOD> <path>" route
+type Config map[string]Limit
+
+// For returns the limit of the route, or the default one
+func (c Config) For(method string, path string) Limit {
+	if l, ok := c[method+" "+path]; ok {
+		return l
+	}
+	if l, ok := c[DefaultRoute]; ok {
+		return l
+	}
+	return Limit{Rate: rate.Inf}
+}
+
+// ParseConfig parses comma separated "<route>=<requests>/<period>[:<burst>]" entries, where route is
+// "<METHOD> <path>" as registered in the router or * for the default, period is s, m or h, and burst defaults
+// to requests. A limit of "off" disables limiting, e.g. "*=20/s:40,POST /orders=30/m:5,GET /orders=off".
+func ParseConfig(s string) (Config, error) {
+	c := make(Config)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rate limit %q, expected <route>=<limit>", entry)
+		}
+		l, err := parseLimit(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %v", entry, err)
+		}
+		c[strings.Join(strings.Fields(kv[0]), " ")] = l
+	}
+	return c, nil
+}
+
+func parseLimit(s string) (Limit, error) {
+	if s == "off" {
+		return Limit{Rate: rate.Inf}, nil
+	}
+	burst := ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		s, burst = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("expected <requests>/<period>")
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid requests %s", parts[0])
+	}
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[parts[1]]
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid period %s", parts[1])
+	}
+	l := Limit{Rate: rate.Limit(float64(n) / period.Seconds()), Burst: n}
+	if burst != "" {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid burst %s", burst)
+		}
+	}
+	return l, nil
+}
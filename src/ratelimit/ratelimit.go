@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"auth"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/time/rate"
+	"math"
+	"net"
+	"net/http"
+	"responseutil"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	idleTTL       = 10 * time.Minute
+	sweepInterval = time.Minute
+)
+
+// Limiter is a token bucket per client, clients being told apart by the authenticated principal or else by IP
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+}
+
+type client struct {
+	bucket   *rate.Limiter
+	lastSeen time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, clients: make(map[string]*client), lastSweep: time.Now()}
+}
+
+// Middleware answers 429 once the caller used up its bucket, and reports the bucket in X-RateLimit-* headers. It
+// goes inside auth.Authenticator.Require, so callers cannot get fresh buckets by sending made up credentials.
+func (l *Limiter) Middleware(h httprouter.Handle) httprouter.Handle {
+	if l.limit.Unlimited() {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		now := time.Now()
+		bucket := l.bucket(clientKey(r), now)
+
+		res := bucket.ReserveN(now, 1)
+		delay := res.DelayFrom(now)
+		if delay > 0 {
+			res.CancelAt(now)
+		}
+		tokens := bucket.TokensAt(now)
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.limit.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Max(0, math.Floor(tokens)))))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(l.limit.timeToFill(tokens))))
+
+		if delay > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(delay)))
+			responseutil.WriteError(w, r, http.StatusTooManyRequests, responseutil.CodeRateLimited, fmt.Sprintf("Rate limit of %s exceeded, retry in %d seconds", l.limit, seconds(delay)))
+			return
+		}
+		h(w, r, ps)
+	}
+}
+
+func (l *Limiter) bucket(key string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > idleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{bucket: rate.NewLimiter(l.limit.Rate, l.limit.Burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c.bucket
+}
+
+func clientKey(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return "principal:" + p.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// round up, so clients retrying after the given seconds do not get rejected again
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"auth"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"responseutil"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	h := New(Limit{Rate: rate.Every(time.Hour), Burst: 2}).Middleware(okHandler)
+
+	w := call(h, "1.2.3.4:1000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = call(h, "1.2.3.4:1001", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = call(h, "1.2.3.4:1002", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+	assert.Equal(t, responseutil.ProblemContentType, w.Header().Get("Content-Type"))
+}
+
+func TestMiddlewareKeyedByClient(t *testing.T) {
+	h := New(Limit{Rate: rate.Every(time.Hour), Burst: 1}).Middleware(okHandler)
+
+	assert.Equal(t, http.StatusOK, call(h, "1.2.3.4:1000", "").Code)
+	assert.Equal(t, http.StatusOK, call(h, "5.6.7.8:1000", "").Code)
+	// same IP but an authenticated caller is its own client
+	assert.Equal(t, http.StatusOK, call(h, "1.2.3.4:1000", "customer-1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, call(h, "5.6.7.8:1000", "customer-1").Code)
+}
+
+func TestMiddlewareIgnoresUnauthenticatedKeys(t *testing.T) {
+	h := New(Limit{Rate: rate.Every(time.Hour), Burst: 1}).Middleware(okHandler)
+
+	for i, key := range []string{"k1", "k2"} {
+		r, _ := http.NewRequest("POST", "/orders", nil)
+		r.RemoteAddr = "1.2.3.4:1000"
+		r.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		h(w, r, nil)
+		if i == 0 {
+			assert.Equal(t, http.StatusOK, w.Code)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		}
+	}
+}
+
+func TestMiddlewareUnlimited(t *testing.T) {
+	h := New(Limit{Rate: rate.Inf}).Middleware(okHandler)
+
+	w := call(h, "1.2.3.4:1000", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig("*=20/s:40, POST  /orders=30/m:5,GET /orders=off")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Limit{Rate: 20, Burst: 40}, c.For("PATCH", "/orders/:id"))
+	assert.Equal(t, Limit{Rate: 0.5, Burst: 5}, c.For("POST", "/orders"))
+	assert.True(t, c.For("GET", "/orders").Unlimited())
+}
+
+func TestParseConfigWithoutDefault(t *testing.T) {
+	c, err := ParseConfig("POST /orders=1/h")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, c.For("POST", "/orders").Burst)
+	assert.True(t, c.For("GET", "/orders").Unlimited())
+}
+
+func TestParseConfigError(t *testing.T) {
+	for _, s := range []string{"*", "*=20", "*=a/s", "*=0/s", "*=1/d", "*=1/s:0"} {
+		_, err := ParseConfig(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+var okHandler = func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func call(h httprouter.Handle, remoteAddr string, principal string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", "/orders", nil)
+	r.RemoteAddr = remoteAddr
+	if principal != "" {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: principal}))
+	}
+	w := httptest.NewRecorder()
+	h(w, r, nil)
+	return w
+}
@@ -1,6 +1,16 @@
 package request
 
 type PlaceOrderRequest struct {
-	Origin      []string
-	Destination []string
+	Origin      []string `json:"origin"`
+	Destination []string `json:"destination"`
+
+	// set when the point was given as an address or place id, Origin and Destination are filled once resolved
+	OriginPlace      *Place `json:"-"`
+	DestinationPlace *Place `json:"-"`
+}
+
+// Place is a point given as a free-text Address or a geocoder specific PlaceID
+type Place struct {
+	Address string `json:"address,omitempty"`
+	PlaceID string `json:"placeId,omitempty"`
 }
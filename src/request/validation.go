@@ -0,0 +1,224 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validation rules reported in Violation.Rule
+const (
+	RuleRequired  = "required"
+	RuleUnknown   = "unknown"
+	RuleDuplicate = "duplicate"
+	RuleType      = "type"
+	RuleLength    = "length"
+	RuleLatitude  = "latitude"
+	RuleLongitude = "longitude"
+	RulePlace     = "place"
+)
+
+// addresses and place ids are stored with the order
+const (
+	maxAddressLength = 512
+	maxPlaceIDLength = 255
+)
+
+// Violation describes why the value at Path, e.g. origin[0], breaks Rule
+type Violation struct {
+	Path    string
+	Rule    string
+	Value   interface{}
+	Message string
+}
+
+// ParsePlaceOrderRequest decodes and validates the body, collecting every violation instead of stopping at the
+// first one. Unknown and repeated fields are violations too. An error is only returned when the body is not a JSON
+// object.
+func ParsePlaceOrderRequest(body io.Reader) (*PlaceOrderRequest, []Violation, error) {
+	var raw json.RawMessage
+	dec := json.NewDecoder(body)
+	if err := dec.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("unexpected data after JSON object")
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	req := &PlaceOrderRequest{}
+	// encoding/json keeps the last of repeated keys, so which one was meant is ambiguous
+	vs := duplicateKeys("", raw)
+	type point struct {
+		coords *[]string
+		place  **Place
+	}
+	known := map[string]point{"origin": {&req.Origin, &req.OriginPlace}, "destination": {&req.Destination, &req.DestinationPlace}}
+	seen := map[string]bool{}
+	for _, name := range sortedKeys(fields) {
+		path := strings.ToLower(name)
+		target, ok := known[path]
+		if !ok {
+			vs = append(vs, Violation{Path: name, Rule: RuleUnknown, Message: "unknown field"})
+			continue
+		}
+		seen[path] = true
+		pvs := decodeLocation(path, fields[name], target.coords, target.place)
+		if pvs == nil {
+			pvs = validateLocation(path, *target.coords, *target.place)
+		}
+		vs = append(vs, pvs...)
+	}
+	for _, name := range []string{"origin", "destination"} {
+		if !seen[name] {
+			vs = append(vs, Violation{Path: name, Rule: RuleRequired, Message: "is required"})
+		}
+	}
+	return req, vs, nil
+}
+
+// decodeLocation accepts a [latitude, longitude] pair, an address string, or an {"address"} or {"placeId"} object
+func decodeLocation(path string, raw json.RawMessage, coords *[]string, place **Place) []Violation {
+	typeErr := []Violation{{Path: path, Rule: RuleType, Value: rawValue(raw),
+		Message: "must be [latitude, longitude], an address, or an object with address or placeId"}}
+	switch t := bytes.TrimSpace(raw); {
+	case len(t) > 0 && t[0] == '"':
+		var address string
+		if err := json.Unmarshal(t, &address); err != nil {
+			return typeErr
+		}
+		*place = &Place{Address: address}
+	case len(t) > 0 && t[0] == '{':
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(t, &fields); err != nil {
+			return typeErr
+		}
+		p := &Place{}
+		vs := duplicateKeys(path+".", t)
+		for _, name := range sortedKeys(fields) {
+			var target *string
+			switch strings.ToLower(name) {
+			case "address":
+				target = &p.Address
+			case "placeid":
+				target = &p.PlaceID
+			default:
+				vs = append(vs, Violation{Path: path + "." + name, Rule: RuleUnknown, Message: "unknown field"})
+				continue
+			}
+			if err := json.Unmarshal(fields[name], target); err != nil {
+				vs = append(vs, Violation{Path: path + "." + name, Rule: RuleType, Value: rawValue(fields[name]), Message: "must be a string"})
+			}
+		}
+		if len(vs) > 0 {
+			return vs
+		}
+		*place = p
+	default:
+		if err := json.Unmarshal(t, coords); err != nil {
+			return typeErr
+		}
+	}
+	return nil
+}
+
+// a place is only validated until it got resolved to coordinates
+func validateLocation(path string, p []string, place *Place) []Violation {
+	if p == nil && place != nil {
+		return validatePlace(path, place)
+	}
+	return validatePoint(path, p)
+}
+
+func validatePlace(path string, p *Place) []Violation {
+	address, placeID := strings.TrimSpace(p.Address), strings.TrimSpace(p.PlaceID)
+	switch {
+	case address == "" && placeID == "":
+		return []Violation{{Path: path, Rule: RuleRequired, Message: "address or placeId is required"}}
+	case address != "" && placeID != "":
+		return []Violation{{Path: path, Rule: RulePlace, Value: p, Message: "only one of address and placeId can be given"}}
+	case utf8.RuneCountInString(address) > maxAddressLength:
+		return []Violation{{Path: path + ".address", Rule: RuleLength, Value: p.Address, Message: fmt.Sprintf("must be at most %d characters", maxAddressLength)}}
+	case utf8.RuneCountInString(placeID) > maxPlaceIDLength:
+		return []Violation{{Path: path + ".placeId", Rule: RuleLength, Value: p.PlaceID, Message: fmt.Sprintf("must be at most %d characters", maxPlaceIDLength)}}
+	}
+	return nil
+}
+
+// a point is a [latitude, longitude] pair of decimal strings
+func validatePoint(path string, p []string) []Violation {
+	if p == nil {
+		return []Violation{{Path: path, Rule: RuleRequired, Message: "is required"}}
+	}
+	if len(p) != 2 {
+		return []Violation{{Path: path, Rule: RuleLength, Value: p, Message: "must be [latitude, longitude]"}}
+	}
+	var vs []Violation
+	if !isNumWithRange(p[0], -90, 90) {
+		vs = append(vs, Violation{Path: path + "[0]", Rule: RuleLatitude, Value: p[0], Message: "must be a number between -90 and 90"})
+	}
+	if !isNumWithRange(p[1], -180, 180) {
+		vs = append(vs, Violation{Path: path + "[1]", Rule: RuleLongitude, Value: p[1], Message: "must be a number between -180 and 180"})
+	}
+	return vs
+}
+
+func isNumWithRange(s string, min float64, max float64) bool {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+	return n >= min && n <= max
+}
+
+// duplicateKeys reports the keys of the raw object given more than once, ignoring case as encoding/json matches
+// fields that way
+func duplicateKeys(prefix string, raw json.RawMessage) []Violation {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+	seen := map[string]bool{}
+	var vs []Violation
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return vs
+		}
+		name, _ := t.(string)
+		if seen[strings.ToLower(name)] {
+			vs = append(vs, Violation{Path: prefix + name, Rule: RuleDuplicate, Message: "is given more than once"})
+		}
+		seen[strings.ToLower(name)] = true
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return vs
+		}
+	}
+	return vs
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func rawValue(raw json.RawMessage) interface{} {
+	var v interface{}
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,152 @@
+package request
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePlaceOrderRequestCoordinates(t *testing.T) {
+	r := map[string][]string{
+		createRequest("-90", "-180", "90", "180"):        nil,                    // normal case
+		createRequest("-90.001", "-180", "90", "180"):    {"origin[0] latitude"}, // overflow
+		createRequest("-90", "-180.001", "90", "180"):    {"origin[1] longitude"},
+		createRequest("-90", "-180", "90.000001", "180"): {"destination[0] latitude"},
+		createRequest("-90", "-180", "90", "180.00001"):  {"destination[1] longitude"},
+		createRequest("-90asdf", "-180", "90", "180"):    {"origin[0] latitude"}, // invalid number
+		createRequest("91", "181", "-91", "-181"): {"destination[0] latitude", "destination[1] longitude",
+			"origin[0] latitude", "origin[1] longitude"}, // all reported, by field name
+		`{"origin": ["1"]}`: {"origin length", "destination required"},
+	}
+
+	for k, v := range r {
+		if actual := describe(parse(t, k)); !reflect.DeepEqual(actual, v) {
+			t.Errorf("Parse %s returns %v, expects %v", k, actual, v)
+		}
+	}
+}
+
+func TestParsePlaceOrderRequest(t *testing.T) {
+	r := map[string][]string{
+		`{"origin": ["22.2802", "114.184919"], "destination": ["22.280457", "114.185672"]}`: nil,
+		`{"Origin": ["22.2802", "114.184919"], "DESTINATION": ["22.280457", "114.185672"]}`: nil, // case insensitive as encoding/json
+		`{"origin": ["22.2802", "114.184919", "1"]}`:                                        {"origin length", "destination required"},
+		`{"origin": ["99", "114.184919"], "destination": [1, 2], "weight": 3, "note": ""}`:  {"destination type", "note unknown", "origin[0] latitude", "weight unknown"},
+		`{"origin": null, "destination": 22.2}`:                                             {"destination type", "origin required"},
+		`{}`:                                                                                {"origin required", "destination required"},
+	}
+
+	for k, v := range r {
+		req, vs, err := ParsePlaceOrderRequest(strings.NewReader(k))
+		if err != nil {
+			t.Errorf("Parse %s returns error %v", k, err)
+			continue
+		}
+		if actual := describe(vs); !reflect.DeepEqual(actual, v) {
+			t.Errorf("Parse %s returns %v, expects %v", k, actual, v)
+		}
+		if v == nil && (len(req.Origin) != 2 || len(req.Destination) != 2) {
+			t.Errorf("Parse %s returns %#v", k, req)
+		}
+	}
+}
+
+func TestParsePlaceOrderRequestPlaces(t *testing.T) {
+	r := map[string][]string{
+		`{"origin": "1 Main St", "destination": {"placeId": "abc"}}`:                                                     nil,
+		`{"origin": {"Address": "1 Main St"}, "destination": ["22.2", "114.1"]}`:                                         nil,
+		`{"origin": "  ", "destination": {}}`:                                                                            {"destination required", "origin required"},
+		`{"origin": {"address": "a", "placeId": "b"}, "destination": {"zip": "1"}}`:                                      {"destination.zip unknown", "origin place"},
+		`{"origin": {"address": 1}, "destination": "` + strings.Repeat("a", 513) + `"}`:                                  {"destination.address length", "origin.address type"},
+		`{"origin": "` + strings.Repeat("é", 512) + `", "destination": {"placeId": "` + strings.Repeat("a", 256) + `"}}`: {"destination.placeId length"},
+	}
+
+	for k, v := range r {
+		_, vs, err := ParsePlaceOrderRequest(strings.NewReader(k))
+		if err != nil {
+			t.Errorf("Parse %s returns error %v", k, err)
+			continue
+		}
+		if actual := describe(vs); !reflect.DeepEqual(actual, v) {
+			t.Errorf("Parse %s returns %v, expects %v", k, actual, v)
+		}
+	}
+
+	req, _, _ := ParsePlaceOrderRequest(strings.NewReader(`{"origin": "1 Main St", "destination": {"placeId": "abc"}}`))
+	if req.Origin != nil || *req.OriginPlace != (Place{Address: "1 Main St"}) || *req.DestinationPlace != (Place{PlaceID: "abc"}) {
+		t.Errorf("Unexpected places %#v", req)
+	}
+}
+
+func TestParsePlaceOrderRequestDuplicateKeys(t *testing.T) {
+	r := map[string][]string{
+		`{"origin": ["22.2", "114.1"], "origin": ["22.3", "114.2"], "destination": ["22.2", "114.1"]}`: {"origin duplicate"},
+		`{"Origin": ["22.2", "114.1"], "origin": ["22.2", "114.1"], "destination": ["22.2", "114.1"]}`: {"origin duplicate"},
+		`{"origin": {"address": "a", "Address": "b"}, "destination": ["22.2", "114.1"]}`:               {"origin.Address duplicate"},
+	}
+
+	for k, v := range r {
+		_, vs, err := ParsePlaceOrderRequest(strings.NewReader(k))
+		if err != nil {
+			t.Errorf("Parse %s returns error %v", k, err)
+			continue
+		}
+		if actual := describe(vs); !reflect.DeepEqual(actual, v) {
+			t.Errorf("Parse %s returns %v, expects %v", k, actual, v)
+		}
+	}
+}
+
+func TestParsePlaceOrderRequestMalformed(t *testing.T) {
+	for _, s := range []string{`{"origin": ["-18`, `[]`, `"origin"`, `{} {}`} {
+		if _, _, err := ParsePlaceOrderRequest(strings.NewReader(s)); err == nil {
+			t.Errorf("Parse %s expects error", s)
+		}
+	}
+}
+
+func TestViolationValue(t *testing.T) {
+	vs := parse(t, createRequest("91", "1", "1", "1"))
+
+	if len(vs) != 1 || vs[0].Value != "91" || vs[0].Message == "" {
+		t.Errorf("Expected violation with value 91, got %#v", vs)
+	}
+}
+
+func TestCoordinatesValid(t *testing.T) {
+	r := map[string]bool{
+		createRequest("-90", "-180", "90", "180"):        true,  // normal case
+		createRequest("-90.001", "-180", "90", "180"):    false, // overflow
+		createRequest("-90", "-180.001", "90", "180"):    false,
+		createRequest("-90", "-180", "90.000001", "180"): false,
+		createRequest("-90", "-180", "90", "180.00001"):  false,
+		createRequest("-90asdf", "-180", "90", "180"):    false, // invalid number
+	}
+
+	for k, v := range r {
+		if valid := len(parse(t, k)) == 0; valid != v {
+			t.Errorf("Parse coordinates %s returns %v, expects %v", k, valid, v)
+		}
+	}
+}
+
+func createRequest(oLat string, oLong string, dLat string, dLong string) string {
+	return fmt.Sprintf(`{"origin": [%q, %q], "destination": [%q, %q]}`, oLat, oLong, dLat, dLong)
+}
+
+func parse(t *testing.T, body string) []Violation {
+	_, vs, err := ParsePlaceOrderRequest(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Cannot parse %s: %v", body, err)
+	}
+	return vs
+}
+
+func describe(vs []Violation) []string {
+	var s []string
+	for _, v := range vs {
+		s = append(s, v.Path+" "+v.Rule)
+	}
+	return s
+}
@@ -0,0 +1,183 @@
+package requesthandler
+
+import (
+	"auth"
+	"entity"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"logging"
+	"net/http"
+	"responseutil"
+	"time"
+)
+
+const (
+	DefaultCourierLocationHistory = 100
+
+	courierIDMaxLength = 64
+	maxCourierCapacity = 100
+	// pings from devices with a clock running ahead are accepted up to
+	maxClockSkew = time.Minute
+)
+
+type locationRequest struct {
+	Lat       *float64   `json:"lat"`
+	Lng       *float64   `json:"lng"`
+	Accuracy  *float64   `json:"accuracy"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+// HandleCourierLocation records a GPS ping of the courier. Couriers can only report their own location.
+func (dep *Dependencies) HandleCourierLocation(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	courierID := ps.ByName("id")
+	if courierID == "" || len(courierID) > courierIDMaxLength {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidID, fmt.Sprintf("Courier id must have 1 to %d characters", courierIDMaxLength))
+		return
+	}
+	if p := auth.FromContext(r.Context()); p != nil && p.ID != courierID && !p.HasAnyRole(auth.RoleAdmin) {
+		responseutil.WriteError(w, r, http.StatusForbidden, responseutil.CodeForbidden, "Couriers can only report their own location")
+		return
+	}
+	if !checkContentType(r, w, "application/json") {
+		return
+	}
+
+	var req locationRequest
+	errs, err := decodeObject(r.Body, &req)
+	if err != nil {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not a valid JSON object")
+		return
+	}
+	now := time.Now()
+	if len(errs) == 0 {
+		errs = req.validate(now)
+	}
+	if len(errs) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, "Invalid location", errs...))
+		return
+	}
+
+	loc := &entity.CourierLocation{CourierID: courierID, Lat: *req.Lat, Long: *req.Lng, LocatedAt: now}
+	if req.Accuracy != nil {
+		loc.Accuracy = *req.Accuracy
+	}
+	if req.Timestamp != nil {
+		loc.LocatedAt = *req.Timestamp
+	}
+	if err := dep.Dao.SaveCourierLocation(dep.db(r.Context()), loc, dep.courierLocationHistory()); err != nil {
+		logging.FromContext(r.Context()).WithField("courier_id", courierID).Errorf("Cannot save courier location: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Location could not be saved")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type courierRequest struct {
+	Capacity  *int  `json:"capacity"`
+	Available *bool `json:"available"`
+}
+
+// HandleSaveCourier creates the courier or sets its capacity and availability for auto-dispatch
+func (dep *Dependencies) HandleSaveCourier(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	courierID := ps.ByName("id")
+	if courierID == "" || len(courierID) > courierIDMaxLength {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidID, fmt.Sprintf("Courier id must have 1 to %d characters", courierIDMaxLength))
+		return
+	}
+	if !checkContentType(r, w, "application/json") {
+		return
+	}
+
+	var req courierRequest
+	errs, err := decodeObject(r.Body, &req)
+	if err != nil {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not a valid JSON object")
+		return
+	}
+	if len(errs) == 0 {
+		errs = req.validate()
+	}
+	if len(errs) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, "Invalid courier", errs...))
+		return
+	}
+
+	conn := dep.db(r.Context())
+	c := &entity.Courier{ID: courierID, Capacity: *req.Capacity, Available: *req.Available}
+	if err := dep.Dao.SaveCourier(conn, c); err != nil {
+		logging.FromContext(r.Context()).WithField("courier_id", courierID).Errorf("Cannot save courier: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Courier could not be saved")
+		return
+	}
+	logging.FromContext(r.Context()).WithField("courier_id", courierID).Infof("Courier saved with capacity %d, available %v", c.Capacity, c.Available)
+
+	// with its location, when known
+	var saved entity.Courier
+	dep.Dao.FindCourier(conn, courierID, &saved)
+	if saved.ID == "" {
+		saved = *c
+	}
+	responseutil.WriteJSONToResponse(&saved, w)
+}
+
+// HandleListCouriers returns the couriers with their capacity, availability and last location
+func (dep *Dependencies) HandleListCouriers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	couriers := []entity.Courier{}
+	if err := dep.Dao.FindCouriers(dep.db(r.Context()), &couriers); err != nil {
+		logging.FromContext(r.Context()).Errorf("Cannot list couriers: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Couriers could not be listed")
+		return
+	}
+	responseutil.WriteJSONToResponse(couriers, w)
+}
+
+func (req *courierRequest) validate() []responseutil.FieldError {
+	var errs []responseutil.FieldError
+	if req.Capacity == nil {
+		errs = append(errs, responseutil.FieldError{Field: "capacity", Code: "required", Detail: "is required"})
+	} else if *req.Capacity < 1 || *req.Capacity > maxCourierCapacity {
+		errs = append(errs, responseutil.FieldError{Field: "capacity", Code: "range", Detail: fmt.Sprintf("must be between 1 and %d", maxCourierCapacity), Value: *req.Capacity})
+	}
+	if req.Available == nil {
+		errs = append(errs, responseutil.FieldError{Field: "available", Code: "required", Detail: "is required"})
+	}
+	return errs
+}
+
+func (req *locationRequest) validate(now time.Time) []responseutil.FieldError {
+	var errs []responseutil.FieldError
+	if req.Lat == nil {
+		errs = append(errs, responseutil.FieldError{Field: "lat", Code: "required", Detail: "is required"})
+	} else if *req.Lat < -90 || *req.Lat > 90 {
+		errs = append(errs, responseutil.FieldError{Field: "lat", Code: "latitude", Detail: "must be a number between -90 and 90", Value: *req.Lat})
+	}
+	if req.Lng == nil {
+		errs = append(errs, responseutil.FieldError{Field: "lng", Code: "required", Detail: "is required"})
+	} else if *req.Lng < -180 || *req.Lng > 180 {
+		errs = append(errs, responseutil.FieldError{Field: "lng", Code: "longitude", Detail: "must be a number between -180 and 180", Value: *req.Lng})
+	}
+	if req.Accuracy != nil && *req.Accuracy < 0 {
+		errs = append(errs, responseutil.FieldError{Field: "accuracy", Code: "minimum", Detail: "must not be negative", Value: *req.Accuracy})
+	}
+	if req.Timestamp != nil && req.Timestamp.After(now.Add(maxClockSkew)) {
+		errs = append(errs, responseutil.FieldError{Field: "timestamp", Code: "future", Detail: "must not be in the future", Value: req.Timestamp})
+	}
+	return errs
+}
+
+// courierLocation returns the last known location of the courier, nil if there is none
+func (dep *Dependencies) courierLocation(r *http.Request, courierID string) *entity.Location {
+	var c entity.Courier
+	dep.Dao.FindCourier(dep.db(r.Context()), courierID, &c)
+	if c.ID == "" || c.LocatedAt == nil {
+		return nil
+	}
+	return &entity.Location{Lat: c.Lat, Long: c.Long, Accuracy: c.Accuracy, LocatedAt: *c.LocatedAt}
+}
+
+func (dep *Dependencies) courierLocationHistory() int {
+	if dep.CourierLocationHistory <= 0 {
+		return DefaultCourierLocationHistory
+	}
+	return dep.CourierLocationHistory
+}
@@ -0,0 +1,245 @@
+package requesthandler
+
+import (
+	"auth"
+	"encoding/json"
+	"entity"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"responseutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCourierLocation(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("SaveCourierLocation", mock.Anything, mock.MatchedBy(func(l *entity.CourierLocation) bool {
+		return l.CourierID == "c1" && l.Lat == 22.28 && l.Long == 114.18 && l.Accuracy == 12.5 &&
+			l.LocatedAt.Equal(time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC))
+	}), DefaultCourierLocationHistory).Return(nil)
+	dep := &Dependencies{Dao: dao}
+
+	w := testCourierLocation(dep, "c1", &auth.Principal{ID: "c1", Roles: []string{auth.RoleCourier}},
+		`{"lat": 22.28, "lng": 114.18, "accuracy": 12.5, "timestamp": "2026-10-19T08:00:00Z"}`)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d %s", w.Code, w.Body.String())
+	}
+	dao.AssertExpectations(t)
+}
+
+func TestCourierLocationDefaultsToNow(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("SaveCourierLocation", mock.Anything, mock.MatchedBy(func(l *entity.CourierLocation) bool {
+		return time.Since(l.LocatedAt) < time.Minute
+	}), 5).Return(nil)
+	dep := &Dependencies{Dao: dao, CourierLocationHistory: 5}
+
+	w := testCourierLocation(dep, "c1", nil, `{"lat": 22.28, "lng": 114.18}`)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	dao.AssertExpectations(t)
+}
+
+func TestCourierLocationOfOtherCourier(t *testing.T) {
+	w := testCourierLocation(&Dependencies{}, "c2", &auth.Principal{ID: "c1", Roles: []string{auth.RoleCourier}}, `{"lat": 1, "lng": 1}`)
+
+	checkNonEmptyResponse(t, w, http.StatusForbidden)
+}
+
+func TestCourierLocationInvalid(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	w := testCourierLocation(&Dependencies{}, "c1", nil, `{"lat": 91, "accuracy": -1, "timestamp": "`+future+`"}`)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	var fields []string
+	for _, e := range problem.Errors {
+		fields = append(fields, e.Field+" "+e.Code)
+	}
+	if strings.Join(fields, ",") != "lat latitude,lng required,accuracy minimum,timestamp future" {
+		t.Errorf("Unexpected errors %v", fields)
+	}
+}
+
+func TestCourierLocationMalformed(t *testing.T) {
+	w := testCourierLocation(&Dependencies{}, "c1", nil, `{"lat": "1", "lng": 1, "speed": 3, "timestamp": "noon"}`)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	var p responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	assert.Equal(t, responseutil.CodeValidationFailed, p.Code)
+	assert.Equal(t, []responseutil.FieldError{
+		{Field: "lat", Code: "type", Detail: "must be a number"},
+		{Field: "speed", Code: "unknown", Detail: "unknown field"},
+		{Field: "timestamp", Code: "type", Detail: "must be an RFC 3339 timestamp"},
+	}, p.Errors)
+
+	w = testCourierLocation(&Dependencies{}, "c1", nil, `{"lat": 1,`)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	p = responseutil.Problem{}
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	assert.Equal(t, responseutil.CodeInvalidJSON, p.Code)
+	assert.Equal(t, "Request body is not a valid JSON object", p.Detail)
+	checkNonEmptyResponse(t, testCourierLocation(&Dependencies{}, strings.Repeat("c", 65), nil, `{"lat": 1, "lng": 1}`), http.StatusBadRequest)
+}
+
+func TestCourierLocationDBError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("SaveCourierLocation", mock.Anything, mock.Anything, mock.Anything).Return(errors.New(""))
+
+	checkNonEmptyResponse(t, testCourierLocation(&Dependencies{Dao: dao}, "c1", nil, `{"lat": 1, "lng": 1}`), http.StatusInternalServerError)
+}
+
+func TestGetOrderWithCourierLocation(t *testing.T) {
+	located := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	dao := getMockDaoForGetOrder(&entity.Order{ID: uint64(id), Status: StatusTaken, Version: 2, CourierID: "c1"})
+	dao.On("FindCourier", mock.Anything, "c1", mock.Anything).Return(true, &entity.Courier{ID: "c1", Lat: 22.28, Long: 114.18, LocatedAt: &located})
+
+	w := testGetOrder(t, strconv.Itoa(id), dao, http.StatusOK)
+
+	var o entity.Order
+	_ = json.NewDecoder(w.Body).Decode(&o)
+	if o.CourierLocation == nil || o.CourierLocation.Lat != 22.28 || !o.CourierLocation.LocatedAt.Equal(located) {
+		t.Errorf("Expected courier location, got %#v", o.CourierLocation)
+	}
+	if etag := fmt.Sprintf(`"2-%d"`, located.UnixNano()); w.Header().Get("ETag") != etag {
+		t.Errorf("Expected ETag %s, got %s", etag, w.Header().Get("ETag"))
+	}
+}
+
+func TestGetOrderCourierLocationVisibility(t *testing.T) {
+	located := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	callers := map[*auth.Principal]bool{
+		{ID: "customer-1", Roles: []string{auth.RoleCustomer}}:     true,
+		{ID: "c1", Roles: []string{auth.RoleCourier}}:              true,
+		{ID: "admin", Roles: []string{auth.RoleAdmin}}:             true,
+		{ID: "c2", Roles: []string{auth.RoleCourier}}:              false,
+		{ID: "dispatcher-1", Roles: []string{auth.RoleDispatcher}}: false,
+	}
+
+	for p, visible := range callers {
+		dao := getMockDaoForGetOrder(&entity.Order{ID: uint64(id), Status: StatusTaken, Version: 2, CourierID: "c1", CreatedBy: "customer-1"})
+		dao.On("FindCourier", mock.Anything, "c1", mock.Anything).Return(true, &entity.Courier{ID: "c1", Lat: 22.28, Long: 114.18, LocatedAt: &located})
+		r, _ := http.NewRequest("GET", fmt.Sprintf("/orders/%d", id), nil)
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		w := httptest.NewRecorder()
+
+		(&Dependencies{Dao: dao}).HandleGetOrder(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+		if shown := strings.Contains(w.Body.String(), "courierLocation"); shown != visible {
+			t.Errorf("Expected courier location shown to %s %v, got %v", p.ID, visible, shown)
+		}
+	}
+}
+
+func TestGetOrderWithUnlocatedCourier(t *testing.T) {
+	dao := getMockDaoForGetOrder(&entity.Order{ID: uint64(id), Status: StatusTaken, Version: 2, CourierID: "c1"})
+	dao.On("FindCourier", mock.Anything, "c1", mock.Anything).Return(false, nil)
+
+	w := testGetOrder(t, strconv.Itoa(id), dao, http.StatusOK)
+
+	if strings.Contains(w.Body.String(), "courierLocation") || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected no courier location, got %s", w.Body.String())
+	}
+}
+
+func TestSaveCourier(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("SaveCourier", mock.Anything, &entity.Courier{ID: "c1", Capacity: 3, Available: true}).Return(nil)
+	dao.On("FindCourier", mock.Anything, "c1", mock.Anything).Return(true, &entity.Courier{ID: "c1", Capacity: 3, Available: true, Lat: 22.28})
+
+	w := testSaveCourier(&Dependencies{Dao: dao}, "c1", `{"capacity": 3, "available": true}`)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	dao.AssertExpectations(t)
+	var c entity.Courier
+	_ = json.NewDecoder(w.Body).Decode(&c)
+	if c.ID != "c1" || c.Capacity != 3 || !c.Available || c.Lat != 22.28 {
+		t.Errorf("Unexpected courier %#v", c)
+	}
+}
+
+func TestSaveCourierInvalid(t *testing.T) {
+	w := testSaveCourier(&Dependencies{}, "c1", `{"capacity": 0}`)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	var p responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	if len(p.Errors) != 2 || p.Errors[0].Field != "capacity" || p.Errors[1].Field != "available" {
+		t.Errorf("Expected capacity and available errors, got %#v", p.Errors)
+	}
+	checkNonEmptyResponse(t, testSaveCourier(&Dependencies{}, strings.Repeat("c", 65), `{"capacity": 1, "available": true}`), http.StatusBadRequest)
+}
+
+func TestSaveCourierMalformed(t *testing.T) {
+	w := testSaveCourier(&Dependencies{}, "c1", `{"capacity": 1.5, "available": "yes", "lat": 1}`)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	var p responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	assert.Equal(t, responseutil.CodeValidationFailed, p.Code)
+	assert.Equal(t, []responseutil.FieldError{
+		{Field: "available", Code: "type", Detail: "must be a boolean"},
+		{Field: "capacity", Code: "type", Detail: "must be a number"},
+		{Field: "lat", Code: "unknown", Detail: "unknown field"},
+	}, p.Errors)
+
+	w = testSaveCourier(&Dependencies{}, "c1", `[]`)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	p = responseutil.Problem{}
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	assert.Equal(t, responseutil.CodeInvalidJSON, p.Code)
+	assert.Equal(t, "Request body is not a valid JSON object", p.Detail)
+}
+
+func TestSaveCourierDBError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("SaveCourier", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+	checkNonEmptyResponse(t, testSaveCourier(&Dependencies{Dao: dao}, "c1", `{"capacity": 1, "available": false}`), http.StatusInternalServerError)
+}
+
+func TestListCouriers(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindCouriers", mock.Anything, mock.Anything).Return([]entity.Courier{{ID: "c1", Capacity: 1}}, nil)
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Dao: dao}).HandleListCouriers(w, httptest.NewRequest("GET", "/admin/couriers", nil), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"id":"c1"`) {
+		t.Errorf("Unexpected couriers %s", w.Body.String())
+	}
+}
+
+func testSaveCourier(dep *Dependencies, courierID string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("PUT", "/admin/couriers/"+courierID, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	dep.HandleSaveCourier(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: courierID}})
+	return w
+}
+
+func testCourierLocation(dep *Dependencies, courierID string, p *auth.Principal, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/couriers/"+courierID+"/location", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if p != nil {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+	}
+	w := httptest.NewRecorder()
+	dep.HandleCourierLocation(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: courierID}})
+	return w
+}
@@ -0,0 +1,161 @@
+package requesthandler
+
+import (
+	"context"
+	"entity"
+	"geo"
+	"logging"
+	"request"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultDispatchCandidates     = 5
+	DefaultDispatchMaxLocationAge = 10 * time.Minute
+	DefaultDispatchQueueSize      = 1000
+)
+
+// DispatchConfig configures the automatic assignment of orders to couriers
+type DispatchConfig struct {
+	// dispatch new orders as they are placed, through Queue
+	OnCreate bool
+	// new orders waiting for RunDispatchQueue
+	Queue chan entity.Order
+	// how many couriers nearest as the crow flies are compared by route distance
+	Candidates int
+	// couriers located longer ago are not considered
+	MaxLocationAge time.Duration
+}
+
+// DispatchPending tries to assign up to limit unassigned orders, oldest first, returning how many were assigned
+func (dep *Dependencies) DispatchPending(ctx context.Context, limit int) (int, error) {
+	var orders []entity.Order
+	if err := dep.Dao.FindOrdersWithStatus(dep.db(ctx), StatusUnassigned, limit, &orders); err != nil {
+		return 0, err
+	}
+	assigned := 0
+	for i := range orders {
+		ok, err := dep.DispatchOrder(ctx, &orders[i])
+		if err != nil {
+			return assigned, err
+		}
+		if ok {
+			assigned++
+		}
+	}
+	return assigned, nil
+}
+
+// queueDispatch hands order to RunDispatchQueue, so placing orders does not wait for the route lookups of the
+// candidates
+func (dep *Dependencies) queueDispatch(ctx context.Context, order *entity.Order) {
+	select {
+	case dep.Dispatch.Queue <- *order:
+	default:
+		logging.FromContext(ctx).WithField("order_id", order.ID).Warn("Dispatch queue is full, order is left for couriers or the periodic dispatch")
+	}
+}
+
+// RunDispatchQueue dispatches the orders queued on creation until ctx is done
+func (dep *Dependencies) RunDispatchQueue(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case order := <-dep.Dispatch.Queue:
+			if _, err := dep.DispatchOrder(ctx, &order); err != nil {
+				logging.FromContext(ctx).WithField("order_id", order.ID).Errorf("Cannot dispatch order: %v", err)
+			}
+		}
+	}
+}
+
+// DispatchOrder assigns order to the available courier with spare capacity whose route to the order origin is the
+// shortest. It returns false when there is no such courier, or the order or the courier's capacity was taken
+// meanwhile.
+func (dep *Dependencies) DispatchOrder(ctx context.Context, order *entity.Order) (bool, error) {
+	conn := dep.db(ctx)
+	var couriers []entity.Courier
+	if err := dep.Dao.FindAvailableCouriers(conn, time.Now().Add(-dep.Dispatch.maxLocationAge()), &couriers); err != nil {
+		return false, err
+	}
+	load, err := dep.Dao.CountOrdersByCourier(conn, StatusTaken)
+	if err != nil {
+		return false, err
+	}
+
+	// orders are stored with validated coordinates
+	origin, _ := geo.ParsePoint([]string{order.OriginsLat, order.OriginsLong})
+	candidates := nearestWithCapacity(couriers, load, origin, dep.Dispatch.candidates())
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	best, bestDistance := -1, 0
+	for i, c := range candidates {
+		d := dep.courierDistance(ctx, &c, order)
+		if d >= 0 && (best == -1 || d < bestDistance) {
+			best, bestDistance = i, d
+		}
+	}
+	if best == -1 {
+		return false, nil
+	}
+
+	res := dep.takeOrder(ctx, order, candidates[best].ID, true)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// courierDistance returns the route distance from the courier to the order origin, falling back to the distance as
+// the crow flies when the provider cannot tell. -1 when the courier cannot reach the origin.
+func (dep *Dependencies) courierDistance(ctx context.Context, c *entity.Courier, order *entity.Order) int {
+	from := []string{formatCoordinate(c.Lat), formatCoordinate(c.Long)}
+	to := []string{order.OriginsLat, order.OriginsLong}
+	route, err := dep.MapHelper.GetRoute(ctx, &request.PlaceOrderRequest{Origin: from, Destination: to}, dep.Map)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Cannot find route of courier %s, using straight distance: %v", c.ID, err)
+		origin, _ := geo.ParsePoint(to)
+		return int(geo.Distance(geo.Point{Lat: c.Lat, Long: c.Long}, origin))
+	}
+	return route.DistanceMeters
+}
+
+// nearestWithCapacity returns up to n couriers with spare capacity, nearest to p as the crow flies first
+func nearestWithCapacity(couriers []entity.Courier, load map[string]int, p geo.Point, n int) []entity.Courier {
+	var free []entity.Courier
+	for _, c := range couriers {
+		if load[c.ID] < c.Capacity {
+			free = append(free, c)
+		}
+	}
+	sort.SliceStable(free, func(i, j int) bool {
+		return geo.Distance(geo.Point{Lat: free[i].Lat, Long: free[i].Long}, p) < geo.Distance(geo.Point{Lat: free[j].Lat, Long: free[j].Long}, p)
+	})
+	if len(free) > n {
+		free = free[:n]
+	}
+	return free
+}
+
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (dc *DispatchConfig) candidates() int {
+	if dc.Candidates <= 0 {
+		return DefaultDispatchCandidates
+	}
+	return dc.Candidates
+}
+
+func (dc *DispatchConfig) maxLocationAge() time.Duration {
+	if dc.MaxLocationAge <= 0 {
+		return DefaultDispatchMaxLocationAge
+	}
+	return dc.MaxLocationAge
+}
@@ -0,0 +1,187 @@
+package requesthandler
+
+import (
+	"context"
+	"distancehelper"
+	"entity"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"request"
+	"testing"
+)
+
+// origin of normalCoordinates is at 22.2802, 114.184919
+var (
+	near    = entity.Courier{ID: "near", Capacity: 1, Available: true, Lat: 22.2805, Long: 114.1850}
+	nearer  = entity.Courier{ID: "nearer", Capacity: 2, Available: true, Lat: 22.2803, Long: 114.1849}
+	faraway = entity.Courier{ID: "faraway", Capacity: 1, Available: true, Lat: 22.40, Long: 114.10}
+)
+
+func dispatchOrder() *entity.Order {
+	return &entity.Order{ID: uint64(id), Status: StatusUnassigned, Version: 1, OriginsLat: "22.2802", OriginsLong: "114.184919",
+		DestLat: "22.280457", DestLong: "114.185672"}
+}
+
+func getMockDaoForDispatch(couriers []entity.Courier, load map[string]int) *GormDBMock {
+	dao := &GormDBMock{}
+	dao.On("FindAvailableCouriers", mock.Anything, mock.Anything, mock.Anything).Return(couriers, nil)
+	dao.On("CountOrdersByCourier", mock.Anything, StatusTaken).Return(load, nil)
+	dao.On("AssignOrder", mock.Anything, mock.Anything, StatusTaken, StatusUnassigned).Return(&gorm.DB{RowsAffected: 1})
+	return dao
+}
+
+// routes from each courier are as long as given by meters
+func getMockMapForDispatch(meters map[string]int, err error) *GMapHelperMock {
+	ghm := &GMapHelperMock{}
+	for _, c := range []entity.Courier{near, nearer, faraway} {
+		c := c
+		ghm.On("GetRoute", mock.Anything, mock.MatchedBy(func(co *request.PlaceOrderRequest) bool {
+			return courierAt(&c, co.Origin)
+		}), mock.Anything).Return(distancehelper.Route{DistanceMeters: meters[c.ID]}, err)
+	}
+	return ghm
+}
+
+func courierAt(c *entity.Courier, p []string) bool {
+	return len(p) == 2 && p[0] == formatCoordinate(c.Lat) && p[1] == formatCoordinate(c.Long)
+}
+
+func TestDispatchShortestRoute(t *testing.T) {
+	// one way streets make the nearer courier drive further
+	dao := getMockDaoForDispatch([]entity.Courier{faraway, nearer, near}, map[string]int{})
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForDispatch(map[string]int{"near": 300, "nearer": 900, "faraway": 20000}, nil)}
+	order := dispatchOrder()
+
+	ok, err := dep.DispatchOrder(context.Background(), order)
+
+	if !ok || err != nil || order.CourierID != "near" || order.Status != StatusTaken {
+		t.Errorf("Expected order taken by near, got %v %v %#v", ok, err, order)
+	}
+}
+
+func TestDispatchCapacity(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{near, nearer, faraway}, map[string]int{"near": 1, "nearer": 2})
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForDispatch(map[string]int{"near": 300, "nearer": 900, "faraway": 20000}, nil)}
+	order := dispatchOrder()
+
+	ok, _ := dep.DispatchOrder(context.Background(), order)
+
+	if !ok || order.CourierID != "faraway" {
+		t.Errorf("Expected order taken by faraway, got %#v", order)
+	}
+}
+
+func TestDispatchCandidates(t *testing.T) {
+	// only the nearest as the crow flies is compared by route
+	dao := getMockDaoForDispatch([]entity.Courier{near, nearer, faraway}, map[string]int{})
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForDispatch(map[string]int{"near": 300, "nearer": 900, "faraway": 100}, nil),
+		Dispatch: DispatchConfig{Candidates: 1}}
+	order := dispatchOrder()
+
+	ok, _ := dep.DispatchOrder(context.Background(), order)
+
+	if !ok || order.CourierID != "nearer" {
+		t.Errorf("Expected order taken by nearer, got %#v", order)
+	}
+}
+
+func TestDispatchRouteErrorUsesStraightDistance(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{faraway, near, nearer}, map[string]int{})
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForDispatch(nil, distancehelper.ErrCircuitOpen)}
+	order := dispatchOrder()
+
+	ok, _ := dep.DispatchOrder(context.Background(), order)
+
+	if !ok || order.CourierID != "nearer" {
+		t.Errorf("Expected order taken by nearer, got %#v", order)
+	}
+}
+
+func TestDispatchNoCourier(t *testing.T) {
+	dao := getMockDaoForDispatch(nil, map[string]int{})
+	dep := &Dependencies{Dao: dao}
+
+	ok, err := dep.DispatchOrder(context.Background(), dispatchOrder())
+
+	if ok || err != nil {
+		t.Errorf("Expected no dispatch, got %v %v", ok, err)
+	}
+	dao.AssertNotCalled(t, "AssignOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDispatchTakenMeanwhile(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindAvailableCouriers", mock.Anything, mock.Anything, mock.Anything).Return([]entity.Courier{near}, nil)
+	dao.On("CountOrdersByCourier", mock.Anything, StatusTaken).Return(map[string]int{}, nil)
+	dao.On("AssignOrder", mock.Anything, mock.Anything, StatusTaken, StatusUnassigned).Return(&gorm.DB{RowsAffected: 0})
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForDispatch(map[string]int{"near": 300}, nil)}
+
+	ok, err := dep.DispatchOrder(context.Background(), dispatchOrder())
+
+	if ok || err != nil {
+		t.Errorf("Expected no dispatch, got %v %v", ok, err)
+	}
+}
+
+func TestDispatchPending(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{near}, map[string]int{})
+	dao.On("FindOrdersWithStatus", mock.Anything, StatusUnassigned, 10, mock.Anything).Return([]entity.Order{*dispatchOrder()}, nil)
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForDispatch(map[string]int{"near": 300}, nil)}
+
+	n, err := dep.DispatchPending(context.Background(), 10)
+
+	if n != 1 || err != nil {
+		t.Errorf("Expected 1 order dispatched, got %d %v", n, err)
+	}
+}
+
+func TestDispatchPendingError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindOrdersWithStatus", mock.Anything, StatusUnassigned, 10, mock.Anything).Return([]entity.Order{}, errors.New(""))
+	dep := &Dependencies{Dao: dao}
+
+	if _, err := dep.DispatchPending(context.Background(), 10); err == nil {
+		t.Errorf("Expected error")
+	}
+}
+
+func TestNewOrderQueuedForDispatch(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("CreateOrder", mock.Anything, mock.Anything).Return(&gorm.DB{}, uint64(id))
+	dao.On("FindAvailableCouriers", mock.Anything, mock.Anything, mock.Anything).Return([]entity.Courier{near}, nil)
+	dao.On("CountOrdersByCourier", mock.Anything, StatusTaken).Return(map[string]int{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	dao.On("AssignOrder", mock.Anything, mock.Anything, StatusTaken, StatusUnassigned).Return(&gorm.DB{RowsAffected: 1}).Run(func(mock.Arguments) { cancel() })
+	ghm := getMockMapForDispatch(map[string]int{"near": 300}, nil)
+	ghm.On("GetRoute", mock.Anything, mock.Anything, mock.Anything).Return(distancehelper.Route{DistanceMeters: distance}, nil)
+	dep := &Dependencies{Dao: dao, MapHelper: ghm, Dispatch: DispatchConfig{OnCreate: true, Queue: make(chan entity.Order, 1)}}
+	w := httptest.NewRecorder()
+
+	dep.HandleNewOrder(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	dao.AssertNotCalled(t, "AssignOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	if len(dep.Dispatch.Queue) != 1 {
+		t.Fatalf("Expected order to be queued")
+	}
+
+	dep.RunDispatchQueue(ctx)
+
+	dao.AssertCalled(t, "AssignOrder", mock.Anything, mock.MatchedBy(func(o *entity.Order) bool {
+		return o.ID == uint64(id) && o.CourierID == "near"
+	}), StatusTaken, StatusUnassigned)
+}
+
+func TestNewOrderDispatchQueueFull(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("CreateOrder", mock.Anything, mock.Anything).Return(&gorm.DB{}, uint64(id))
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(distance, nil), Dispatch: DispatchConfig{OnCreate: true, Queue: make(chan entity.Order)}}
+	w := httptest.NewRecorder()
+
+	dep.HandleNewOrder(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+}
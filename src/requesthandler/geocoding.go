@@ -0,0 +1,94 @@
+package requesthandler
+
+import (
+	"context"
+	"geo"
+	"geocoder"
+	"logging"
+	"net/http"
+	"request"
+	"responseutil"
+)
+
+// formatted addresses are stored in varchar(512), providers may return longer ones
+const maxFormattedAddressLength = 512
+
+// formatted addresses of the order points, as far as they are known
+type pointAddresses struct {
+	origin      string
+	destination string
+}
+
+// resolvePlaces fills the coordinates of the points given as address or place id, returning the formatted
+// addresses found on the way. When a point cannot be resolved the problem is written and false is returned.
+func (dep *Dependencies) resolvePlaces(w http.ResponseWriter, r *http.Request, req *request.PlaceOrderRequest) (*pointAddresses, bool) {
+	addresses := &pointAddresses{}
+	points := []struct {
+		path    string
+		place   *request.Place
+		coords  *[]string
+		address *string
+	}{
+		{"origin", req.OriginPlace, &req.Origin, &addresses.origin},
+		{"destination", req.DestinationPlace, &req.Destination, &addresses.destination},
+	}
+
+	var notFound []responseutil.FieldError
+	for _, p := range points {
+		if p.place == nil {
+			continue
+		}
+		if dep.Geocoder == nil {
+			responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, "Invalid order request",
+				responseutil.FieldError{Field: p.path, Code: "unsupported", Detail: "addresses are not supported, use [latitude, longitude]", Value: p.place}))
+			return nil, false
+		}
+		loc, err := dep.Geocoder.Geocode(r.Context(), geocoder.Query{Address: p.place.Address, PlaceID: p.place.PlaceID})
+		if err == geocoder.ErrNotFound {
+			notFound = append(notFound, responseutil.FieldError{Field: p.path, Code: "notFound", Detail: "cannot be resolved to a location", Value: p.place})
+			continue
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Cannot geocode %s: %v", p.path, err)
+			responseutil.WriteError(w, r, http.StatusServiceUnavailable, responseutil.CodeGeocodingUnavailable, "Geocoding provider unavailable, please retry later.")
+			return nil, false
+		}
+		*p.coords = loc.LatLong()
+		*p.address = truncate(loc.Address, maxFormattedAddressLength)
+	}
+
+	if len(notFound) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeAddressNotFound, "Address not found", notFound...))
+		return nil, false
+	}
+	return addresses, true
+}
+
+// reverseGeocode fills the addresses still missing with the ReverseGeocoder. It is best effort, the order is
+// placed without address when the provider cannot tell.
+func (dep *Dependencies) reverseGeocode(ctx context.Context, t *trip) {
+	if dep.ReverseGeocoder == nil {
+		return
+	}
+	points := []struct {
+		path    string
+		point   geo.Point
+		address *string
+	}{
+		{"origin", t.origin, &t.addresses.origin},
+		{"destination", t.destination, &t.addresses.destination},
+	}
+	for _, p := range points {
+		if *p.address != "" {
+			continue
+		}
+		loc, err := dep.ReverseGeocoder.ReverseGeocode(ctx, p.point.Lat, p.point.Long)
+		if err != nil {
+			if err != geocoder.ErrNotFound {
+				logging.FromContext(ctx).Warnf("Cannot reverse geocode %s: %v", p.path, err)
+			}
+			continue
+		}
+		*p.address = truncate(loc.Address, maxFormattedAddressLength)
+	}
+}
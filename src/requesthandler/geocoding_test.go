@@ -0,0 +1,128 @@
+package requesthandler
+
+import (
+	"context"
+	"distancehelper"
+	"encoding/json"
+	"entity"
+	"errors"
+	"geocoder"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"request"
+	"responseutil"
+	"strings"
+	"testing"
+)
+
+var stubGeocoder = &geocoder.Stub{Places: map[string]geocoder.Location{
+	"1 queen's road central": {Lat: 22.2802, Long: 114.184919},
+	"place:taipei-101":       {Lat: 25.033964, Long: 121.564468, Address: "Taipei 101"},
+}}
+
+type failingGeocoder struct{}
+
+func (failingGeocoder) Geocode(context.Context, geocoder.Query) (*geocoder.Location, error) {
+	return nil, errors.New("timeout")
+}
+
+func (failingGeocoder) ReverseGeocode(context.Context, float64, float64) (*geocoder.Location, error) {
+	return nil, errors.New("timeout")
+}
+
+const addressOrder = `{"origin": "1 Queen's Road Central", "destination": {"placeId": "taipei-101"}}`
+
+func TestNewOrderWithAddresses(t *testing.T) {
+	ghm := &GMapHelperMock{}
+	ghm.On("GetRoute", mock.Anything, mock.MatchedBy(func(co *request.PlaceOrderRequest) bool {
+		return strings.Join(co.Origin, ",") == "22.2802,114.184919" && strings.Join(co.Destination, ",") == "25.033964,121.564468"
+	}), mock.Anything).Return(distancehelper.Route{DistanceMeters: distance}, nil)
+	dao := getMockDaoForNewOrder(id, nil)
+
+	w := testNewOrderWithGeocoder(t, addressOrder, ghm, dao, stubGeocoder, http.StatusOK)
+
+	var order entity.Order
+	_ = json.NewDecoder(w.Body).Decode(&order)
+	if order.OriginAddress != "1 Queen's Road Central" || order.DestPlaceID != "taipei-101" || order.DestFormattedAddress != "Taipei 101" {
+		t.Errorf("Expect addresses to be stored, got %#v", order)
+	}
+	created := dao.Calls[0].Arguments.Get(1).(*entity.Order)
+	if created.OriginsLat != "22.2802" || created.DestLong != "121.564468" {
+		t.Errorf("Expect resolved coordinates to be stored, got %#v", created)
+	}
+}
+
+func TestNewOrderAddressNotFound(t *testing.T) {
+	w := testNewOrderWithGeocoder(t, `{"origin": "nowhere", "destination": {"placeId": "unknown"}}`, nil, nil, stubGeocoder, http.StatusBadRequest)
+
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	if problem.Code != responseutil.CodeAddressNotFound || len(problem.Errors) != 2 {
+		t.Errorf("Expect %s for both points, got %#v", responseutil.CodeAddressNotFound, problem)
+	}
+}
+
+func TestNewOrderAddressWithoutGeocoder(t *testing.T) {
+	testNewOrderWithGeocoder(t, addressOrder, nil, nil, nil, http.StatusBadRequest)
+}
+
+func TestNewOrderGeocoderError(t *testing.T) {
+	testNewOrderWithGeocoder(t, addressOrder, nil, nil, failingGeocoder{}, http.StatusServiceUnavailable)
+}
+
+func TestNewOrderReverseGeocoded(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: getMockDaoForNewOrder(id, nil), MapHelper: getMockMapForNewOrder(distance, nil), ReverseGeocoder: stubGeocoder}
+
+	dep.HandleNewOrder(w, newOrderRequest(`{"origin": ["22.2802", "114.184919"], "destination": ["1", "1"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	var order entity.Order
+	_ = json.NewDecoder(w.Body).Decode(&order)
+	if order.OriginFormattedAddress != "1 queen's road central" || order.DestFormattedAddress != "" {
+		t.Errorf("Expect origin address only, got %#v", order)
+	}
+}
+
+func TestNewOrderLongFormattedAddress(t *testing.T) {
+	w := httptest.NewRecorder()
+	long := &geocoder.Stub{Places: map[string]geocoder.Location{
+		"1 queen's road central": {Lat: 22.2802, Long: 114.184919, Address: strings.Repeat("道", 600)},
+	}}
+	dep := &Dependencies{Dao: getMockDaoForNewOrder(id, nil), MapHelper: getMockMapForNewOrder(distance, nil), ReverseGeocoder: long}
+
+	dep.HandleNewOrder(w, newOrderRequest(`{"origin": ["22.2802", "114.184919"], "destination": ["1", "1"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	var order entity.Order
+	_ = json.NewDecoder(w.Body).Decode(&order)
+	if order.OriginFormattedAddress != strings.Repeat("道", maxFormattedAddressLength) {
+		t.Errorf("Expect address cut to %d characters, got %d", maxFormattedAddressLength, len([]rune(order.OriginFormattedAddress)))
+	}
+}
+
+func TestNewOrderReverseGeocoderError(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: getMockDaoForNewOrder(id, nil), MapHelper: getMockMapForNewOrder(distance, nil), ReverseGeocoder: failingGeocoder{}}
+
+	dep.HandleNewOrder(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+}
+
+func testNewOrderWithGeocoder(t *testing.T, body string, m *GMapHelperMock, dao *GormDBMock, g geocoder.Geocoder, status int) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: dao, MapHelper: m, Geocoder: g}
+
+	dep.HandleNewOrder(w, newOrderRequest(body), nil)
+
+	checkNonEmptyResponse(t, w, status)
+	return w
+}
+
+func newOrderRequest(body string) *http.Request {
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
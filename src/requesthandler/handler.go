@@ -1,18 +1,25 @@
 package requesthandler
 
 import (
+	"auth"
+	"context"
 	db "dao"
 	"distancehelper"
 	"encoding/json"
 	"entity"
+	"eventbus"
 	"fmt"
+	"geocoder"
+	"geofence"
 	"github.com/jinzhu/gorm"
 	"github.com/julienschmidt/httprouter"
+	"logging"
 	"net/http"
-	"request"
+	"outbox"
+	"pricing"
 	"responseutil"
-	"strconv"
-	"strings"
+	"time"
+	"webhook"
 )
 
 const (
@@ -30,107 +37,231 @@ type Dependencies struct {
 	Dao       db.DAO
 	Map       distancehelper.GMap
 	MapHelper distancehelper.MapHelper
+	// resolves addresses and place ids of new orders, nil when only coordinates are accepted
+	Geocoder geocoder.Geocoder
+	// finds the addresses of new orders placed with coordinates, optional
+	ReverseGeocoder geocoder.ReverseGeocoder
+	// prices new orders and quotes, optional
+	Pricing *pricing.Rules
+	// service areas new orders must be in, orders are accepted anywhere when nil or empty
+	Zones *geofence.Zones
+	// checked for new orders and quotes
+	RouteRules RouteRules
+	Dispatch   DispatchConfig
+	// pushes new orders to the couriers nearby, optional
+	Offers   *Offers
+	Webhooks WebhookConfig
+	// the outbox is relayed to, in order
+	EventSinks []outbox.Named
+	// order changes for the streams of this process
+	Events *eventbus.Bus
+	// how many locations are kept per courier
+	CourierLocationHistory int
+
+	// how long a stored Idempotency-Key response is replayed
+	IdempotencyWindow time.Duration
+}
+
+// db returns the connection carrying the request context
+func (dep *Dependencies) db(ctx context.Context) *gorm.DB {
+	return db.WithContext(dep.DB, ctx)
 }
 
 func (dep *Dependencies) HandleListOrder(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	// get query params
 	page, limit, err := getPageAndLimit(r)
 	if len(err) > 0 {
-		responseutil.WriteJSONErrorResponse(w, strings.Join(err, "; "), http.StatusBadRequest)
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeInvalidQuery, "Invalid query parameters", err...))
 		return
 	}
 
 	// query
 	var orders []entity.Order
-	dep.Dao.FindWithLimitAndOffset(dep.DB, limit, (page-1)*limit, &orders)
+	dep.Dao.FindWithLimitAndOffset(dep.db(r.Context()), limit, (page-1)*limit, &orders)
 
 	// return result to user
+	etag := ordersETag(orders)
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	responseutil.WriteJSONToResponse(&orders, w)
 }
 
+func (dep *Dependencies) HandleGetOrder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// check input
+	id, ok := getOrderId(w, r, ps)
+	if !ok {
+		return
+	}
+
+	// get entity
+	var order entity.Order
+	dep.Dao.FindFirstWithId(dep.db(r.Context()), id, &order)
+	if order.ID == 0 || !canAccessOrder(r, &order) {
+		responseutil.WriteError(w, r, http.StatusNotFound, responseutil.CodeOrderNotFound, fmt.Sprintf("Order id %d not found", id))
+		return
+	}
+
+	// the location of the courier changes without the order
+	etag := orderETag(&order)
+	if order.CourierID != "" && canSeeCourierLocation(r, &order) {
+		order.CourierLocation = dep.courierLocation(r, order.CourierID)
+		if loc := order.CourierLocation; loc != nil {
+			etag = fmt.Sprintf(`"%d-%d"`, order.Version, loc.LocatedAt.UnixNano())
+		}
+	}
+
+	// return result to user
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	responseutil.WriteJSONToResponse(&order, w)
+}
+
 func (dep *Dependencies) HandleTakeOrder(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// check input
-	ids := ps.ByName("id")
-	id, err := strconv.Atoi(ids)
-	if err != nil || id < 1 {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Invalid Id: %s", ids), http.StatusBadRequest)
+	id, ok := getOrderId(w, r, ps)
+	if !ok {
 		return
 	}
 
 	// get entity
 	var order entity.Order
-	dep.Dao.FindFirstWithIdAndStatus(dep.DB, StatusUnassigned, id, &order)
-	if order.ID == 0 {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Order id %d with status %s not found", id, StatusUnassigned), http.StatusNotFound)
+	dep.Dao.FindFirstWithIdAndStatus(dep.db(r.Context()), StatusUnassigned, id, &order)
+	if order.ID == 0 || !canAccessOrder(r, &order) {
+		responseutil.WriteError(w, r, http.StatusNotFound, responseutil.CodeOrderNotFound, fmt.Sprintf("Order id %d with status %s not found", id, StatusUnassigned))
+		return
+	}
+
+	// reject when the client has seen an older version
+	if !ifMatch(r, orderETag(&order)) {
+		responseutil.WriteError(w, r, http.StatusPreconditionFailed, responseutil.CodeOrderModified, fmt.Sprintf("Order id %d has been modified, current version is %d", id, order.Version))
 		return
 	}
 
 	// get body and check JSON
 	var jsonReq TakeOrder
-	err = json.NewDecoder(r.Body).Decode(&jsonReq)
+	err := json.NewDecoder(r.Body).Decode(&jsonReq)
 	if err != nil {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Cannot parse JSON body: %v", err), http.StatusBadRequest)
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not valid JSON")
 		return
 	}
 	// only accept taken as status
 	if jsonReq.Status != StatusTaken {
-		responseutil.WriteJSONErrorResponse(w, "Invalid request status", http.StatusBadRequest)
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeInvalidStatus, "Invalid request status",
+			responseutil.FieldError{Field: "status", Code: "enum", Detail: fmt.Sprintf("must be %s", StatusTaken), Value: jsonReq.Status}))
 		return
 	}
 
-	// to avoid multiple updates, we add the where check
-	updateResult := dep.Dao.UpdateOrderStatus(dep.DB, &order, StatusTaken, StatusUnassigned)
+	// record who took it
+	var courierID string
+	if p := auth.FromContext(r.Context()); p != nil {
+		courierID = p.ID
+	}
+
+	updateResult := dep.takeOrder(r.Context(), &order, courierID, false)
 	if updateResult.RowsAffected < 1 {
 		if updateResult.Error != nil {
-			responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Update error: %v", updateResult.Error), http.StatusInternalServerError)
+			logging.FromContext(r.Context()).WithField("order_id", id).Errorf("Cannot take order: %v", updateResult.Error)
+			responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Order could not be updated")
+		} else if r.Header.Get("If-Match") != "" {
+			responseutil.WriteError(w, r, http.StatusPreconditionFailed, responseutil.CodeOrderModified, fmt.Sprintf("Order id %d has been modified", id))
 		} else {
-			responseutil.WriteJSONErrorResponse(w, "Not updated - perhaps updated moment ago?", http.StatusBadRequest)
+			responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeOrderNotUpdated, "Not updated - perhaps updated moment ago?")
 		}
 		return
 	} else {
+		w.Header().Set("ETag", orderETag(&order))
 		responseutil.WriteJSONToResponse(&TakeOrder{StatusSuccess}, w)
 	}
 }
 
-func (dep *Dependencies) HandleNewOrder(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	if !checkContentType(r, w, "application/json") {
-		return
+// canAccessOrder returns whether the caller may read or change order. Customers are limited to the orders they
+// placed, so others are reported as not found; couriers, dispatchers and admins work on all orders.
+func canAccessOrder(r *http.Request, order *entity.Order) bool {
+	p := auth.FromContext(r.Context())
+	if p == nil || p.HasAnyRole(auth.RoleCourier, auth.RoleDispatcher) {
+		return true
 	}
+	return order.CreatedBy == p.ID
+}
 
-	// get body and check JSON
-	var orderRequest request.PlaceOrderRequest
-	err := json.NewDecoder(r.Body).Decode(&orderRequest)
-	if err != nil || len(orderRequest.Origin) != 2 || len(orderRequest.Destination) != 2 {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Cannot parse JSON body: %v", err), http.StatusBadRequest)
-		return
-	}
-	// check coordinates
-	if !coordinatesValid(&orderRequest) {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Incorrect input - must be valid latitudes and longitudes: %v", &orderRequest), http.StatusBadRequest)
-		return
+// canSeeCourierLocation returns whether the caller may follow the courier of order: the customer who placed it, the
+// courier itself and admins
+func canSeeCourierLocation(r *http.Request, order *entity.Order) bool {
+	p := auth.FromContext(r.Context())
+	if p == nil {
+		return true
 	}
+	return p.ID == order.CreatedBy || p.ID == order.CourierID || p.HasAnyRole(auth.RoleAdmin)
+}
 
-	// Get distance
-	dist, err := dep.MapHelper.GetDistanceMeters(&orderRequest, dep.Map)
-	if err != nil {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Canno find distance: %v", err), http.StatusInternalServerError)
-		return
+// takeOrder moves order from UNASSIGNED to TAKEN by courierID. Couriers taking orders and auto-dispatch both go
+// through here, the latter with checkCapacity so the courier is only assigned while it has room for the order.
+func (dep *Dependencies) takeOrder(ctx context.Context, order *entity.Order, courierID string, checkCapacity bool) *gorm.DB {
+	order.CourierID = courierID
+	update := dep.Dao.UpdateOrderStatus
+	if checkCapacity {
+		update = dep.Dao.AssignOrder
 	}
-	if dist == -1 {
-		responseutil.WriteJSONErrorResponse(w, "Canno find distance, please check your input.", http.StatusBadRequest)
+	// to avoid multiple updates, we add the where check
+	updateResult := update(dep.db(ctx), order, StatusTaken, StatusUnassigned,
+		orderEvents(StatusUnassigned, webhook.EventOrderTaken, webhook.EventOrderStatusChanged))
+	if updateResult.Error == nil && updateResult.RowsAffected > 0 {
+		logging.FromContext(ctx).WithField("order_id", order.ID).WithField("courier_id", courierID).Info("Order taken")
+		dep.publishOrder(webhook.EventOrderTaken, order)
+		dep.Offers.withdraw(order.ID, courierID)
+	}
+	return updateResult
+}
+
+func (dep *Dependencies) HandleNewOrder(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	trip, ok := dep.planTrip(w, r)
+	if !ok {
 		return
 	}
+	dep.reverseGeocode(r.Context(), trip)
 
 	// save orderRequest in db
-	res := &entity.Order{Distance: dist, Status: "UNASSIGNED",
+	logger := logging.FromContext(r.Context())
+	orderRequest := trip.req
+	res := &entity.Order{Distance: trip.route.DistanceMeters, Duration: int(trip.route.Duration.Seconds()), Status: StatusUnassigned, Version: 1,
 		OriginsLat: orderRequest.Origin[0], OriginsLong: orderRequest.Origin[1],
-		DestLat: orderRequest.Destination[0], DestLong: orderRequest.Destination[1]}
-	createResult := dep.Dao.CreateOrder(dep.DB, res)
+		DestLat: orderRequest.Destination[0], DestLong: orderRequest.Destination[1],
+		OriginFormattedAddress: trip.addresses.origin, DestFormattedAddress: trip.addresses.destination, Zone: trip.zone}
+	if p := auth.FromContext(r.Context()); p != nil {
+		res.CreatedBy = p.ID
+	}
+	if p := orderRequest.OriginPlace; p != nil {
+		res.OriginAddress, res.OriginPlaceID = p.Address, p.PlaceID
+	}
+	if p := orderRequest.DestinationPlace; p != nil {
+		res.DestAddress, res.DestPlaceID = p.Address, p.PlaceID
+	}
+	if q := dep.quote(trip); q != nil {
+		res.Price, res.Currency = q.Amount, q.Currency
+	}
+	createResult := dep.Dao.CreateOrder(dep.db(r.Context()), res, orderEvents("", webhook.EventOrderCreated))
 	if createResult.Error != nil || res.ID == 0 {
-		responseutil.WriteJSONErrorResponse(w, fmt.Sprintf("Create error: %v", createResult.Error), http.StatusInternalServerError)
+		logger.Errorf("Cannot create order: %v", createResult.Error)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Order could not be created")
 		return
 	}
 
+	logger.WithField("order_id", res.ID).Infof("Order created with distance %d", res.Distance)
+	dep.publishOrder(webhook.EventOrderCreated, res)
+
+	// best effort, the order is left for couriers or the periodic dispatch otherwise
+	if dep.Dispatch.OnCreate {
+		dep.queueDispatch(r.Context(), res)
+	}
+	// off the request path, it outlives the request
+	go dep.offerOrder(context.WithoutCancel(r.Context()), *res)
+
 	// return result to user
 	responseutil.WriteJSONToResponse(&res, w)
 }
@@ -1,6 +1,8 @@
 package requesthandler
 
 import (
+	"auth"
+	"context"
 	"dao"
 	"distancehelper"
 	"encoding/json"
@@ -14,9 +16,11 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"request"
+	"responseutil"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 // new interfaces and structs for mocks
@@ -24,6 +28,10 @@ import (
 type GormDBMock struct {
 	mock.Mock
 	dao.DAO
+	// events written along with orders
+	Outbox []entity.OutboxEvent
+	// whether the outbox relay lock was released
+	Unlocked bool
 }
 
 func (gdb *GormDBMock) FindWithLimitAndOffset(db *gorm.DB, limit int, offset int, out *[]entity.Order) {
@@ -31,6 +39,13 @@ func (gdb *GormDBMock) FindWithLimitAndOffset(db *gorm.DB, limit int, offset int
 	*out = *args.Get(0).(*[]entity.Order)
 }
 
+func (gdb *GormDBMock) FindFirstWithId(db *gorm.DB, id int, out *entity.Order) {
+	args := gdb.Called(db, id, out)
+	if args.Bool(0) {
+		*out = *args.Get(1).(*entity.Order)
+	}
+}
+
 func (gdb *GormDBMock) FindFirstWithIdAndStatus(db *gorm.DB, status string, id int, out *entity.Order) {
 	args := gdb.Called(db, status, id, out)
 	if args.Bool(0) {
@@ -38,28 +53,221 @@ func (gdb *GormDBMock) FindFirstWithIdAndStatus(db *gorm.DB, status string, id i
 	}
 }
 
-func (gdb *GormDBMock) UpdateOrderStatus(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string) *gorm.DB {
+func (gdb *GormDBMock) UpdateOrderStatus(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events dao.OrderEvents) *gorm.DB {
 	args := gdb.Called(db, modelToUpdate, newStatus, oldStatus)
 	if modelToUpdate.Status == oldStatus {
 		modelToUpdate.Status = newStatus
+		modelToUpdate.Version++
 	}
-	return args.Get(0).(*gorm.DB)
+	res := args.Get(0).(*gorm.DB)
+	if res.Error == nil && res.RowsAffected > 0 {
+		gdb.writeEvents(modelToUpdate, events)
+	}
+	return res
+}
+
+func (gdb *GormDBMock) AssignOrder(db *gorm.DB, modelToUpdate *entity.Order, newStatus string, oldStatus string, events dao.OrderEvents) *gorm.DB {
+	args := gdb.Called(db, modelToUpdate, newStatus, oldStatus)
+	res := args.Get(0).(*gorm.DB)
+	if res.Error == nil && res.RowsAffected > 0 {
+		modelToUpdate.Status = newStatus
+		modelToUpdate.Version++
+		gdb.writeEvents(modelToUpdate, events)
+	}
+	return res
+}
+
+func (gdb *GormDBMock) SaveCourier(db *gorm.DB, c *entity.Courier) error {
+	args := gdb.Called(db, c)
+	return args.Error(0)
 }
 
-func (gdb *GormDBMock) CreateOrder(db *gorm.DB, modelToCreate *entity.Order) *gorm.DB {
+func (gdb *GormDBMock) FindCouriers(db *gorm.DB, out *[]entity.Courier) error {
+	args := gdb.Called(db, out)
+	*out = args.Get(0).([]entity.Courier)
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) CreateOrder(db *gorm.DB, modelToCreate *entity.Order, events dao.OrderEvents) *gorm.DB {
 	args := gdb.Called(db, modelToCreate)
 	modelToCreate.ID = args.Get(1).(uint64)
+	res := args.Get(0).(*gorm.DB)
+	if res.Error == nil {
+		gdb.writeEvents(modelToCreate, events)
+	}
+	return res
+}
+
+func (gdb *GormDBMock) writeEvents(order *entity.Order, events dao.OrderEvents) {
+	if events == nil {
+		return
+	}
+	evs, err := events(order)
+	if err != nil {
+		panic(err)
+	}
+	gdb.Outbox = append(gdb.Outbox, evs...)
+}
+
+func (gdb *GormDBMock) FindIdempotencyKey(db *gorm.DB, subject string, key string, out *entity.IdempotencyKey) {
+	args := gdb.Called(db, subject, key, out)
+	if args.Bool(0) {
+		*out = *args.Get(1).(*entity.IdempotencyKey)
+	}
+}
+
+func (gdb *GormDBMock) CreateIdempotencyKey(db *gorm.DB, modelToCreate *entity.IdempotencyKey) *gorm.DB {
+	args := gdb.Called(db, modelToCreate)
+	return args.Get(0).(*gorm.DB)
+}
+
+func (gdb *GormDBMock) UpdateIdempotencyKey(db *gorm.DB, modelToUpdate *entity.IdempotencyKey) *gorm.DB {
+	args := gdb.Called(db, modelToUpdate)
+	return args.Get(0).(*gorm.DB)
+}
+
+func (gdb *GormDBMock) DeleteIdempotencyKey(db *gorm.DB, subject string, key string) *gorm.DB {
+	args := gdb.Called(db, subject, key)
 	return args.Get(0).(*gorm.DB)
 }
 
+func (gdb *GormDBMock) Ping(ctx context.Context, db *gorm.DB) error {
+	args := gdb.Called(ctx, db)
+	return args.Error(0)
+}
+
+func (gdb *GormDBMock) FindServiceAreas(db *gorm.DB, out *[]entity.ServiceArea) error {
+	args := gdb.Called(db, out)
+	*out = args.Get(0).([]entity.ServiceArea)
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) SeedServiceAreas(db *gorm.DB, areas []entity.ServiceArea) (bool, error) {
+	args := gdb.Called(db, areas)
+	return args.Bool(0), args.Error(1)
+}
+
+func (gdb *GormDBMock) ReplaceServiceAreas(db *gorm.DB, areas []entity.ServiceArea) error {
+	args := gdb.Called(db, areas)
+	return args.Error(0)
+}
+
+func (gdb *GormDBMock) FindOrdersWithStatus(db *gorm.DB, status string, limit int, out *[]entity.Order) error {
+	args := gdb.Called(db, status, limit, out)
+	*out = args.Get(0).([]entity.Order)
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) CountOrdersByCourier(db *gorm.DB, status string) (map[string]int, error) {
+	args := gdb.Called(db, status)
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (gdb *GormDBMock) FindAvailableCouriers(db *gorm.DB, locatedSince time.Time, out *[]entity.Courier) error {
+	args := gdb.Called(db, locatedSince, out)
+	*out = args.Get(0).([]entity.Courier)
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) FindCourier(db *gorm.DB, id string, out *entity.Courier) {
+	args := gdb.Called(db, id, out)
+	if args.Bool(0) {
+		*out = *args.Get(1).(*entity.Courier)
+	}
+}
+
+func (gdb *GormDBMock) SaveCourierLocation(db *gorm.DB, loc *entity.CourierLocation, historySize int) error {
+	args := gdb.Called(db, loc, historySize)
+	return args.Error(0)
+}
+
+func (gdb *GormDBMock) CreateWebhookSubscription(db *gorm.DB, modelToCreate *entity.WebhookSubscription) error {
+	args := gdb.Called(db, modelToCreate)
+	modelToCreate.ID = uint64(args.Int(0))
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) FindWebhookSubscriptions(db *gorm.DB, out *[]entity.WebhookSubscription) error {
+	args := gdb.Called(db, out)
+	if subs, ok := args.Get(0).([]entity.WebhookSubscription); ok {
+		*out = subs
+	}
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) DeleteWebhookSubscription(db *gorm.DB, id uint64) (bool, error) {
+	args := gdb.Called(db, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (gdb *GormDBMock) CreateWebhookDeliveries(db *gorm.DB, deliveries []entity.WebhookDelivery) error {
+	args := gdb.Called(db, deliveries)
+	return args.Error(0)
+}
+
+func (gdb *GormDBMock) FindDueWebhookDeliveries(db *gorm.DB, now time.Time, limit int, out *[]entity.WebhookDelivery) error {
+	args := gdb.Called(db, now, limit, out)
+	if deliveries, ok := args.Get(0).([]entity.WebhookDelivery); ok {
+		*out = deliveries
+	}
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) ClaimWebhookDelivery(db *gorm.DB, delivery *entity.WebhookDelivery, until time.Time) (bool, error) {
+	args := gdb.Called(db, delivery, until)
+	if args.Bool(0) {
+		delivery.Attempts++
+	}
+	return args.Bool(0), args.Error(1)
+}
+
+func (gdb *GormDBMock) UpdateWebhookDelivery(db *gorm.DB, modelToUpdate *entity.WebhookDelivery) error {
+	args := gdb.Called(db, modelToUpdate)
+	return args.Error(0)
+}
+
+func (gdb *GormDBMock) FindWebhookDeliveries(db *gorm.DB, subscriptionID uint64, status string, limit int, offset int, out *[]entity.WebhookDelivery) error {
+	args := gdb.Called(db, subscriptionID, status, limit, offset, out)
+	if deliveries, ok := args.Get(0).([]entity.WebhookDelivery); ok {
+		*out = deliveries
+	}
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) RetryWebhookDelivery(db *gorm.DB, subscriptionID uint64, id uint64, now time.Time) (bool, error) {
+	args := gdb.Called(db, subscriptionID, id, now)
+	return args.Bool(0), args.Error(1)
+}
+
+func (gdb *GormDBMock) LockOutboxRelay(ctx context.Context, db *gorm.DB) (func(), bool, error) {
+	args := gdb.Called(ctx, db)
+	return func() { gdb.Unlocked = true }, args.Bool(0), args.Error(1)
+}
+
+func (gdb *GormDBMock) FindOutboxEvents(db *gorm.DB, limit int, out *[]entity.OutboxEvent) error {
+	args := gdb.Called(db, limit, out)
+	if events, ok := args.Get(0).([]entity.OutboxEvent); ok {
+		*out = events
+	}
+	return args.Error(1)
+}
+
+func (gdb *GormDBMock) MarkOutboxEventPublished(db *gorm.DB, id uint64, publishedTo string) error {
+	return gdb.Called(db, id, publishedTo).Error(0)
+}
+
+func (gdb *GormDBMock) DeleteOutboxEvent(db *gorm.DB, id uint64) error {
+	return gdb.Called(db, id).Error(0)
+}
+
 type GMapHelperMock struct {
 	mock.Mock
 	distancehelper.MapHelper
 }
 
-func (ghm *GMapHelperMock) GetDistanceMeters(co *request.PlaceOrderRequest, gm distancehelper.GMap) (int, error) {
-	args := ghm.Called(co, gm)
-	return args.Get(0).(int), args.Error(1)
+func (ghm *GMapHelperMock) GetRoute(ctx context.Context, co *request.PlaceOrderRequest, gm distancehelper.GMap) (distancehelper.Route, error) {
+	args := ghm.Called(ctx, co, gm)
+	return args.Get(0).(distancehelper.Route), args.Error(1)
 }
 
 // test list order
@@ -91,6 +299,28 @@ func TestListOrder(t *testing.T) {
 	}
 }
 
+func TestListOrderNotModified(t *testing.T) {
+	o := []entity.Order{{ID: 10, Status: StatusUnassigned, Distance: 100, Version: 1}}
+	dao := &GormDBMock{}
+	dao.On("FindWithLimitAndOffset", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&o)
+	dep := &Dependencies{Dao: dao}
+
+	r, _ := http.NewRequest("GET", "/orders", nil)
+	w := httptest.NewRecorder()
+	dep.HandleListOrder(w, r, nil)
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, "W/") {
+		t.Errorf("Expected weak ETag, got %s", etag)
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	dep.HandleListOrder(w, r, nil)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expect status %d, actual: %d", http.StatusNotModified, w.Code)
+	}
+}
+
 func TestListOrderInputError(t *testing.T) {
 	var h http.Request
 	h.URL = &url.URL{RawQuery: "page=-2"}
@@ -102,10 +332,94 @@ func TestListOrderInputError(t *testing.T) {
 	checkNonEmptyResponse(t, w, http.StatusBadRequest)
 }
 
+// get order tests
+
+func TestGetOrder(t *testing.T) {
+	w := testGetOrder(t, strconv.Itoa(id), getMockDaoForGetOrder(&entity.Order{ID: uint64(id), Status: StatusTaken, Version: 3}), http.StatusOK)
+
+	if w.Header().Get("ETag") != "\"3\"" {
+		t.Errorf("Expected ETag \"3\", got %s", w.Header().Get("ETag"))
+	}
+	var o entity.Order
+	_ = json.NewDecoder(w.Body).Decode(&o)
+	if o.Version != 3 {
+		t.Errorf("Expected version 3, got %d", o.Version)
+	}
+}
+
+func TestGetOrderIDInvalid(t *testing.T) {
+	testGetOrder(t, "asdf", nil, http.StatusBadRequest)
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	w := testGetOrder(t, strconv.Itoa(id), getMockDaoForGetOrder(nil), http.StatusNotFound)
+
+	var p responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	if p.Code != responseutil.CodeOrderNotFound {
+		t.Errorf("Expected code %s, got %s", responseutil.CodeOrderNotFound, p.Code)
+	}
+}
+
+func TestGetOrderNotModified(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", fmt.Sprintf("/orders/%d", id), nil)
+	r.Header.Set("If-None-Match", "\"3\"")
+	dep := &Dependencies{Dao: getMockDaoForGetOrder(&entity.Order{ID: uint64(id), Status: StatusTaken, Version: 3})}
+
+	dep.HandleGetOrder(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expect status %d, actual: %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestGetOrderOwnedByCustomer(t *testing.T) {
+	order := &entity.Order{ID: uint64(id), Status: StatusUnassigned, Version: 1, CreatedBy: "customer-1"}
+
+	testGetOrderAs(t, &auth.Principal{ID: "customer-1", Roles: []string{auth.RoleCustomer}}, order, http.StatusOK)
+	testGetOrderAs(t, &auth.Principal{ID: "customer-2", Roles: []string{auth.RoleCustomer}}, order, http.StatusNotFound)
+	testGetOrderAs(t, &auth.Principal{ID: "courier-1", Roles: []string{auth.RoleCourier}}, order, http.StatusOK)
+	testGetOrderAs(t, &auth.Principal{ID: "dispatcher-1", Roles: []string{auth.RoleDispatcher}}, order, http.StatusOK)
+	testGetOrderAs(t, &auth.Principal{ID: "admin-1", Roles: []string{auth.RoleAdmin}}, order, http.StatusOK)
+}
+
+func testGetOrderAs(t *testing.T, p *auth.Principal, order *entity.Order, status int) {
+	r, _ := http.NewRequest("GET", fmt.Sprintf("/orders/%d", order.ID), nil)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: getMockDaoForGetOrder(order)}
+
+	dep.HandleGetOrder(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.FormatUint(order.ID, 10)}})
+
+	if w.Code != status {
+		t.Errorf("Expect status %d for %s, actual: %d", status, p.ID, w.Code)
+	}
+}
+
+func testGetOrder(t *testing.T, id string, dao dao.DAO, status int) (w *httptest.ResponseRecorder) {
+	r, _ := http.NewRequest("GET", fmt.Sprintf("/orders/%s", id), nil)
+	w = httptest.NewRecorder()
+	dep := &Dependencies{Dao: dao}
+
+	dep.HandleGetOrder(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: id}})
+
+	checkNonEmptyResponse(t, w, status)
+
+	return w
+}
+
+func getMockDaoForGetOrder(order *entity.Order) *GormDBMock {
+	dao := &GormDBMock{}
+	dao.On("FindFirstWithId", mock.Anything, mock.Anything, mock.Anything).Return(order != nil, order)
+	return dao
+}
+
 // New order tests
 var normalCoordinates = "{\"origin\": [\"22.2802\", \"114.184919\"], \"destination\": [\"22.280457\", \"114.185672\"]}"
 var id = 10
 var distance = 73
+var duration = 95 * time.Second
 
 func TestNewOrderContentTypeError(t *testing.T) {
 	var h http.Request
@@ -125,11 +439,31 @@ func TestNewOrderCoordinatesError(t *testing.T) {
 	testNewOrder(t, strings.NewReader("{\"origin\": [\"-180.1\", \"1\"], \"destination\": [\"1\", \"1\"]}"), nil, nil, http.StatusBadRequest)
 }
 
+func TestNewOrderFieldErrors(t *testing.T) {
+	w := testNewOrder(t, strings.NewReader("{\"origin\": [\"91\", \"181\"], \"destination\": [\"1\"], \"weight\": 1}"), nil, nil, http.StatusBadRequest)
+
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	fields := make([]string, len(problem.Errors))
+	for i, e := range problem.Errors {
+		fields[i] = e.Field + " " + e.Code
+	}
+	expected := "[destination length origin[0] latitude origin[1] longitude weight unknown]"
+	if problem.Code != responseutil.CodeValidationFailed || fmt.Sprint(fields) != expected {
+		t.Errorf("Expect %s errors %s, got %s %v", responseutil.CodeValidationFailed, expected, problem.Code, fields)
+	}
+}
+
 func TestNewOrderMapAPIError(t *testing.T) {
 	ghm := getMockMapForNewOrder(-1, errors.New(""))
 	testNewOrder(t, strings.NewReader(normalCoordinates), ghm, nil, http.StatusInternalServerError)
 }
 
+func TestNewOrderMapCircuitOpen(t *testing.T) {
+	ghm := getMockMapForNewOrder(-1, distancehelper.ErrCircuitOpen)
+	testNewOrder(t, strings.NewReader(normalCoordinates), ghm, nil, http.StatusServiceUnavailable)
+}
+
 func TestNewOrderMapNotOKError(t *testing.T) {
 	ghm := getMockMapForNewOrder(-1, nil)
 	testNewOrder(t, strings.NewReader(normalCoordinates), ghm, nil, http.StatusBadRequest)
@@ -165,6 +499,20 @@ func TestNewOrder(t *testing.T) {
 	}
 }
 
+func TestNewOrderRecordsCreator(t *testing.T) {
+	dao := getMockDaoForNewOrder(id, nil)
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader(normalCoordinates))
+	r.Header.Set("Content-Type", "application/json")
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: "customer-1", Roles: []string{auth.RoleCustomer}}))
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(distance, nil)}
+
+	dep.HandleNewOrder(httptest.NewRecorder(), r, nil)
+
+	dao.AssertCalled(t, "CreateOrder", mock.Anything, mock.MatchedBy(func(o *entity.Order) bool {
+		return o.CreatedBy == "customer-1"
+	}))
+}
+
 func testNewOrder(t *testing.T, body *strings.Reader, m distancehelper.MapHelper, dao dao.DAO, status int) (w *httptest.ResponseRecorder) {
 	r, _ := http.NewRequest("POST", "/orders", body)
 	r.Header = map[string][]string{
@@ -184,8 +532,12 @@ func checkNonEmptyResponse(t *testing.T, w *httptest.ResponseRecorder, status in
 	if w.Code != status {
 		t.Errorf("Expect status %d, actual: %d", status, w.Code)
 	}
-	if !strings.Contains(w.Header().Get("Content-Type"), "application/json") {
-		t.Errorf("Expect return content type is %s, actual: %s", "application/json", w.Header().Get("Content-Type"))
+	contentType := "application/json"
+	if status >= http.StatusBadRequest {
+		contentType = responseutil.ProblemContentType
+	}
+	if !strings.Contains(w.Header().Get("Content-Type"), contentType) {
+		t.Errorf("Expect return content type is %s, actual: %s", contentType, w.Header().Get("Content-Type"))
 	}
 	if w.Body.String() == "" {
 		t.Errorf("Expect non empty json response.")
@@ -194,7 +546,7 @@ func checkNonEmptyResponse(t *testing.T, w *httptest.ResponseRecorder, status in
 
 func getMockMapForNewOrder(distance int, err error) *GMapHelperMock {
 	ghm := &GMapHelperMock{}
-	ghm.On("GetDistanceMeters", mock.Anything, mock.Anything).Return(distance, err)
+	ghm.On("GetRoute", mock.Anything, mock.Anything, mock.Anything).Return(distancehelper.Route{DistanceMeters: distance, Duration: duration}, err)
 	return ghm
 }
 
@@ -205,7 +557,7 @@ func getMockDaoForNewOrder(id int, err error) *GormDBMock {
 }
 
 // take order test
-var order = &entity.Order{ID: uint64(id), Status: StatusUnassigned, Distance: distance}
+var order = &entity.Order{ID: uint64(id), Status: StatusUnassigned, Distance: distance, Version: 1}
 
 func TestTakeOrderIDInvalid(t *testing.T) {
 	testTakeOrder(t, "asdf", nil, http.StatusBadRequest, strings.NewReader("{}"))
@@ -243,8 +595,57 @@ func TestTakeOrderOK(t *testing.T) {
 	}
 }
 
+func TestTakeOrderIfMatch(t *testing.T) {
+	w := testTakeOrderIfMatch(t, strconv.Itoa(id), getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 1}), "\"1\"", http.StatusOK, strings.NewReader("{\"status\":\"TAKEN\"}"))
+
+	if w.Header().Get("ETag") != "\"2\"" {
+		t.Errorf("Expected ETag \"2\", got %s", w.Header().Get("ETag"))
+	}
+}
+
+func TestTakeOrderIfMatchStale(t *testing.T) {
+	testTakeOrderIfMatch(t, strconv.Itoa(id), getMockDaoForTakeOrder(order, nil), "\"0\"", http.StatusPreconditionFailed, strings.NewReader("{\"status\":\"TAKEN\"}"))
+}
+
+func TestTakeOrderIfMatchUpdateFailed(t *testing.T) {
+	testTakeOrderIfMatch(t, strconv.Itoa(id), getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 0}), "\"1\"", http.StatusPreconditionFailed, strings.NewReader("{\"status\":\"TAKEN\"}"))
+}
+
+func TestTakeOrderRecordsCourier(t *testing.T) {
+	dao := getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 1})
+	r, _ := http.NewRequest("PATCH", fmt.Sprintf("/orders/%d", id), strings.NewReader("{\"status\":\"TAKEN\"}"))
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: "courier-1", Roles: []string{auth.RoleCourier}}))
+	dep := &Dependencies{Dao: dao}
+
+	dep.HandleTakeOrder(httptest.NewRecorder(), r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+	dao.AssertCalled(t, "UpdateOrderStatus", mock.Anything, mock.MatchedBy(func(o *entity.Order) bool {
+		return o.CourierID == "courier-1"
+	}), StatusTaken, StatusUnassigned)
+}
+
+func TestTakeOrderNotOwned(t *testing.T) {
+	dao := getMockDaoForTakeOrder(&entity.Order{ID: uint64(id), Status: StatusUnassigned, Version: 1, CreatedBy: "customer-1"}, &gorm.DB{RowsAffected: 1})
+	r, _ := http.NewRequest("PATCH", fmt.Sprintf("/orders/%d", id), strings.NewReader("{\"status\":\"TAKEN\"}"))
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: "customer-2", Roles: []string{auth.RoleCustomer}}))
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: dao}
+
+	dep.HandleTakeOrder(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+	checkNonEmptyResponse(t, w, http.StatusNotFound)
+	dao.AssertNotCalled(t, "UpdateOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func testTakeOrder(t *testing.T, id string, dao dao.DAO, status int, body *strings.Reader) (w *httptest.ResponseRecorder) {
+	return testTakeOrderIfMatch(t, id, dao, "", status, body)
+}
+
+func testTakeOrderIfMatch(t *testing.T, id string, dao dao.DAO, ifMatch string, status int, body *strings.Reader) (w *httptest.ResponseRecorder) {
 	r, _ := http.NewRequest("PATCH", fmt.Sprintf("/orders/%s", id), body)
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
 	w = httptest.NewRecorder()
 	dep := &Dependencies{Dao: dao}
 	params := httprouter.Params{
@@ -1,41 +1,52 @@
 package requesthandler
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"entity"
 	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
 	"net/http"
+	"reflect"
 	"request"
 	"responseutil"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-func getPageAndLimit(req *http.Request) (int, int, []string) {
+func getPageAndLimit(req *http.Request) (int, int, []responseutil.FieldError) {
 	limitMin, pageMin, pageDefault := 1, 1, 1
 	limitDefault := -1
-	var es []string
+	var es []responseutil.FieldError
 
 	limit, err := getNumberFromRequestWithLowerBound(req, "limit", limitDefault, limitMin)
-	if err != "" {
-		es = append(es, err)
+	if err != nil {
+		es = append(es, *err)
 	}
 	page, err := getNumberFromRequestWithLowerBound(req, "page", pageDefault, pageMin)
-	if err != "" {
-		es = append(es, err)
+	if err != nil {
+		es = append(es, *err)
 	}
 
 	return page, limit, es
 }
 
 // return param in number, in case of err, default value and err are returned
-func getNumberFromRequestWithLowerBound(req *http.Request, param string, def int, min int) (int, string) {
-	num, err := strconv.Atoi(getParamOrDefault(req, param, def))
+func getNumberFromRequestWithLowerBound(req *http.Request, param string, def int, min int) (int, *responseutil.FieldError) {
+	p := getParamOrDefault(req, param, def)
+	num, err := strconv.Atoi(p)
 	if err != nil {
-		return def, fmt.Sprintf("Invalid %s %v", param, err)
+		return def, &responseutil.FieldError{Field: param, Code: "integer", Detail: "must be an integer", Value: p}
 	}
 	if num != def && num < min {
-		return def, fmt.Sprintf("Invalid %s %d", param, num)
+		return def, &responseutil.FieldError{Field: param, Code: "minimum", Detail: fmt.Sprintf("must be at least %d", min), Value: num}
 	}
-	return num, ""
+	return num, nil
 }
 
 func getParamOrDefault(r *http.Request, param string, def int) string {
@@ -46,32 +57,150 @@ func getParamOrDefault(r *http.Request, param string, def int) string {
 	return p
 }
 
-func coordinatesValid(request *request.PlaceOrderRequest) bool {
-	return isLatitude(request.Origin[0]) && isLongitude(request.Origin[1]) &&
-		isLatitude(request.Destination[0]) && isLongitude(request.Destination[1])
+func toFieldErrors(vs []request.Violation) []responseutil.FieldError {
+	es := make([]responseutil.FieldError, len(vs))
+	for i, v := range vs {
+		es[i] = responseutil.FieldError{Field: v.Path, Code: v.Rule, Detail: v.Message, Value: v.Value}
+	}
+	return es
 }
 
-func isLatitude(s string) bool {
-	return isNumWithRange(s, -90, 90)
+// decodeObject decodes the JSON object body into the struct v field by field, reporting every unknown or mistyped
+// field instead of the first one. An error is only returned when the body is not a JSON object.
+func decodeObject(body io.Reader, v interface{}) ([]responseutil.FieldError, error) {
+	var raw json.RawMessage
+	dec := json.NewDecoder(body)
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON object")
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var es []responseutil.FieldError
+	target := reflect.ValueOf(v).Elem()
+	for _, name := range names {
+		f, ok := jsonField(target, name)
+		if !ok {
+			es = append(es, responseutil.FieldError{Field: name, Code: request.RuleUnknown, Detail: "unknown field"})
+			continue
+		}
+		if err := json.Unmarshal(fields[name], f.Addr().Interface()); err != nil {
+			es = append(es, responseutil.FieldError{Field: name, Code: request.RuleType, Detail: "must be " + jsonTypeName(f.Type())})
+		}
+	}
+	return es, nil
 }
 
-func isLongitude(s string) bool {
-	return isNumWithRange(s, -180, 180)
+// jsonField returns the field of the struct v named name in JSON, matched case-insensitively like encoding/json
+func jsonField(v reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if tag != "" && tag != "-" && strings.EqualFold(tag, name) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
 }
 
-func isNumWithRange(s string, min float64, max float64) bool {
-	n, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return false
+var timeType = reflect.TypeOf(time.Time{})
+
+func jsonTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
-	return n >= min && n <= max
+	switch {
+	case t == timeType:
+		return "an RFC 3339 timestamp"
+	case t.Kind() == reflect.Bool:
+		return "a boolean"
+	case t.Kind() == reflect.String:
+		return "a string"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64:
+		return "a number"
+	case t.Kind() == reflect.Slice:
+		return "a list of " + strings.TrimPrefix(strings.TrimPrefix(jsonTypeName(t.Elem()), "a "), "an ") + "s"
+	}
+	return "an object"
 }
 
 func checkContentType(r *http.Request, w http.ResponseWriter, ct string) bool {
 	contentType := r.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, ct) {
-		responseutil.WriteJSONErrorResponse(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		responseutil.WriteError(w, r, http.StatusUnsupportedMediaType, responseutil.CodeUnsupportedMediaType, fmt.Sprintf("Content-Type must be %s", ct))
 		return false
 	}
 	return true
 }
+
+// return order id from path, in case of err, the error response is written
+func getOrderId(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (int, bool) {
+	ids := ps.ByName("id")
+	id, err := strconv.Atoi(ids)
+	if err != nil || id < 1 {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidID, fmt.Sprintf("Invalid Id: %s", ids))
+		return 0, false
+	}
+	return id, true
+}
+
+// truncate cuts s to at most n characters, replacing invalid UTF-8 so the result can be stored
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func orderETag(order *entity.Order) string {
+	return fmt.Sprintf("\"%d\"", order.Version)
+}
+
+// weak tag as the list is only identical in content, not in representation
+func ordersETag(orders []entity.Order) string {
+	h := sha1.New()
+	for _, o := range orders {
+		_, _ = fmt.Fprintf(h, "%d:%d;", o.ID, o.Version)
+	}
+	return fmt.Sprintf("W/\"%s\"", hex.EncodeToString(h.Sum(nil)))
+}
+
+// true when there is no If-Match header or one of its tags strongly matches etag
+func ifMatch(r *http.Request, etag string) bool {
+	h := r.Header.Get("If-Match")
+	if h == "" {
+		return true
+	}
+	for _, t := range strings.Split(h, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || (t == etag && !strings.HasPrefix(t, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// true when one of the If-None-Match tags weakly matches etag
+func noneMatch(r *http.Request, etag string) bool {
+	h := r.Header.Get("If-None-Match")
+	if h == "" {
+		return false
+	}
+	for _, t := range strings.Split(h, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
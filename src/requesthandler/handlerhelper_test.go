@@ -4,7 +4,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"request"
 	"strings"
 	"testing"
 )
@@ -38,30 +37,6 @@ func TestContentTypeFail(t *testing.T) {
 	}
 }
 
-func TestCoordinatesValid(t *testing.T) {
-	r := map[*request.PlaceOrderRequest]bool{
-		createRequest("-90", "-180", "90", "180"):        true,  // normal case
-		createRequest("-90.001", "-180", "90", "180"):    false, // overflow
-		createRequest("-90", "-180.001", "90", "180"):    false,
-		createRequest("-90", "-180", "90.000001", "180"): false,
-		createRequest("-90", "-180", "90", "180.00001"):  false,
-		createRequest("-90asdf", "-180", "90", "180"):    false, // invalid number
-	}
-
-	for k, v := range r {
-		if coordinatesValid(k) != v {
-			t.Errorf("Validate coordinate %#v returns %v, expects %v", k, !v, v)
-		}
-	}
-}
-
-func createRequest(oLat string, oLong string, dLat string, dLong string) *request.PlaceOrderRequest {
-	return &request.PlaceOrderRequest{
-		Origin:      []string{oLat, oLong},
-		Destination: []string{dLat, dLong},
-	}
-}
-
 func TestGetPageAndLimit(t *testing.T) {
 	var h http.Request
 
@@ -96,3 +71,45 @@ type testPageLimitResult struct {
 func createPageLimitResult(p int, l int, numError int) *testPageLimitResult {
 	return &testPageLimitResult{p, l, numError}
 }
+
+func TestIfMatch(t *testing.T) {
+	r := map[string]bool{
+		"":               true, // no precondition
+		"*":              true,
+		"\"2\"":          true,
+		"\"1\", \"2\"":   true,
+		"\"1\"":          false,
+		"W/\"2\"":        false, // weak tags never match strongly
+		"\"1\", W/\"2\"": false,
+	}
+
+	for k, v := range r {
+		h := http.Request{Header: http.Header{}}
+		if k != "" {
+			h.Header.Set("If-Match", k)
+		}
+		if ifMatch(&h, "\"2\"") != v {
+			t.Errorf("If-Match %s returns %v, expects %v", k, !v, v)
+		}
+	}
+}
+
+func TestNoneMatch(t *testing.T) {
+	r := map[string]bool{
+		"":             false,
+		"*":            true,
+		"W/\"2\"":      true,
+		"\"1\", \"2\"": true,
+		"\"1\"":        false,
+	}
+
+	for k, v := range r {
+		h := http.Request{Header: http.Header{}}
+		if k != "" {
+			h.Header.Set("If-None-Match", k)
+		}
+		if noneMatch(&h, "\"2\"") != v {
+			t.Errorf("If-None-Match %s returns %v, expects %v", k, !v, v)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package requesthandler
+
+import (
+	"context"
+	"distancehelper"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"responseutil"
+	"time"
+)
+
+const (
+	HealthUp   = "UP"
+	HealthDown = "DOWN"
+	// HealthDisabled is an optional dependency that is not configured, which does not make the service unready
+	HealthDisabled = "DISABLED"
+
+	readyCheckTimeout = 2 * time.Second
+)
+
+type HealthStatus struct {
+	Status string                      `json:"status"`
+	Checks map[string]*DependencyCheck `json:"checks,omitempty"`
+}
+
+type DependencyCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HandleHealth only reports the process is alive
+func (dep *Dependencies) HandleHealth(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	responseutil.WriteJSONToResponse(&HealthStatus{Status: HealthUp}, w)
+}
+
+// HandleReady reports whether the dependencies needed to serve orders are available
+func (dep *Dependencies) HandleReady(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+	defer cancel()
+	res := &HealthStatus{Status: HealthUp, Checks: map[string]*DependencyCheck{
+		"database": check(func() error { return dep.Dao.Ping(ctx, dep.DB) }),
+		"distance": dep.checkDistanceProvider(),
+	}}
+
+	code := http.StatusOK
+	for _, c := range res.Checks {
+		if c.Status == HealthDown {
+			res.Status = HealthDown
+			code = http.StatusServiceUnavailable
+		}
+	}
+	responseutil.WriteJSONToResponseWithStatus(res, w, code)
+}
+
+// without a Google API key the provider is reported disabled rather than down, distances are 0 then
+func (dep *Dependencies) checkDistanceProvider() *DependencyCheck {
+	ps, ok := dep.MapHelper.(distancehelper.ProviderStatus)
+	if ok && !ps.Configured() {
+		return &DependencyCheck{Status: HealthDisabled}
+	}
+	return check(func() error {
+		if ok && ps.CircuitOpen() {
+			return distancehelper.ErrCircuitOpen
+		}
+		return nil
+	})
+}
+
+func check(f func() error) *DependencyCheck {
+	start := time.Now()
+	err := f()
+	c := &DependencyCheck{Status: HealthUp, LatencyMs: float64(time.Since(start)) / float64(time.Millisecond)}
+	if err != nil {
+		c.Status = HealthDown
+		c.Error = err.Error()
+	}
+	return c
+}
@@ -0,0 +1,105 @@
+package requesthandler
+
+import (
+	"context"
+	"distancehelper"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"secret"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ProviderStatusMock struct {
+	GMapHelperMock
+	configured bool
+	open       bool
+}
+
+func (m *ProviderStatusMock) Configured() bool {
+	return m.configured
+}
+
+func (m *ProviderStatusMock) CircuitOpen() bool {
+	return m.open
+}
+
+func TestHealth(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{}
+
+	dep.HandleHealth(w, nil, nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+}
+
+func TestReady(t *testing.T) {
+	res := testReady(t, nil, &ProviderStatusMock{configured: true}, http.StatusOK)
+
+	if res.Status != HealthUp || res.Checks["database"].Status != HealthUp || res.Checks["distance"].Status != HealthUp {
+		t.Errorf("Expected all up, got %#v", res)
+	}
+}
+
+func TestReadyWithoutProviderStatus(t *testing.T) {
+	testReady(t, nil, &GMapHelperMock{}, http.StatusOK)
+}
+
+func TestReadyDBDown(t *testing.T) {
+	res := testReady(t, errors.New("connection refused"), &ProviderStatusMock{configured: true}, http.StatusServiceUnavailable)
+
+	if res.Status != HealthDown || res.Checks["database"].Error != "connection refused" {
+		t.Errorf("Expected database down, got %#v", res.Checks["database"])
+	}
+}
+
+func TestReadyProviderNotConfigured(t *testing.T) {
+	res := testReady(t, nil, &ProviderStatusMock{}, http.StatusOK)
+
+	if res.Status != HealthUp || res.Checks["distance"].Status != HealthDisabled {
+		t.Errorf("Expected distance disabled, got %#v", res.Checks["distance"])
+	}
+}
+
+func TestReadyCircuitOpen(t *testing.T) {
+	res := testReady(t, nil, &ProviderStatusMock{configured: true, open: true}, http.StatusServiceUnavailable)
+
+	if res.Checks["distance"].Error != distancehelper.ErrCircuitOpen.Error() {
+		t.Errorf("Expected circuit open, got %#v", res.Checks["distance"])
+	}
+}
+
+func TestReadyWithRealHelper(t *testing.T) {
+	h := &distancehelper.GMapHelper{Breaker: distancehelper.NewCircuitBreaker(1, time.Hour), APIKey: secret.New("key")}
+	h.Breaker.Failure()
+
+	testReady(t, nil, h, http.StatusServiceUnavailable)
+}
+
+func testReady(t *testing.T, pingErr error, m distancehelper.MapHelper, status int) *HealthStatus {
+	dao := &GormDBMock{}
+	// the ping is bounded, so a hanging database does not hang the probe
+	dao.On("Ping", mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	}), mock.Anything).Return(pingErr)
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: dao, MapHelper: m}
+	r, _ := http.NewRequest("GET", "/readyz", nil)
+
+	dep.HandleReady(w, r, nil)
+
+	if w.Code != status {
+		t.Errorf("Expect status %d, actual: %d", status, w.Code)
+	}
+	if !strings.Contains(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Expect health document, actual content type: %s", w.Header().Get("Content-Type"))
+	}
+	var res HealthStatus
+	_ = json.NewDecoder(w.Body).Decode(&res)
+	return &res
+}
@@ -0,0 +1,175 @@
+package requesthandler
+
+import (
+	"auth"
+	"bytes"
+	"crypto/sha256"
+	db "dao"
+	"encoding/hex"
+	"encoding/json"
+	"entity"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"logging"
+	"net/http"
+	"responseutil"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyReplayHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength = 255
+	// the body is read whole to be hashed, before the handler validates it
+	idempotencyMaxBodyBytes  = 1 << 20
+	DefaultIdempotencyWindow = 24 * time.Hour
+)
+
+// response headers stored with the body and sent again on replay
+var idempotencyReplayedHeaders = []string{"Location", "Content-Location", "ETag", "Last-Modified", "Cache-Control"}
+
+// WithIdempotency wraps h so that a request repeating an Idempotency-Key within dep.IdempotencyWindow gets the
+// stored response instead of being processed again. Requests without the header are passed through.
+func (dep *Dependencies) WithIdempotency(h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			h(w, r, ps)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, fmt.Sprintf("Invalid %s header", idempotencyKeyHeader),
+				responseutil.FieldError{Field: idempotencyKeyHeader, Code: "maxLength", Detail: fmt.Sprintf("must be at most %d characters", idempotencyKeyMaxLength)}))
+			return
+		}
+
+		// read body for hashing and give the handler a fresh copy
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, idempotencyMaxBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			responseutil.WriteError(w, r, http.StatusRequestEntityTooLarge, responseutil.CodeRequestTooLarge,
+				fmt.Sprintf("Request body must be at most %d bytes", idempotencyMaxBodyBytes))
+			return
+		}
+		if err != nil {
+			responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Cannot read request body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash := hashRequest(r, body)
+
+		// keys are scoped by caller, so one client cannot replay another client's response
+		subject := ""
+		if p := auth.FromContext(r.Context()); p != nil {
+			subject = p.ID
+		}
+
+		conn := dep.db(r.Context())
+		var stored entity.IdempotencyKey
+		dep.Dao.FindIdempotencyKey(conn, subject, key, &stored)
+		if stored.Key != "" && time.Since(stored.CreatedAt) > dep.IdempotencyWindow {
+			dep.Dao.DeleteIdempotencyKey(conn, subject, key)
+			stored = entity.IdempotencyKey{}
+		}
+
+		// first time we see the key - reserve it, so concurrent retries do not get processed too
+		if stored.Key == "" {
+			stored = entity.IdempotencyKey{Subject: subject, Key: key, RequestHash: hash}
+			if err := dep.Dao.CreateIdempotencyKey(conn, &stored).Error; err != nil {
+				// a concurrent retry reserved it first
+				if db.IsUniqueViolation(err) {
+					responseutil.WriteError(w, r, http.StatusConflict, responseutil.CodeIdempotencyKeyBusy, fmt.Sprintf("Request with %s %s is being processed", idempotencyKeyHeader, key))
+					return
+				}
+				logging.FromContext(r.Context()).Errorf("Cannot reserve idempotency key: %v", err)
+				responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Idempotency key could not be reserved")
+				return
+			}
+			// a panicking handler must not leave the key reserved forever
+			defer func() {
+				if p := recover(); p != nil {
+					dep.Dao.DeleteIdempotencyKey(conn, subject, key)
+					panic(p)
+				}
+			}()
+			rec := &responseRecorder{ResponseWriter: w, code: http.StatusOK}
+			h(rec, r, ps)
+			dep.storeIdempotentResponse(conn, &stored, rec)
+			return
+		}
+
+		if stored.RequestHash != hash {
+			responseutil.WriteError(w, r, http.StatusUnprocessableEntity, responseutil.CodeIdempotencyKeyReused, fmt.Sprintf("%s %s was used with a different request", idempotencyKeyHeader, key))
+			return
+		}
+		if stored.StatusCode == 0 {
+			responseutil.WriteError(w, r, http.StatusConflict, responseutil.CodeIdempotencyKeyBusy, fmt.Sprintf("Request with %s %s is being processed", idempotencyKeyHeader, key))
+			return
+		}
+
+		// replay
+		contentType := stored.ContentType
+		if contentType == "" {
+			contentType = "application/json; charset=utf-8"
+		}
+		if stored.Headers != "" {
+			var headers map[string]string
+			if err := json.Unmarshal([]byte(stored.Headers), &headers); err == nil {
+				for k, v := range headers {
+					w.Header().Set(k, v)
+				}
+			}
+		}
+		w.Header().Set(idempotencyReplayHeader, "true")
+		responseutil.WriteRawResponse(w, contentType, []byte(stored.ResponseBody), stored.StatusCode)
+	}
+}
+
+// server errors are not stored, so the client can retry with the same key
+func (dep *Dependencies) storeIdempotentResponse(conn *gorm.DB, stored *entity.IdempotencyKey, rec *responseRecorder) {
+	if rec.code >= http.StatusInternalServerError {
+		dep.Dao.DeleteIdempotencyKey(conn, stored.Subject, stored.Key)
+		return
+	}
+	headers := map[string]string{}
+	for _, k := range idempotencyReplayedHeaders {
+		if v := rec.Header().Get(k); v != "" {
+			headers[k] = v
+		}
+	}
+	if len(headers) > 0 {
+		b, _ := json.Marshal(headers)
+		stored.Headers = string(b)
+	}
+	stored.StatusCode = rec.code
+	stored.ContentType = rec.Header().Get("Content-Type")
+	stored.ResponseBody = rec.body.String()
+	dep.Dao.UpdateIdempotencyKey(conn, stored)
+}
+
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy of status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.code = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,258 @@
+package requesthandler
+
+import (
+	"auth"
+	"entity"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"responseutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+var idempotencyKey = "8e03978e-40d5-43e8-bc93-6894a57f9324"
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	dao := &GormDBMock{}
+	called := 0
+
+	w := testIdempotency(t, dao, "", "{}", &called, http.StatusOK)
+
+	if called != 1 {
+		t.Errorf("Expected handler to be called once, got %d", called)
+	}
+	if w.Header().Get(idempotencyReplayHeader) != "" {
+		t.Errorf("Expected no replay header")
+	}
+	dao.AssertNotCalled(t, "FindIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIdempotencyFirstRequestStored(t *testing.T) {
+	dao := getMockDaoForIdempotency(nil)
+	called := 0
+
+	testIdempotency(t, dao, idempotencyKey, "{}", &called, http.StatusOK)
+
+	if called != 1 {
+		t.Errorf("Expected handler to be called once, got %d", called)
+	}
+	dao.AssertCalled(t, "UpdateIdempotencyKey", mock.Anything, mock.MatchedBy(func(k *entity.IdempotencyKey) bool {
+		return k.Key == idempotencyKey && k.StatusCode == http.StatusOK && strings.Contains(k.ResponseBody, "handled")
+	}))
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	stored := storedIdempotencyKey("{}", time.Now())
+	dao := getMockDaoForIdempotency(stored)
+	called := 0
+
+	w := testIdempotency(t, dao, idempotencyKey, "{}", &called, http.StatusCreated)
+
+	if called != 0 {
+		t.Errorf("Expected handler not to be called, got %d", called)
+	}
+	if w.Header().Get(idempotencyReplayHeader) != "true" || w.Body.String() != stored.ResponseBody {
+		t.Errorf("Expected replay of %s, got %s", stored.ResponseBody, w.Body.String())
+	}
+}
+
+func TestIdempotencyDifferentBody(t *testing.T) {
+	dao := getMockDaoForIdempotency(storedIdempotencyKey("{}", time.Now()))
+	called := 0
+
+	testIdempotency(t, dao, idempotencyKey, "{\"origin\":[]}", &called, http.StatusUnprocessableEntity)
+
+	if called != 0 {
+		t.Errorf("Expected handler not to be called, got %d", called)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	stored := storedIdempotencyKey("{}", time.Now())
+	stored.StatusCode = 0
+	called := 0
+
+	testIdempotency(t, getMockDaoForIdempotency(stored), idempotencyKey, "{}", &called, http.StatusConflict)
+
+	if called != 0 {
+		t.Errorf("Expected handler not to be called, got %d", called)
+	}
+}
+
+func TestIdempotencyConcurrentReservation(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	dao.On("CreateIdempotencyKey", mock.Anything, mock.Anything).Return(&gorm.DB{Error: &pq.Error{Code: "23505"}})
+	called := 0
+
+	testIdempotency(t, dao, idempotencyKey, "{}", &called, http.StatusConflict)
+
+	if called != 0 {
+		t.Errorf("Expected handler not to be called, got %d", called)
+	}
+}
+
+func TestIdempotencyReservationDBError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	dao.On("CreateIdempotencyKey", mock.Anything, mock.Anything).Return(&gorm.DB{Error: gorm.ErrInvalidSQL})
+	called := 0
+
+	w := testIdempotency(t, dao, idempotencyKey, "{}", &called, http.StatusInternalServerError)
+
+	if called != 0 || !strings.Contains(w.Body.String(), responseutil.CodeInternal) {
+		t.Errorf("Expected an internal error without calling the handler, got %d %s", called, w.Body)
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	called := 0
+
+	w := testIdempotency(t, &GormDBMock{}, idempotencyKey, strings.Repeat(" ", idempotencyMaxBodyBytes+1), &called, http.StatusRequestEntityTooLarge)
+
+	if called != 0 || !strings.Contains(w.Body.String(), responseutil.CodeRequestTooLarge) {
+		t.Errorf("Expected the body to be rejected, got %d %s", called, w.Body)
+	}
+}
+
+func TestIdempotencyExpired(t *testing.T) {
+	dao := getMockDaoForIdempotency(storedIdempotencyKey("{\"origin\":[]}", time.Now().Add(-2*time.Hour)))
+	called := 0
+
+	testIdempotency(t, dao, idempotencyKey, "{}", &called, http.StatusOK)
+
+	if called != 1 {
+		t.Errorf("Expected handler to be called once, got %d", called)
+	}
+	dao.AssertCalled(t, "DeleteIdempotencyKey", mock.Anything, "", idempotencyKey)
+}
+
+func TestIdempotencyServerErrorNotStored(t *testing.T) {
+	dao := getMockDaoForIdempotency(nil)
+	dep := &Dependencies{Dao: dao, IdempotencyWindow: time.Hour}
+	h := dep.WithIdempotency(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader("{}"))
+	r.Header.Set(idempotencyKeyHeader, idempotencyKey)
+
+	h(httptest.NewRecorder(), r, nil)
+
+	dao.AssertCalled(t, "DeleteIdempotencyKey", mock.Anything, "", idempotencyKey)
+	dao.AssertNotCalled(t, "UpdateIdempotencyKey", mock.Anything, mock.Anything)
+}
+
+func TestIdempotencyScopedBySubject(t *testing.T) {
+	dao := getMockDaoForIdempotency(nil)
+	dep := &Dependencies{Dao: dao, IdempotencyWindow: time.Hour}
+	h := dep.WithIdempotency(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader("{}"))
+	r.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: "customer-1"}))
+
+	h(httptest.NewRecorder(), r, nil)
+
+	dao.AssertCalled(t, "FindIdempotencyKey", mock.Anything, "customer-1", idempotencyKey, mock.Anything)
+	dao.AssertCalled(t, "CreateIdempotencyKey", mock.Anything, mock.MatchedBy(func(k *entity.IdempotencyKey) bool {
+		return k.Subject == "customer-1" && k.Key == idempotencyKey
+	}))
+}
+
+func TestIdempotencyReplayHeaders(t *testing.T) {
+	dao := getMockDaoForIdempotency(nil)
+	dep := &Dependencies{Dao: dao, IdempotencyWindow: time.Hour}
+	h := dep.WithIdempotency(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Header().Set("Location", "/orders/1")
+		w.Header().Set("X-Request-ID", "abc")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{\"id\":1}"))
+	})
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader("{}"))
+	r.Header.Set(idempotencyKeyHeader, idempotencyKey)
+
+	h(httptest.NewRecorder(), r, nil)
+
+	var updated *entity.IdempotencyKey
+	dao.AssertCalled(t, "UpdateIdempotencyKey", mock.Anything, mock.MatchedBy(func(k *entity.IdempotencyKey) bool {
+		updated = k
+		return true
+	}))
+	if updated.Headers != "{\"Location\":\"/orders/1\"}" {
+		t.Errorf("Expected only Location to be stored, got %s", updated.Headers)
+	}
+
+	updated.CreatedAt = time.Now()
+	called := 0
+	w := testIdempotency(t, getMockDaoForIdempotency(updated), idempotencyKey, "{}", &called, http.StatusCreated)
+	if w.Header().Get("Location") != "/orders/1" {
+		t.Errorf("Expected replayed Location header, got %q", w.Header().Get("Location"))
+	}
+}
+
+func TestIdempotencyPanicReleasesKey(t *testing.T) {
+	dao := getMockDaoForIdempotency(nil)
+	dep := &Dependencies{Dao: dao, IdempotencyWindow: time.Hour}
+	h := dep.WithIdempotency(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		panic("boom")
+	})
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader("{}"))
+	r.Header.Set(idempotencyKeyHeader, idempotencyKey)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected panic to be propagated")
+			}
+		}()
+		h(httptest.NewRecorder(), r, nil)
+	}()
+
+	dao.AssertCalled(t, "DeleteIdempotencyKey", mock.Anything, "", idempotencyKey)
+}
+
+func testIdempotency(t *testing.T, dao *GormDBMock, key string, body string, called *int, status int) *httptest.ResponseRecorder {
+	dep := &Dependencies{Dao: dao, IdempotencyWindow: time.Hour}
+	h := dep.WithIdempotency(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		*called++
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != body {
+			t.Errorf("Expected handler to get body %s, got %s", body, string(b))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{\"result\":\"handled\"}"))
+	})
+	r, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+
+	h(w, r, nil)
+
+	checkNonEmptyResponse(t, w, status)
+
+	return w
+}
+
+func storedIdempotencyKey(body string, createdAt time.Time) *entity.IdempotencyKey {
+	r, _ := http.NewRequest("POST", "/orders", nil)
+	return &entity.IdempotencyKey{Key: idempotencyKey, RequestHash: hashRequest(r, []byte(body)),
+		StatusCode: http.StatusCreated, ContentType: "application/json", ResponseBody: "{\"id\":1}", CreatedAt: createdAt}
+}
+
+func getMockDaoForIdempotency(stored *entity.IdempotencyKey) *GormDBMock {
+	dao := &GormDBMock{}
+	dao.On("FindIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(stored != nil, stored)
+	dao.On("CreateIdempotencyKey", mock.Anything, mock.Anything).Return(&gorm.DB{})
+	dao.On("UpdateIdempotencyKey", mock.Anything, mock.Anything).Return(&gorm.DB{})
+	dao.On("DeleteIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(&gorm.DB{})
+	return dao
+}
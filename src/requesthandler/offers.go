@@ -0,0 +1,352 @@
+package requesthandler
+
+import (
+	"auth"
+	"context"
+	"entity"
+	"geo"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	"logging"
+	"net/http"
+	"responseutil"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultOfferRadius  = 5000
+	DefaultOfferTimeout = 30 * time.Second
+
+	// messages sent to couriers
+	offerTypeOffer     = "offer"
+	offerTypeExpired   = "expired"
+	offerTypeWithdrawn = "withdrawn"
+	offerTypeAccepted  = "accepted"
+	offerTypeRejected  = "rejected"
+	offerTypeError     = "error"
+	// and received from them
+	offerTypeAccept  = "accept"
+	offerTypeDecline = "decline"
+
+	// why an accept was rejected
+	offerNotOffered = "notOffered"
+	offerNotTaken   = "notTaken"
+	offerFailed     = "failed"
+
+	offerSendBuffer   = 32
+	offerMaxMessage   = 1024
+	offerPingInterval = 30 * time.Second
+	// connections not answering pings for as long are closed
+	offerPongWait     = 2 * offerPingInterval
+	offerWriteTimeout = 10 * time.Second
+)
+
+var offerUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// offerMessage is a JSON text message of the offer WebSocket, either way
+type offerMessage struct {
+	Type    string `json:"type"`
+	OrderID uint64 `json:"orderId,omitempty"`
+	// of offer and accepted
+	Order *entity.Order `json:"order,omitempty"`
+	// of offer, when it is withdrawn unless accepted
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// of rejected and error
+	Reason string `json:"reason,omitempty"`
+}
+
+// Offers pushes new orders to the couriers nearby connected to GET /couriers/offers, who accept or decline them
+// before the offer expires. Only the couriers connected to this instance are offered the orders placed through it.
+// A nil Offers offers nothing.
+type Offers struct {
+	// couriers farther from the order origin as the crow flies are not offered it, in meters
+	Radius int
+	// how long couriers have to accept an offer
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	couriers map[string]*offerConn
+	closed   bool
+}
+
+// offerConn is the connection of a courier, a courier connecting again replaces it
+type offerConn struct {
+	courierID string
+	send      chan offerMessage
+	// closed when the connection is replaced or the offers are closed
+	done chan struct{}
+	// the orders offered and not yet answered, with the timers expiring them
+	pending map[uint64]*time.Timer
+	// the logger of the request which connected, with its request id
+	log *log.Entry
+}
+
+func NewOffers(radius int, timeout time.Duration) *Offers {
+	return &Offers{Radius: radius, Timeout: timeout, couriers: map[string]*offerConn{}}
+}
+
+// HandleCourierOffers upgrades to a WebSocket pushing offers to the authenticated courier. Couriers answer with
+// {"type": "accept", "orderId": 1}, which takes the order like PATCH /orders/:id, or {"type": "decline",
+// "orderId": 1}.
+func (dep *Dependencies) HandleCourierOffers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	p := auth.FromContext(r.Context())
+	if p == nil || p.ID == "" {
+		responseutil.WriteError(w, r, http.StatusUnauthorized, responseutil.CodeUnauthorized, "Offers are only pushed to authenticated couriers")
+		return
+	}
+	ws, err := offerUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader answered already
+		logging.FromContext(r.Context()).Debugf("Cannot upgrade offer connection: %v", err)
+		return
+	}
+	c := dep.Offers.register(r.Context(), p.ID)
+	defer dep.Offers.unregister(c)
+	go writeOffers(ws, c)
+
+	ws.SetReadLimit(offerMaxMessage)
+	_ = ws.SetReadDeadline(time.Now().Add(offerPongWait))
+	ws.SetPongHandler(func(string) error { return ws.SetReadDeadline(time.Now().Add(offerPongWait)) })
+	for {
+		var m offerMessage
+		if err := ws.ReadJSON(&m); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				logging.FromContext(r.Context()).WithField("courier_id", p.ID).Debugf("Offer connection closed: %v", err)
+			}
+			return
+		}
+		dep.answerOffer(r.Context(), c, &m)
+	}
+}
+
+// writeOffers is the only writer of ws, closing it once c is done
+func writeOffers(ws *websocket.Conn, c *offerConn) {
+	defer ws.Close()
+	ping := time.NewTicker(offerPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(offerWriteTimeout))
+			return
+		case <-ping.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(offerWriteTimeout)); err != nil {
+				return
+			}
+		case m := <-c.send:
+			_ = ws.SetWriteDeadline(time.Now().Add(offerWriteTimeout))
+			if err := ws.WriteJSON(&m); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// answerOffer handles a message of the courier of c
+func (dep *Dependencies) answerOffer(ctx context.Context, c *offerConn, m *offerMessage) {
+	switch m.Type {
+	case offerTypeDecline:
+		dep.Offers.answer(c, m.OrderID)
+	case offerTypeAccept:
+		if !dep.Offers.answer(c, m.OrderID) {
+			dep.Offers.push(c, offerMessage{Type: offerTypeRejected, OrderID: m.OrderID, Reason: offerNotOffered})
+			return
+		}
+		var order entity.Order
+		dep.Dao.FindFirstWithIdAndStatus(dep.db(ctx), StatusUnassigned, int(m.OrderID), &order)
+		if order.ID == 0 {
+			dep.Offers.push(c, offerMessage{Type: offerTypeRejected, OrderID: m.OrderID, Reason: offerNotTaken})
+			return
+		}
+		res := dep.takeOrder(ctx, &order, c.courierID, true)
+		switch {
+		case res.Error != nil:
+			logging.FromContext(ctx).WithField("order_id", order.ID).Errorf("Cannot take offered order: %v", res.Error)
+			dep.Offers.push(c, offerMessage{Type: offerTypeRejected, OrderID: m.OrderID, Reason: offerFailed})
+		case res.RowsAffected < 1:
+			// taken meanwhile, or the courier is unavailable or at capacity
+			dep.Offers.push(c, offerMessage{Type: offerTypeRejected, OrderID: m.OrderID, Reason: offerNotTaken})
+		default:
+			dep.Offers.push(c, offerMessage{Type: offerTypeAccepted, OrderID: order.ID, Order: &order})
+		}
+	default:
+		dep.Offers.push(c, offerMessage{Type: offerTypeError, OrderID: m.OrderID, Reason: "type must be accept or decline"})
+	}
+}
+
+// offerOrder offers order to the available couriers connected within Radius of its origin
+func (dep *Dependencies) offerOrder(ctx context.Context, order entity.Order) {
+	if !dep.Offers.anyConnected() {
+		return
+	}
+	var couriers []entity.Courier
+	if err := dep.Dao.FindAvailableCouriers(dep.db(ctx), time.Now().Add(-dep.Dispatch.maxLocationAge()), &couriers); err != nil {
+		logging.FromContext(ctx).WithField("order_id", order.ID).Errorf("Cannot find couriers to offer the order: %v", err)
+		return
+	}
+	// orders are stored with validated coordinates
+	origin, _ := geo.ParsePoint([]string{order.OriginsLat, order.OriginsLong})
+	for _, c := range couriers {
+		if geo.Distance(geo.Point{Lat: c.Lat, Long: c.Long}, origin) <= float64(dep.Offers.radius()) {
+			dep.Offers.offer(c.ID, &order)
+		}
+	}
+}
+
+// Close disconnects the couriers, e.g. on shutdown, later connections are closed right away
+func (o *Offers) Close() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	for _, c := range o.couriers {
+		o.drop(c)
+	}
+}
+
+func (o *Offers) register(ctx context.Context, courierID string) *offerConn {
+	c := &offerConn{courierID: courierID, send: make(chan offerMessage, offerSendBuffer), done: make(chan struct{}),
+		pending: map[uint64]*time.Timer{}, log: logging.FromContext(ctx).WithField("courier_id", courierID)}
+	if o == nil {
+		close(c.done)
+		return c
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		close(c.done)
+		return c
+	}
+	if old := o.couriers[courierID]; old != nil {
+		o.drop(old)
+	}
+	o.couriers[courierID] = c
+	return c
+}
+
+func (o *Offers) unregister(c *offerConn) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.couriers[c.courierID] == c {
+		o.drop(c)
+	}
+}
+
+// drop closes c and forgets its offers, called with mu held
+func (o *Offers) drop(c *offerConn) {
+	delete(o.couriers, c.courierID)
+	for id, t := range c.pending {
+		t.Stop()
+		delete(c.pending, id)
+	}
+	close(c.done)
+}
+
+func (o *Offers) anyConnected() bool {
+	if o == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.couriers) > 0
+}
+
+// offer sends order to the courier if it is connected and was not offered it yet
+func (o *Offers) offer(courierID string, order *entity.Order) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	c := o.couriers[courierID]
+	if c == nil || c.pending[order.ID] != nil {
+		return
+	}
+	expires := time.Now().Add(o.timeout())
+	if !o.send(c, offerMessage{Type: offerTypeOffer, OrderID: order.ID, Order: order, ExpiresAt: &expires}) {
+		return
+	}
+	id := order.ID
+	c.pending[id] = time.AfterFunc(o.timeout(), func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := c.pending[id]; ok {
+			delete(c.pending, id)
+			o.send(c, offerMessage{Type: offerTypeExpired, OrderID: id})
+		}
+	})
+}
+
+// answer removes the offer of orderID to c, false when it was not offered or expired
+func (o *Offers) answer(c *offerConn, orderID uint64) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	t, ok := c.pending[orderID]
+	if ok {
+		t.Stop()
+		delete(c.pending, orderID)
+	}
+	return ok
+}
+
+// withdraw tells the couriers other than courierID the order is gone
+func (o *Offers) withdraw(orderID uint64, courierID string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for id, c := range o.couriers {
+		if t, ok := c.pending[orderID]; ok && id != courierID {
+			t.Stop()
+			delete(c.pending, orderID)
+			o.send(c, offerMessage{Type: offerTypeWithdrawn, OrderID: orderID})
+		}
+	}
+}
+
+func (o *Offers) push(c *offerConn, m offerMessage) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.send(c, m)
+}
+
+// send queues m for c, false when c is closed or too slow to keep up; called with mu held
+func (o *Offers) send(c *offerConn, m offerMessage) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- m:
+		return true
+	default:
+		c.log.Warn("Offer connection is too slow, message dropped")
+		return false
+	}
+}
+
+func (o *Offers) radius() int {
+	if o.Radius <= 0 {
+		return DefaultOfferRadius
+	}
+	return o.Radius
+}
+
+func (o *Offers) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultOfferTimeout
+	}
+	return o.Timeout
+}
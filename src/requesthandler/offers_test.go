@@ -0,0 +1,143 @@
+package requesthandler
+
+import (
+	"auth"
+	"context"
+	"entity"
+	"github.com/gorilla/websocket"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectCourier connects courierID to the offers of dep, returning once it is registered
+func connectCourier(t *testing.T, dep *Dependencies, courierID string) *websocket.Conn {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &auth.Principal{ID: courierID, Roles: []string{auth.RoleCourier}}
+		dep.HandleCourierOffers(w, r.WithContext(auth.WithPrincipal(r.Context(), p)), nil)
+	}))
+	t.Cleanup(srv.Close)
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	assert.Eventually(t, func() bool {
+		dep.Offers.mu.Lock()
+		defer dep.Offers.mu.Unlock()
+		return dep.Offers.couriers[courierID] != nil
+	}, time.Second, 5*time.Millisecond)
+	return ws
+}
+
+func readOffer(t *testing.T, ws *websocket.Conn) offerMessage {
+	var m offerMessage
+	_ = ws.SetReadDeadline(time.Now().Add(time.Second))
+	if err := ws.ReadJSON(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCourierOffersAccept(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{near, faraway}, nil)
+	dao.On("FindFirstWithIdAndStatus", mock.Anything, StatusUnassigned, id, mock.Anything).Return(true, dispatchOrder())
+	dep := &Dependencies{Dao: dao, Offers: NewOffers(0, time.Minute)}
+	ws := connectCourier(t, dep, near.ID)
+	far := connectCourier(t, dep, faraway.ID)
+
+	dep.offerOrder(context.Background(), *dispatchOrder())
+
+	m := readOffer(t, ws)
+	assert.Equal(t, offerTypeOffer, m.Type)
+	assert.Equal(t, uint64(id), m.OrderID)
+	assert.NotNil(t, m.ExpiresAt)
+	assert.Nil(t, ws.WriteJSON(&offerMessage{Type: offerTypeAccept, OrderID: uint64(id)}))
+	m = readOffer(t, ws)
+	assert.Equal(t, offerTypeAccepted, m.Type)
+	assert.Equal(t, near.ID, m.Order.CourierID)
+	assert.Equal(t, StatusTaken, m.Order.Status)
+	dao.AssertCalled(t, "AssignOrder", mock.Anything, mock.Anything, StatusTaken, StatusUnassigned)
+
+	// beyond the radius
+	_ = far.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	var none offerMessage
+	assert.NotNil(t, far.ReadJSON(&none))
+}
+
+func TestCourierOffersWithdrawnWhenTaken(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{near, nearer}, nil)
+	dao.On("FindFirstWithIdAndStatus", mock.Anything, StatusUnassigned, id, mock.Anything).Return(true, dispatchOrder())
+	dep := &Dependencies{Dao: dao, Offers: NewOffers(0, time.Minute)}
+	first, second := connectCourier(t, dep, near.ID), connectCourier(t, dep, nearer.ID)
+
+	dep.offerOrder(context.Background(), *dispatchOrder())
+	readOffer(t, first)
+	readOffer(t, second)
+	assert.Nil(t, first.WriteJSON(&offerMessage{Type: offerTypeAccept, OrderID: uint64(id)}))
+
+	assert.Equal(t, offerTypeAccepted, readOffer(t, first).Type)
+	m := readOffer(t, second)
+	assert.Equal(t, offerTypeWithdrawn, m.Type)
+	assert.Equal(t, uint64(id), m.OrderID)
+}
+
+func TestCourierOffersNotTaken(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindAvailableCouriers", mock.Anything, mock.Anything, mock.Anything).Return([]entity.Courier{near}, nil)
+	dao.On("FindFirstWithIdAndStatus", mock.Anything, StatusUnassigned, id, mock.Anything).Return(true, dispatchOrder())
+	// at capacity meanwhile
+	dao.On("AssignOrder", mock.Anything, mock.Anything, StatusTaken, StatusUnassigned).Return(&gorm.DB{RowsAffected: 0})
+	dep := &Dependencies{Dao: dao, Offers: NewOffers(0, time.Minute)}
+	ws := connectCourier(t, dep, near.ID)
+
+	dep.offerOrder(context.Background(), *dispatchOrder())
+	readOffer(t, ws)
+	assert.Nil(t, ws.WriteJSON(&offerMessage{Type: offerTypeAccept, OrderID: uint64(id)}))
+
+	m := readOffer(t, ws)
+	assert.Equal(t, offerTypeRejected, m.Type)
+	assert.Equal(t, offerNotTaken, m.Reason)
+}
+
+func TestCourierOffersExpire(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{near}, nil)
+	dep := &Dependencies{Dao: dao, Offers: NewOffers(0, 20*time.Millisecond)}
+	ws := connectCourier(t, dep, near.ID)
+
+	dep.offerOrder(context.Background(), *dispatchOrder())
+	readOffer(t, ws)
+	assert.Equal(t, offerTypeExpired, readOffer(t, ws).Type)
+
+	assert.Nil(t, ws.WriteJSON(&offerMessage{Type: offerTypeAccept, OrderID: uint64(id)}))
+	m := readOffer(t, ws)
+	assert.Equal(t, offerTypeRejected, m.Type)
+	assert.Equal(t, offerNotOffered, m.Reason)
+	dao.AssertNotCalled(t, "AssignOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCourierOffersDecline(t *testing.T) {
+	dao := getMockDaoForDispatch([]entity.Courier{near}, nil)
+	dep := &Dependencies{Dao: dao, Offers: NewOffers(0, time.Minute)}
+	ws := connectCourier(t, dep, near.ID)
+
+	dep.offerOrder(context.Background(), *dispatchOrder())
+	readOffer(t, ws)
+	assert.Nil(t, ws.WriteJSON(&offerMessage{Type: offerTypeDecline, OrderID: uint64(id)}))
+	assert.Nil(t, ws.WriteJSON(&offerMessage{Type: offerTypeAccept, OrderID: uint64(id)}))
+
+	assert.Equal(t, offerNotOffered, readOffer(t, ws).Reason)
+}
+
+func TestCourierOffersUnauthenticated(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Offers: NewOffers(0, 0)}).HandleCourierOffers(w, httptest.NewRequest("GET", "/couriers/offers", nil), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusUnauthorized)
+}
@@ -0,0 +1,177 @@
+package requesthandler
+
+import (
+	"auth"
+	"bytes"
+	"context"
+	"distancehelper"
+	"entity"
+	"errors"
+	"geofence"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"openapi"
+	"strconv"
+	"testing"
+	"time"
+	"webhook"
+)
+
+// specCase is a request served by a handler, whose response must match openapi.json
+type specCase struct {
+	name   string
+	handle httprouter.Handle
+	r      *http.Request
+	ps     httprouter.Params
+	status int
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range specCases(t) {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c.handle(w, c.r, c.ps)
+
+			assert.Equal(t, c.status, w.Code)
+			validateResponse(t, router, c.r, w)
+		})
+	}
+}
+
+func validateResponse(t *testing.T, router routers.Router, r *http.Request, w *httptest.ResponseRecorder) {
+	route, params, err := router.FindRoute(r)
+	if err != nil {
+		t.Fatalf("%s %s is not in openapi.json: %v", r.Method, r.URL.Path, err)
+	}
+	in := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: r, PathParams: params, Route: route},
+		Status:                 w.Code,
+		Header:                 w.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), in); err != nil {
+		t.Errorf("Response %d %s does not match openapi.json: %v", w.Code, w.Body.String(), err)
+	}
+}
+
+func specCases(t *testing.T) []specCase {
+	located := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	admin := &auth.Principal{ID: "admin", Roles: []string{auth.RoleAdmin}}
+	as := func(r *http.Request, p *auth.Principal) *http.Request {
+		return r.WithContext(auth.WithPrincipal(r.Context(), p))
+	}
+	orderParams := httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}}
+
+	taken := getMockDaoForGetOrder(&entity.Order{ID: uint64(id), Status: StatusTaken, Version: 2, CourierID: "c1", Distance: distance,
+		Duration: 95, Price: 2500, Currency: "HKD", OriginAddress: "1 Queen's Road Central", Zone: "central"})
+	taken.On("FindCourier", mock.Anything, "c1", mock.Anything).Return(true, &entity.Courier{ID: "c1", Lat: 22.28, Long: 114.18, Accuracy: 12, LocatedAt: &located})
+
+	listOrders := &GormDBMock{}
+	listOrders.On("FindWithLimitAndOffset", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&[]entity.Order{*order})
+
+	courierLocation := &GormDBMock{}
+	courierLocation.On("SaveCourierLocation", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	couriers := &GormDBMock{}
+	couriers.On("FindCouriers", mock.Anything, mock.Anything).Return([]entity.Courier{{ID: "c1", Capacity: 2, Available: true, Lat: 22.28, Long: 114.18, LocatedAt: &located}}, nil)
+	couriers.On("SaveCourier", mock.Anything, mock.Anything).Return(nil)
+	couriers.On("FindCourier", mock.Anything, "c1", mock.Anything).Return(true, &entity.Courier{ID: "c1", Capacity: 3, Available: true})
+
+	zones := &GormDBMock{}
+	zones.On("ReplaceServiceAreas", mock.Anything, mock.Anything).Return(nil)
+
+	webhooks := &GormDBMock{}
+	webhooks.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{
+		{ID: 1, URL: "https://example.com/hook", Secret: webhookSecret, Events: "order.created,order.taken", CreatedAt: located}}, nil)
+	webhooks.On("CreateWebhookSubscription", mock.Anything, mock.Anything).Return(2, nil)
+	webhooks.On("DeleteWebhookSubscription", mock.Anything, uint64(1)).Return(true, nil)
+	webhooks.On("DeleteWebhookSubscription", mock.Anything, uint64(2)).Return(false, nil)
+	webhooks.On("FindWebhookDeliveries", mock.Anything, uint64(1), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]entity.WebhookDelivery{
+		{ID: 7, SubscriptionID: 1, EventID: 3, Event: webhook.EventOrderCreated, Payload: `{"type":"order.created"}`, Status: entity.WebhookDead,
+			Attempts: 8, NextAttemptAt: located, LastStatus: 500, LastError: "receiver answered 500", CreatedAt: located, UpdatedAt: located}}, nil)
+	webhooks.On("RetryWebhookDelivery", mock.Anything, uint64(1), uint64(7), mock.Anything).Return(true, nil)
+
+	dbDown := &GormDBMock{}
+	dbDown.On("Ping", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+	return []specCase{
+		{"create order", (&Dependencies{Dao: getMockDaoForNewOrder(id, nil), MapHelper: getMockMapForNewOrder(distance, nil)}).HandleNewOrder,
+			newOrderRequest(normalCoordinates), nil, http.StatusOK},
+		{"create order invalid", (&Dependencies{}).HandleNewOrder,
+			newOrderRequest(`{"origin": ["91", "181"], "destination": ["1"], "weight": 1}`), nil, http.StatusBadRequest},
+		{"create order not json", (&Dependencies{}).HandleNewOrder, httptest.NewRequest("POST", "/orders", nil), nil, http.StatusUnsupportedMediaType},
+		{"create order outside service area", (&Dependencies{Zones: getCentralZones(t)}).HandleNewOrder,
+			newOrderRequest(`{"origin": ["22.2802", "114.184919"], "destination": ["25.033964", "121.564468"]}`), nil, http.StatusUnprocessableEntity},
+		{"create order distance unavailable", (&Dependencies{MapHelper: getMockMapForNewOrder(-1, distancehelper.ErrCircuitOpen)}).HandleNewOrder,
+			newOrderRequest(normalCoordinates), nil, http.StatusServiceUnavailable},
+		{"quote", (&Dependencies{MapHelper: getMockMapForNewOrder(10000, nil), Pricing: testPricing}).HandleQuote,
+			newJSONRequest("POST", "/quotes", normalCoordinates), nil, http.StatusOK},
+		{"quote without pricing", (&Dependencies{MapHelper: getMockMapForNewOrder(distance, nil)}).HandleQuote,
+			newJSONRequest("POST", "/quotes", normalCoordinates), nil, http.StatusServiceUnavailable},
+		{"list orders", (&Dependencies{Dao: listOrders}).HandleListOrder, httptest.NewRequest("GET", "/orders?page=1&limit=10", nil), nil, http.StatusOK},
+		{"list orders invalid page", (&Dependencies{}).HandleListOrder, httptest.NewRequest("GET", "/orders?page=-2", nil), nil, http.StatusBadRequest},
+		{"get order", (&Dependencies{Dao: taken}).HandleGetOrder, as(httptest.NewRequest("GET", "/orders/10", nil), admin), orderParams, http.StatusOK},
+		{"get order not found", (&Dependencies{Dao: getMockDaoForGetOrder(nil)}).HandleGetOrder, httptest.NewRequest("GET", "/orders/10", nil), orderParams, http.StatusNotFound},
+		{"take order", (&Dependencies{Dao: getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 1})}).HandleTakeOrder,
+			newJSONRequest("PATCH", "/orders/10", `{"status": "TAKEN"}`), orderParams, http.StatusOK},
+		{"take order stale", (&Dependencies{Dao: getMockDaoForTakeOrder(order, nil)}).HandleTakeOrder,
+			withHeader(newJSONRequest("PATCH", "/orders/10", `{"status": "TAKEN"}`), "If-Match", `"0"`), orderParams, http.StatusPreconditionFailed},
+		{"courier location", (&Dependencies{Dao: courierLocation}).HandleCourierLocation,
+			newJSONRequest("POST", "/couriers/c1/location", `{"lat": 22.28, "lng": 114.18, "accuracy": 12.5}`), courierParams("c1"), http.StatusNoContent},
+		{"courier location of other courier", (&Dependencies{}).HandleCourierLocation,
+			as(newJSONRequest("POST", "/couriers/c2/location", `{"lat": 1, "lng": 1}`), &auth.Principal{ID: "c1", Roles: []string{auth.RoleCourier}}),
+			courierParams("c2"), http.StatusForbidden},
+		{"courier offers unauthenticated", (&Dependencies{Offers: NewOffers(0, 0)}).HandleCourierOffers,
+			httptest.NewRequest("GET", "/couriers/offers", nil), nil, http.StatusUnauthorized},
+		{"list couriers", (&Dependencies{Dao: couriers}).HandleListCouriers, httptest.NewRequest("GET", "/admin/couriers", nil), nil, http.StatusOK},
+		{"save courier", (&Dependencies{Dao: couriers}).HandleSaveCourier,
+			newJSONRequest("PUT", "/admin/couriers/c1", `{"capacity": 3, "available": true}`), courierParams("c1"), http.StatusOK},
+		{"list zones", (&Dependencies{Zones: getCentralZones(t)}).HandleListZones, httptest.NewRequest("GET", "/admin/zones", nil), nil, http.StatusOK},
+		{"replace zones", (&Dependencies{Dao: zones, Zones: &geofence.Zones{}}).HandleReplaceZones,
+			newJSONRequest("PUT", "/admin/zones", zonesBody), nil, http.StatusOK},
+		{"replace zones invalid", (&Dependencies{Zones: &geofence.Zones{}}).HandleReplaceZones,
+			newJSONRequest("PUT", "/admin/zones", `{"type": "FeatureCollection", "features": [{"type": "Feature"}]}`), nil, http.StatusBadRequest},
+		{"list webhooks", (&Dependencies{Dao: webhooks}).HandleListWebhooks, httptest.NewRequest("GET", "/admin/webhooks", nil), nil, http.StatusOK},
+		{"create webhook", (&Dependencies{Dao: webhooks}).HandleCreateWebhook, newJSONRequest("POST", "/admin/webhooks",
+			`{"url": "https://example.com/hook", "secret": "`+webhookSecret+`", "events": ["order.created"]}`), nil, http.StatusCreated},
+		{"delete webhook", (&Dependencies{Dao: webhooks}).HandleDeleteWebhook, httptest.NewRequest("DELETE", "/admin/webhooks/1", nil),
+			webhookParams("1", ""), http.StatusNoContent},
+		{"delete webhook not found", (&Dependencies{Dao: webhooks}).HandleDeleteWebhook, httptest.NewRequest("DELETE", "/admin/webhooks/2", nil),
+			webhookParams("2", ""), http.StatusNotFound},
+		{"list webhook deliveries", (&Dependencies{Dao: webhooks}).HandleListWebhookDeliveries,
+			httptest.NewRequest("GET", "/admin/webhooks/1/deliveries?status=dead", nil), webhookParams("1", ""), http.StatusOK},
+		{"retry webhook delivery", (&Dependencies{Dao: webhooks}).HandleRetryWebhookDelivery,
+			httptest.NewRequest("POST", "/admin/webhooks/1/deliveries/7/retry", nil), webhookParams("1", "7"), http.StatusAccepted},
+		{"health", (&Dependencies{}).HandleHealth, httptest.NewRequest("GET", "/healthz", nil), nil, http.StatusOK},
+		{"ready with database down", (&Dependencies{Dao: dbDown, MapHelper: &ProviderStatusMock{configured: true}}).HandleReady,
+			httptest.NewRequest("GET", "/readyz", nil), nil, http.StatusServiceUnavailable},
+		{"openapi", openapi.Handle, httptest.NewRequest("GET", "/openapi.json", nil), nil, http.StatusOK},
+	}
+}
+
+func withHeader(r *http.Request, name string, value string) *http.Request {
+	r.Header.Set(name, value)
+	return r
+}
+
+func courierParams(courierID string) httprouter.Params {
+	return httprouter.Params{httprouter.Param{Key: "id", Value: courierID}}
+}
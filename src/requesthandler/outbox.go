@@ -0,0 +1,114 @@
+package requesthandler
+
+import (
+	"context"
+	db "dao"
+	"encoding/json"
+	"entity"
+	"outbox"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// orderEvent is the payload of the order events. Events can be published more than once, the order id and version
+// with the type tell them apart.
+type orderEvent struct {
+	Type       string        `json:"type"`
+	OccurredAt time.Time     `json:"occurredAt"`
+	Order      *entity.Order `json:"order"`
+	// of order.taken and order.status_changed
+	PreviousStatus string `json:"previousStatus,omitempty"`
+}
+
+// orderEvents records the change of an order from previousStatus as the events of types, written to the outbox
+// along with the order
+func orderEvents(previousStatus string, types ...string) db.OrderEvents {
+	return func(order *entity.Order) ([]entity.OutboxEvent, error) {
+		now := time.Now()
+		events := make([]entity.OutboxEvent, len(types))
+		for i, t := range types {
+			payload, err := json.Marshal(&orderEvent{Type: t, OccurredAt: now, Order: order, PreviousStatus: previousStatus})
+			if err != nil {
+				return nil, err
+			}
+			events[i] = entity.OutboxEvent{Type: t, Key: strconv.FormatUint(order.ID, 10), Payload: string(payload)}
+		}
+		return events, nil
+	}
+}
+
+// RelayOutbox publishes up to limit events of the outbox to every sink, oldest first, returning how many were
+// relayed. Only one relay publishes at a time, the others return right away. An event is removed once all sinks
+// accepted it; a failing sink gets it and the events after it again on the next relay, the others do not.
+func (dep *Dependencies) RelayOutbox(ctx context.Context, limit int) (int, error) {
+	conn := dep.db(ctx)
+	unlock, locked, err := dep.Dao.LockOutboxRelay(ctx, conn)
+	if err != nil || !locked {
+		return 0, err
+	}
+	defer unlock()
+	var events []entity.OutboxEvent
+	if err := dep.Dao.FindOutboxEvents(conn, limit, &events); err != nil {
+		return 0, err
+	}
+
+	relayed := 0
+	// sinks are not published to after failing, so their events stay in order
+	failed := map[string]bool{}
+	var publishErr error
+	for i := range events {
+		e := &events[i]
+		ev := outbox.Event{ID: e.ID, Type: e.Type, Key: e.Key, Payload: []byte(e.Payload), CreatedAt: e.CreatedAt}
+		published := publishedTo(e.PublishedTo)
+		done, changed := true, false
+		for _, sink := range dep.EventSinks {
+			if published[sink.Name] {
+				continue
+			}
+			if failed[sink.Name] {
+				done = false
+				continue
+			}
+			if err := sink.Publish(ctx, ev); err != nil {
+				failed[sink.Name], done = true, false
+				if publishErr == nil {
+					publishErr = err
+				}
+				continue
+			}
+			published[sink.Name], changed = true, true
+		}
+		if done {
+			if err := dep.Dao.DeleteOutboxEvent(conn, e.ID); err != nil {
+				return relayed, err
+			}
+			relayed++
+		} else if changed {
+			if err := dep.Dao.MarkOutboxEventPublished(conn, e.ID, joinPublishedTo(published)); err != nil {
+				return relayed, err
+			}
+		}
+	}
+	return relayed, publishErr
+}
+
+func publishedTo(s string) map[string]bool {
+	published := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		if name != "" {
+			published[name] = true
+		}
+	}
+	return published
+}
+
+func joinPublishedTo(published map[string]bool) string {
+	names := make([]string, 0, len(published))
+	for name := range published {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,159 @@
+package requesthandler
+
+import (
+	"context"
+	"encoding/json"
+	"entity"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"outbox"
+	"strconv"
+	"strings"
+	"testing"
+	"webhook"
+)
+
+func TestNewOrderWritesOutboxEvent(t *testing.T) {
+	dao := getMockDaoForNewOrder(id, nil)
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(distance, nil)}
+
+	dep.HandleNewOrder(httptest.NewRecorder(), newOrderRequest(normalCoordinates), nil)
+
+	assert.Equal(t, 1, len(dao.Outbox))
+	assert.Equal(t, webhook.EventOrderCreated, dao.Outbox[0].Type)
+	assert.Equal(t, strconv.Itoa(id), dao.Outbox[0].Key)
+	var payload orderEvent
+	_ = json.Unmarshal([]byte(dao.Outbox[0].Payload), &payload)
+	assert.Equal(t, webhook.EventOrderCreated, payload.Type)
+	assert.Equal(t, uint64(id), payload.Order.ID)
+	assert.Equal(t, distance, payload.Order.Distance)
+	assert.Empty(t, payload.PreviousStatus)
+}
+
+func TestNewOrderDBErrorWritesNoEvent(t *testing.T) {
+	dao := getMockDaoForNewOrder(id, errors.New(""))
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(distance, nil)}
+
+	dep.HandleNewOrder(httptest.NewRecorder(), newOrderRequest(normalCoordinates), nil)
+
+	assert.Empty(t, dao.Outbox)
+}
+
+func TestTakeOrderWritesOutboxEvents(t *testing.T) {
+	dao := getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 1})
+	r := httptest.NewRequest("PATCH", "/orders/"+strconv.Itoa(id), strings.NewReader(`{"status":"TAKEN"}`))
+
+	(&Dependencies{Dao: dao}).HandleTakeOrder(httptest.NewRecorder(), r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+	assert.Equal(t, 2, len(dao.Outbox))
+	for i, event := range []string{webhook.EventOrderTaken, webhook.EventOrderStatusChanged} {
+		var payload orderEvent
+		_ = json.Unmarshal([]byte(dao.Outbox[i].Payload), &payload)
+		assert.Equal(t, event, dao.Outbox[i].Type)
+		assert.Equal(t, event, payload.Type)
+		assert.Equal(t, StatusTaken, payload.Order.Status)
+		assert.Equal(t, uint64(2), payload.Order.Version)
+		assert.Equal(t, StatusUnassigned, payload.PreviousStatus)
+	}
+}
+
+func TestTakeOrderUpdateFailedWritesNoEvents(t *testing.T) {
+	dao := getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 0})
+	r := httptest.NewRequest("PATCH", "/orders/"+strconv.Itoa(id), strings.NewReader(`{"status":"TAKEN"}`))
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Dao: dao}).HandleTakeOrder(w, r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, dao.Outbox)
+}
+
+// recordingSink keeps the ids of the events published, failing for the id in failOn
+type recordingSink struct {
+	published []uint64
+	failOn    uint64
+}
+
+func (s *recordingSink) Publish(_ context.Context, e outbox.Event) error {
+	if e.ID == s.failOn {
+		return errors.New("down")
+	}
+	s.published = append(s.published, e.ID)
+	return nil
+}
+
+func TestRelayOutbox(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("LockOutboxRelay", mock.Anything, mock.Anything).Return(true, nil)
+	dao.On("FindOutboxEvents", mock.Anything, 10, mock.Anything).Return([]entity.OutboxEvent{{ID: 1, Type: webhook.EventOrderCreated}, {ID: 2, Type: webhook.EventOrderTaken}}, nil)
+	dao.On("DeleteOutboxEvent", mock.Anything, mock.Anything).Return(nil)
+	first, second := &recordingSink{}, &recordingSink{}
+	dep := &Dependencies{Dao: dao, EventSinks: []outbox.Named{{Name: "first", Sink: first}, {Name: "second", Sink: second}}}
+
+	n, err := dep.RelayOutbox(context.Background(), 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []uint64{1, 2}, first.published)
+	assert.Equal(t, []uint64{1, 2}, second.published)
+	dao.AssertCalled(t, "DeleteOutboxEvent", mock.Anything, uint64(1))
+	dao.AssertCalled(t, "DeleteOutboxEvent", mock.Anything, uint64(2))
+	assert.True(t, dao.Unlocked)
+}
+
+func TestRelayOutboxSinkFailure(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("LockOutboxRelay", mock.Anything, mock.Anything).Return(true, nil)
+	dao.On("FindOutboxEvents", mock.Anything, 10, mock.Anything).Return([]entity.OutboxEvent{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+	dao.On("DeleteOutboxEvent", mock.Anything, mock.Anything).Return(nil)
+	dao.On("MarkOutboxEventPublished", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	first, second := &recordingSink{}, &recordingSink{failOn: 2}
+	dep := &Dependencies{Dao: dao, EventSinks: []outbox.Named{{Name: "first", Sink: first}, {Name: "second", Sink: second}}}
+
+	n, err := dep.RelayOutbox(context.Background(), 10)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, n)
+	// the first sink goes on, the second stops at event 2 to keep the order
+	assert.Equal(t, []uint64{1, 2, 3}, first.published)
+	assert.Equal(t, []uint64{1}, second.published)
+	dao.AssertCalled(t, "DeleteOutboxEvent", mock.Anything, uint64(1))
+	dao.AssertCalled(t, "MarkOutboxEventPublished", mock.Anything, uint64(2), "first")
+	dao.AssertCalled(t, "MarkOutboxEventPublished", mock.Anything, uint64(3), "first")
+	dao.AssertNumberOfCalls(t, "DeleteOutboxEvent", 1)
+}
+
+func TestRelayOutboxSkipsPublishedSinks(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("LockOutboxRelay", mock.Anything, mock.Anything).Return(true, nil)
+	dao.On("FindOutboxEvents", mock.Anything, 10, mock.Anything).Return([]entity.OutboxEvent{{ID: 2, PublishedTo: "first"}}, nil)
+	dao.On("DeleteOutboxEvent", mock.Anything, mock.Anything).Return(nil)
+	first, second := &recordingSink{}, &recordingSink{}
+	dep := &Dependencies{Dao: dao, EventSinks: []outbox.Named{{Name: "first", Sink: first}, {Name: "second", Sink: second}}}
+
+	n, err := dep.RelayOutbox(context.Background(), 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, first.published)
+	assert.Equal(t, []uint64{2}, second.published)
+}
+
+func TestRelayOutboxLockedElsewhere(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("LockOutboxRelay", mock.Anything, mock.Anything).Return(false, nil)
+	sink := &recordingSink{}
+	dep := &Dependencies{Dao: dao, EventSinks: []outbox.Named{{Name: "first", Sink: sink}}}
+
+	n, err := dep.RelayOutbox(context.Background(), 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, sink.published)
+	dao.AssertNotCalled(t, "FindOutboxEvents", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,117 @@
+package requesthandler
+
+import (
+	"distancehelper"
+	"geo"
+	"github.com/julienschmidt/httprouter"
+	"logging"
+	"net/http"
+	"pricing"
+	"request"
+	"responseutil"
+	"time"
+)
+
+// trip is a validated order request with its route
+type trip struct {
+	req       *request.PlaceOrderRequest
+	addresses *pointAddresses
+	// the coordinates of req, parsed once they are all known
+	origin      geo.Point
+	destination geo.Point
+	zone        string
+	route       distancehelper.Route
+}
+
+// HandleQuote prices an order request without placing the order
+func (dep *Dependencies) HandleQuote(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if dep.Pricing == nil {
+		responseutil.WriteError(w, r, http.StatusServiceUnavailable, responseutil.CodePricingUnavailable, "Pricing is not configured")
+		return
+	}
+	t, ok := dep.planTrip(w, r)
+	if !ok {
+		return
+	}
+	responseutil.WriteJSONToResponse(dep.quote(t), w)
+}
+
+// planTrip reads the order request, resolves its places and looks up the route. When any of it fails the
+// problem is written and false is returned.
+func (dep *Dependencies) planTrip(w http.ResponseWriter, r *http.Request) (*trip, bool) {
+	if !checkContentType(r, w, "application/json") {
+		return nil, false
+	}
+
+	// get body, check JSON and coordinates
+	orderRequest, violations, err := request.ParsePlaceOrderRequest(r.Body)
+	if err != nil {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not a valid JSON object")
+		return nil, false
+	}
+	if len(violations) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed,
+			"Invalid order request", toFieldErrors(violations)...))
+		return nil, false
+	}
+	addresses, ok := dep.resolvePlaces(w, r, orderRequest)
+	if !ok {
+		return nil, false
+	}
+	t := &trip{req: orderRequest, addresses: addresses}
+	if !t.parsePoints(w, r) {
+		return nil, false
+	}
+	if t.zone, ok = dep.locateZone(w, r, t); !ok {
+		return nil, false
+	}
+	if problem := dep.RouteRules.checkPoints(t.origin, t.destination); problem != nil {
+		responseutil.WriteProblem(w, r, problem)
+		return nil, false
+	}
+
+	// Get distance
+	route, err := dep.MapHelper.GetRoute(r.Context(), orderRequest, dep.Map)
+	if err == distancehelper.ErrCircuitOpen {
+		responseutil.WriteError(w, r, http.StatusServiceUnavailable, responseutil.CodeDistanceUnavailable, "Distance provider unavailable, please retry later.")
+		return nil, false
+	}
+	if err != nil {
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeDistanceUnavailable, "Cannot find distance")
+		return nil, false
+	}
+	if route.DistanceMeters == -1 {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeDistanceNotFound, "Cannot find distance, please check your input.")
+		return nil, false
+	}
+	if problem := dep.RouteRules.checkRoute(route); problem != nil {
+		responseutil.WriteProblem(w, r, problem)
+		return nil, false
+	}
+	t.route = route
+	return t, true
+}
+
+// parsePoints reads the coordinates of the request, which were validated or filled in by the geocoder. When they
+// cannot be read the problem is written and false is returned.
+func (t *trip) parsePoints(w http.ResponseWriter, r *http.Request) bool {
+	var err error
+	if t.origin, err = geo.ParsePoint(t.req.Origin); err == nil {
+		t.destination, err = geo.ParsePoint(t.req.Destination)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Cannot read the order points: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Order points could not be read")
+		return false
+	}
+	return true
+}
+
+// quote prices the trip as of now, nil without pricing rules
+func (dep *Dependencies) quote(t *trip) *pricing.Quote {
+	if dep.Pricing == nil {
+		return nil
+	}
+	return dep.Pricing.Price(pricing.Trip{DistanceMeters: t.route.DistanceMeters, Duration: t.route.Duration,
+		Origin: t.origin, Destination: t.destination, At: time.Now()})
+}
@@ -0,0 +1,74 @@
+package requesthandler
+
+import (
+	"encoding/json"
+	"entity"
+	"net/http"
+	"net/http/httptest"
+	"pricing"
+	"strings"
+	"testing"
+)
+
+// 10 + 4 per km + 0.5 per minute, at least 25
+var testPricing, _ = pricing.ParseRules(strings.NewReader(`{"currency": "HKD", "baseFare": 1000, "perKm": 400, "perMinute": 50, "minimumFare": 2500}`))
+
+func TestQuote(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{MapHelper: getMockMapForNewOrder(10000, nil), Pricing: testPricing}
+
+	dep.HandleQuote(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	var q pricing.Quote
+	_ = json.NewDecoder(w.Body).Decode(&q)
+	// 95s are rounded to 79.17 cents
+	if q.Currency != "HKD" || q.Amount != 5079 || q.DistanceFare != 4000 {
+		t.Errorf("Unexpected quote %+v", q)
+	}
+}
+
+func TestQuoteMinimumFare(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{MapHelper: getMockMapForNewOrder(distance, nil), Pricing: testPricing}
+
+	dep.HandleQuote(w, newOrderRequest(normalCoordinates), nil)
+
+	var q pricing.Quote
+	_ = json.NewDecoder(w.Body).Decode(&q)
+	if q.Amount != 2500 || !q.MinimumFare {
+		t.Errorf("Expected minimum fare, got %+v", q)
+	}
+}
+
+func TestQuoteWithoutPricing(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{MapHelper: getMockMapForNewOrder(distance, nil)}
+
+	dep.HandleQuote(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusServiceUnavailable)
+}
+
+func TestQuoteInvalidRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Pricing: testPricing}
+
+	dep.HandleQuote(w, newOrderRequest(`{"origin": ["91", "1"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+}
+
+func TestNewOrderPriced(t *testing.T) {
+	w := httptest.NewRecorder()
+	dao := getMockDaoForNewOrder(id, nil)
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(10000, nil), Pricing: testPricing}
+
+	dep.HandleNewOrder(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	created := dao.Calls[0].Arguments.Get(1).(*entity.Order)
+	if created.Price != 5079 || created.Currency != "HKD" || created.Duration != 95 {
+		t.Errorf("Expected priced order, got %+v", created)
+	}
+}
@@ -0,0 +1,43 @@
+package requesthandler
+
+import (
+	"distancehelper"
+	"fmt"
+	"geo"
+	"net/http"
+	"responseutil"
+	"time"
+)
+
+// RouteRules are the business rules orders must meet. Zero values disable a rule.
+type RouteRules struct {
+	MinDistanceMeters int
+	MaxDistanceMeters int
+	MaxDuration       time.Duration
+	// reject orders whose origin and destination are the same coordinates
+	RejectIdenticalPoints bool
+}
+
+// checkPoints applies the rules which need no route, so the distance lookup is saved
+func (rr *RouteRules) checkPoints(origin geo.Point, destination geo.Point) *responseutil.Problem {
+	if rr.RejectIdenticalPoints && origin == destination {
+		return responseutil.NewProblem(http.StatusUnprocessableEntity, responseutil.CodeRouteIdenticalPoints, "Origin and destination are the same")
+	}
+	return nil
+}
+
+// checkRoute applies the rules on the route found
+func (rr *RouteRules) checkRoute(route distancehelper.Route) *responseutil.Problem {
+	switch {
+	case rr.MinDistanceMeters > 0 && route.DistanceMeters < rr.MinDistanceMeters:
+		return responseutil.NewProblem(http.StatusUnprocessableEntity, responseutil.CodeRouteTooShort,
+			fmt.Sprintf("Distance of %d m is below the minimum of %d m", route.DistanceMeters, rr.MinDistanceMeters))
+	case rr.MaxDistanceMeters > 0 && route.DistanceMeters > rr.MaxDistanceMeters:
+		return responseutil.NewProblem(http.StatusUnprocessableEntity, responseutil.CodeRouteTooLong,
+			fmt.Sprintf("Distance of %d m exceeds the maximum of %d m", route.DistanceMeters, rr.MaxDistanceMeters))
+	case rr.MaxDuration > 0 && route.Duration > rr.MaxDuration:
+		return responseutil.NewProblem(http.StatusUnprocessableEntity, responseutil.CodeRouteDurationTooLong,
+			fmt.Sprintf("Duration of %v exceeds the maximum of %v", route.Duration, rr.MaxDuration))
+	}
+	return nil
+}
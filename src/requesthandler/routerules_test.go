@@ -0,0 +1,63 @@
+package requesthandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"responseutil"
+	"testing"
+	"time"
+)
+
+func TestRouteRules(t *testing.T) {
+	rules := RouteRules{MinDistanceMeters: 100, MaxDistanceMeters: 50000, MaxDuration: time.Hour, RejectIdenticalPoints: true}
+	tests := []struct {
+		body     string
+		distance int
+		code     string
+	}{
+		{normalCoordinates, 73, responseutil.CodeRouteTooShort},
+		{normalCoordinates, 50001, responseutil.CodeRouteTooLong},
+		{normalCoordinates, 1000, ""},
+		{`{"origin": ["22.2802", "114.184919"], "destination": ["22.28020", "114.1849190"]}`, 0, responseutil.CodeRouteIdenticalPoints},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		dep := &Dependencies{Dao: getMockDaoForNewOrder(id, nil), MapHelper: getMockMapForNewOrder(test.distance, nil), RouteRules: rules}
+
+		dep.HandleNewOrder(w, newOrderRequest(test.body), nil)
+
+		var problem responseutil.Problem
+		_ = json.NewDecoder(w.Body).Decode(&problem)
+		if problem.Code != test.code {
+			t.Errorf("Order %s with distance %d: expected code %q, got %q", test.body, test.distance, test.code, problem.Code)
+		}
+		if test.code != "" && w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422, got %d", w.Code)
+		}
+	}
+}
+
+func TestRouteRulesDuration(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{MapHelper: getMockMapForNewOrder(1000, nil), RouteRules: RouteRules{MaxDuration: time.Minute}}
+
+	dep.HandleNewOrder(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusUnprocessableEntity)
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	if problem.Code != responseutil.CodeRouteDurationTooLong {
+		t.Errorf("Expected %s, got %s", responseutil.CodeRouteDurationTooLong, problem.Code)
+	}
+}
+
+func TestRouteRulesDisabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Dao: getMockDaoForNewOrder(id, nil), MapHelper: getMockMapForNewOrder(0, nil)}
+
+	dep.HandleNewOrder(w, newOrderRequest(`{"origin": ["1", "1"], "destination": ["1", "1"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+}
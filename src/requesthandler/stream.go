@@ -0,0 +1,132 @@
+package requesthandler
+
+import (
+	"encoding/json"
+	"entity"
+	"errors"
+	"eventbus"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"logging"
+	"net/http"
+	"responseutil"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultStreamHistory = 1000
+
+	// event sent when the events after Last-Event-ID are not all kept, clients should reload the orders
+	streamReset     = "reset"
+	streamBuffer    = 64
+	streamHeartbeat = 15 * time.Second
+	// client reconnection delay
+	streamRetry = 3 * time.Second
+	// each write has as long, the write timeout of the server does not apply to streams
+	streamWriteTimeout = 10 * time.Second
+)
+
+// HandleOrderStream streams the changes of orders as Server-Sent Events, one per order created or taken, with the
+// order as data. Clients reconnecting with Last-Event-ID get the events they missed, or a reset event when they
+// cannot be told. The status query parameter, e.g. status=UNASSIGNED, only streams orders changed to the statuses.
+func (dep *Dependencies) HandleOrderStream(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	statuses, err := getStatusFilter(r)
+	if err != nil {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeInvalidQuery, "Invalid query parameters", *err))
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		// for clients which cannot set headers
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+
+	sub, missed, resumed := dep.Events.Subscribe(lastID, streamBuffer)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if !resumed {
+		_, _ = io.WriteString(w, "event: "+streamReset+"\ndata: {}\n\n")
+	}
+	for _, e := range missed {
+		if statuses == nil || statuses[e.Status] {
+			writeStreamEvent(w, e)
+		}
+	}
+	if err := flushStream(rc); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, _ = io.WriteString(w, ": heartbeat\n\n")
+		case e, ok := <-sub.Events():
+			if !ok {
+				// dropped as too slow or shutting down, the client resumes after reconnecting
+				return
+			}
+			if statuses != nil && !statuses[e.Status] {
+				continue
+			}
+			writeStreamEvent(w, e)
+		}
+		if err := flushStream(rc); err != nil {
+			logging.FromContext(r.Context()).Debugf("Order stream closed: %v", err)
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w io.Writer, e eventbus.Event) {
+	_, _ = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
+
+func flushStream(rc *http.ResponseController) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return rc.Flush()
+}
+
+// return the statuses of the status query parameters, comma separated or repeated, nil if there are none
+func getStatusFilter(r *http.Request) (map[string]bool, *responseutil.FieldError) {
+	var statuses map[string]bool
+	for _, param := range r.URL.Query()["status"] {
+		for _, s := range strings.Split(param, ",") {
+			if s != StatusUnassigned && s != StatusTaken {
+				return nil, &responseutil.FieldError{Field: "status", Code: "enum", Detail: fmt.Sprintf("must be %s or %s", StatusUnassigned, StatusTaken), Value: s}
+			}
+			if statuses == nil {
+				statuses = map[string]bool{}
+			}
+			statuses[s] = true
+		}
+	}
+	return statuses, nil
+}
+
+// publishOrder tells the order streams of the change of order
+func (dep *Dependencies) publishOrder(eventType string, order *entity.Order) {
+	if dep.Events == nil {
+		return
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		return
+	}
+	dep.Events.Publish(eventType, order.Status, data)
+}
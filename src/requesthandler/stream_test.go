@@ -0,0 +1,128 @@
+package requesthandler
+
+import (
+	"bufio"
+	"eventbus"
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"webhook"
+)
+
+// streamOrders connects to the order stream of dep, calling ready once subscribed. It returns the lines read until
+// the stream closes or the line after the wanted number of events.
+func streamOrders(t *testing.T, dep *Dependencies, query string, lastID string, events int, ready func()) []string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dep.HandleOrderStream(w, r, nil)
+	}))
+	defer srv.Close()
+	req, _ := http.NewRequest("GET", srv.URL+"/orders/stream"+query, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Cannot connect: %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for events > 0 && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		// the retry is only written after subscribing
+		if len(lines) == 1 && ready != nil {
+			ready()
+		}
+		if scanner.Text() == "" && len(lines) > 1 && strings.HasPrefix(lines[len(lines)-2], "data:") {
+			events--
+		}
+	}
+	return lines
+}
+
+func TestOrderStream(t *testing.T) {
+	bus := eventbus.New(10)
+	dao := getMockDaoForNewOrder(id, nil)
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(distance, nil), Events: bus}
+
+	lines := streamOrders(t, dep, "", "", 1, func() {
+		dep.HandleNewOrder(httptest.NewRecorder(), newOrderRequest(normalCoordinates), nil)
+	})
+
+	assert.Equal(t, "retry: 3000", lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "id: "))
+	assert.Equal(t, "event: "+webhook.EventOrderCreated, lines[3])
+	assert.Contains(t, lines[4], `"id":`+strconv.Itoa(id)+`,"distance":`+strconv.Itoa(distance))
+	assert.Contains(t, lines[4], `"status":"UNASSIGNED"`)
+}
+
+func TestOrderStreamResume(t *testing.T) {
+	bus := eventbus.New(10)
+	s, _, _ := bus.Subscribe("", 10)
+	bus.Publish(webhook.EventOrderCreated, StatusUnassigned, []byte(`{"id":1}`))
+	bus.Publish(webhook.EventOrderCreated, StatusUnassigned, []byte(`{"id":2}`))
+	bus.Publish(webhook.EventOrderTaken, StatusTaken, []byte(`{"id":1}`))
+	first := <-s.Events()
+	s.Close()
+
+	lines := streamOrders(t, &Dependencies{Events: bus}, "?status=TAKEN", first.ID, 1, nil)
+
+	assert.Equal(t, []string{"retry: 3000", "", "id: " + strings.TrimSuffix(first.ID, "1") + "3", "event: order.taken", `data: {"id":1}`, ""}, lines)
+}
+
+func TestOrderStreamReset(t *testing.T) {
+	bus := eventbus.New(10)
+	bus.Publish(webhook.EventOrderCreated, StatusUnassigned, []byte(`{"id":1}`))
+
+	// the events before the restart are gone, only the reset is sent
+	lines := streamOrders(t, &Dependencies{Events: bus}, "", "before-restart-7", 1, nil)
+
+	assert.Equal(t, []string{"retry: 3000", "", "event: reset", "data: {}", ""}, lines)
+}
+
+func TestOrderStreamTakenOrder(t *testing.T) {
+	bus := eventbus.New(10)
+	dep := &Dependencies{Dao: getMockDaoForTakeOrder(order, &gorm.DB{RowsAffected: 1}), Events: bus}
+	s, _, _ := bus.Subscribe("", 10)
+	defer s.Close()
+	r := httptest.NewRequest("PATCH", "/orders/"+strconv.Itoa(id), strings.NewReader(`{"status":"TAKEN"}`))
+
+	dep.HandleTakeOrder(httptest.NewRecorder(), r, httprouter.Params{httprouter.Param{Key: "id", Value: strconv.Itoa(id)}})
+
+	e := <-s.Events()
+	assert.Equal(t, webhook.EventOrderTaken, e.Type)
+	assert.Equal(t, StatusTaken, e.Status)
+	assert.Contains(t, string(e.Data), `"version":2`)
+}
+
+func TestOrderStreamClosedOnShutdown(t *testing.T) {
+	bus := eventbus.New(10)
+
+	lines := streamOrders(t, &Dependencies{Events: bus}, "", "", 1, bus.Close)
+
+	assert.Equal(t, []string{"retry: 3000", ""}, lines)
+}
+
+func TestOrderStreamInvalidStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Events: eventbus.New(10)}).HandleOrderStream(w, httptest.NewRequest("GET", "/orders/stream?status=UNASSIGNED,DONE", nil), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+}
+
+func TestStatusFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/orders/stream?status=UNASSIGNED&status=TAKEN", nil)
+
+	statuses, err := getStatusFilter(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{StatusUnassigned: true, StatusTaken: true}, statuses)
+}
@@ -0,0 +1,345 @@
+package requesthandler
+
+import (
+	"context"
+	"entity"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"logging"
+	"net/http"
+	"net/url"
+	"outbox"
+	"responseutil"
+	"strconv"
+	"strings"
+	"time"
+	"webhook"
+)
+
+const (
+	DefaultWebhookMaxAttempts = 8
+	DefaultWebhookBackoff     = 10 * time.Second
+	DefaultWebhookMaxBackoff  = time.Hour
+
+	webhookSecretMinLength = 16
+	webhookURLMaxLength    = 2048
+	webhookErrorMaxLength  = 1024
+	// other attempts are held off while one is in flight, longer when the send timeout plus margin is longer
+	webhookClaim       = time.Minute
+	webhookClaimMargin = 30 * time.Second
+)
+
+// WebhookConfig configures the delivery of order events to webhook subscriptions
+type WebhookConfig struct {
+	Sender *webhook.Sender
+	// deliveries failing as many times are dead, and only retried on request
+	MaxAttempts int
+	Backoff     webhook.Backoff
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+type webhookResponse struct {
+	ID        uint64    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HandleCreateWebhook subscribes a URL to order events
+func (dep *Dependencies) HandleCreateWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !checkContentType(r, w, "application/json") {
+		return
+	}
+	var req webhookRequest
+	errs, err := decodeObject(r.Body, &req)
+	if err != nil {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not a valid JSON object")
+		return
+	}
+	if len(errs) == 0 {
+		errs = req.validate(dep.Webhooks.Sender)
+	}
+	if len(errs) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, "Invalid webhook", errs...))
+		return
+	}
+
+	sub := &entity.WebhookSubscription{URL: req.URL, Secret: req.Secret, Events: strings.Join(uniqueEvents(req.Events), ",")}
+	if err := dep.Dao.CreateWebhookSubscription(dep.db(r.Context()), sub); err != nil {
+		logging.FromContext(r.Context()).Errorf("Cannot create webhook: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Webhook could not be created")
+		return
+	}
+	logging.FromContext(r.Context()).WithField("webhook_id", sub.ID).Infof("Webhook created for %s", sub.Events)
+
+	w.Header().Set("Location", fmt.Sprintf("/admin/webhooks/%d", sub.ID))
+	responseutil.WriteJSONToResponseWithStatus(toWebhookResponse(sub), w, http.StatusCreated)
+}
+
+// HandleListWebhooks returns the webhook subscriptions, without their secrets
+func (dep *Dependencies) HandleListWebhooks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var subs []entity.WebhookSubscription
+	if err := dep.Dao.FindWebhookSubscriptions(dep.db(r.Context()), &subs); err != nil {
+		logging.FromContext(r.Context()).Errorf("Cannot list webhooks: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Webhooks could not be listed")
+		return
+	}
+	res := make([]*webhookResponse, len(subs))
+	for i := range subs {
+		res[i] = toWebhookResponse(&subs[i])
+	}
+	responseutil.WriteJSONToResponse(res, w)
+}
+
+// HandleDeleteWebhook unsubscribes, dropping the deliveries of the subscription
+func (dep *Dependencies) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := getIdParam(w, r, ps, "id")
+	if !ok {
+		return
+	}
+	found, err := dep.Dao.DeleteWebhookSubscription(dep.db(r.Context()), id)
+	if err != nil {
+		logging.FromContext(r.Context()).WithField("webhook_id", id).Errorf("Cannot delete webhook: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Webhook could not be deleted")
+		return
+	}
+	if !found {
+		responseutil.WriteError(w, r, http.StatusNotFound, responseutil.CodeWebhookNotFound, fmt.Sprintf("Webhook id %d not found", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListWebhookDeliveries returns the delivery log of a subscription, the latest first. The status query
+// parameter filters by pending, delivered or dead.
+func (dep *Dependencies) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := getIdParam(w, r, ps, "id")
+	if !ok {
+		return
+	}
+	page, limit, errs := getPageAndLimit(r)
+	status := r.URL.Query().Get("status")
+	if status != "" && status != entity.WebhookPending && status != entity.WebhookDelivered && status != entity.WebhookDead {
+		errs = append(errs, responseutil.FieldError{Field: "status", Code: "enum", Detail: "must be pending, delivered or dead", Value: status})
+	}
+	if len(errs) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeInvalidQuery, "Invalid query parameters", errs...))
+		return
+	}
+
+	deliveries := []entity.WebhookDelivery{}
+	if err := dep.Dao.FindWebhookDeliveries(dep.db(r.Context()), id, status, limit, (page-1)*limit, &deliveries); err != nil {
+		logging.FromContext(r.Context()).WithField("webhook_id", id).Errorf("Cannot list webhook deliveries: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Deliveries could not be listed")
+		return
+	}
+	responseutil.WriteJSONToResponse(&deliveries, w)
+}
+
+// HandleRetryWebhookDelivery makes a dead delivery pending again
+func (dep *Dependencies) HandleRetryWebhookDelivery(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, ok := getIdParam(w, r, ps, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := getIdParam(w, r, ps, "delivery")
+	if !ok {
+		return
+	}
+	found, err := dep.Dao.RetryWebhookDelivery(dep.db(r.Context()), id, deliveryID, time.Now())
+	if err != nil {
+		logging.FromContext(r.Context()).WithField("webhook_id", id).Errorf("Cannot retry webhook delivery: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Delivery could not be retried")
+		return
+	}
+	if !found {
+		responseutil.WriteError(w, r, http.StatusNotFound, responseutil.CodeDeliveryNotFound, fmt.Sprintf("Dead delivery id %d of webhook %d not found", deliveryID, id))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (req *webhookRequest) validate(sender *webhook.Sender) []responseutil.FieldError {
+	var errs []responseutil.FieldError
+	if u, err := url.Parse(req.URL); req.URL == "" {
+		errs = append(errs, responseutil.FieldError{Field: "url", Code: "required", Detail: "is required"})
+	} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, responseutil.FieldError{Field: "url", Code: "url", Detail: "must be an absolute http or https URL", Value: req.URL})
+	} else if len(req.URL) > webhookURLMaxLength {
+		errs = append(errs, responseutil.FieldError{Field: "url", Code: "maxLength", Detail: fmt.Sprintf("must be at most %d characters", webhookURLMaxLength)})
+	} else if sender != nil && sender.CheckURL(u) != nil {
+		errs = append(errs, responseutil.FieldError{Field: "url", Code: "forbiddenTarget", Detail: "must not be a loopback, private or link-local address", Value: req.URL})
+	}
+	if len(req.Secret) < webhookSecretMinLength {
+		errs = append(errs, responseutil.FieldError{Field: "secret", Code: "minLength", Detail: fmt.Sprintf("must be at least %d characters", webhookSecretMinLength)})
+	}
+	if len(req.Events) == 0 {
+		errs = append(errs, responseutil.FieldError{Field: "events", Code: "required", Detail: "must list at least one event"})
+	}
+	for i, e := range req.Events {
+		if !webhook.ValidEvent(e) {
+			errs = append(errs, responseutil.FieldError{Field: fmt.Sprintf("events[%d]", i), Code: "enum",
+				Detail: "must be one of " + strings.Join(webhook.Events, ", "), Value: e})
+		}
+	}
+	return errs
+}
+
+func uniqueEvents(events []string) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func toWebhookResponse(sub *entity.WebhookSubscription) *webhookResponse {
+	return &webhookResponse{ID: sub.ID, URL: sub.URL, Events: strings.Split(sub.Events, ","), CreatedAt: sub.CreatedAt}
+}
+
+// return the positive id named name from path, in case of err, the error response is written
+func getIdParam(w http.ResponseWriter, r *http.Request, ps httprouter.Params, name string) (uint64, bool) {
+	ids := ps.ByName(name)
+	id, err := strconv.ParseUint(ids, 10, 64)
+	if err != nil || id < 1 {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidID, fmt.Sprintf("Invalid Id: %s", ids))
+		return 0, false
+	}
+	return id, true
+}
+
+// WebhookSink fans the events of the outbox out to the webhook subscriptions, as deliveries sent by
+// DeliverWebhooks
+func (dep *Dependencies) WebhookSink() outbox.Sink {
+	return outbox.SinkFunc(dep.enqueueWebhooks)
+}
+
+func (dep *Dependencies) enqueueWebhooks(ctx context.Context, e outbox.Event) error {
+	conn := dep.db(ctx)
+	var subs []entity.WebhookSubscription
+	if err := dep.Dao.FindWebhookSubscriptions(conn, &subs); err != nil {
+		return err
+	}
+	now := time.Now()
+	var deliveries []entity.WebhookDelivery
+	for _, sub := range subs {
+		if subscribed(&sub, e.Type) {
+			deliveries = append(deliveries, entity.WebhookDelivery{SubscriptionID: sub.ID, EventID: e.ID, Event: e.Type,
+				Payload: string(e.Payload), Status: entity.WebhookPending, NextAttemptAt: now})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return dep.Dao.CreateWebhookDeliveries(conn, deliveries)
+}
+
+func subscribed(sub *entity.WebhookSubscription, event string) bool {
+	for _, e := range strings.Split(sub.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliverWebhooks attempts up to limit due deliveries, returning how many were delivered. Failed deliveries are
+// retried with exponential backoff until they are dead after MaxAttempts.
+func (dep *Dependencies) DeliverWebhooks(ctx context.Context, limit int) (int, error) {
+	conn := dep.db(ctx)
+	var due []entity.WebhookDelivery
+	if err := dep.Dao.FindDueWebhookDeliveries(conn, time.Now(), limit, &due); err != nil {
+		return 0, err
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+	var subs []entity.WebhookSubscription
+	if err := dep.Dao.FindWebhookSubscriptions(conn, &subs); err != nil {
+		return 0, err
+	}
+	byID := make(map[uint64]*entity.WebhookSubscription, len(subs))
+	for i := range subs {
+		byID[subs[i].ID] = &subs[i]
+	}
+
+	delivered := 0
+	for i := range due {
+		ok, err := dep.deliverWebhook(ctx, &due[i], byID[due[i].SubscriptionID])
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// deliverWebhook makes one attempt at d, false when it failed or was attempted elsewhere
+func (dep *Dependencies) deliverWebhook(ctx context.Context, d *entity.WebhookDelivery, sub *entity.WebhookSubscription) (bool, error) {
+	conn := dep.db(ctx)
+	claimed, err := dep.Dao.ClaimWebhookDelivery(conn, d, time.Now().Add(dep.Webhooks.claim()))
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	logger := logging.FromContext(ctx).WithField("delivery_id", d.ID).WithField("webhook_id", d.SubscriptionID)
+	var sendErr error
+	if sub == nil {
+		// deleted after the delivery was found
+		sendErr = fmt.Errorf("webhook %d not found", d.SubscriptionID)
+		d.Attempts = dep.Webhooks.maxAttempts()
+	} else {
+		d.LastStatus, sendErr = dep.Webhooks.Sender.Send(ctx, webhook.Message{URL: sub.URL, Secret: sub.Secret, Event: d.Event,
+			DeliveryID: strconv.FormatUint(d.ID, 10), Body: []byte(d.Payload)})
+	}
+
+	now := time.Now()
+	switch {
+	case sendErr == nil:
+		d.Status, d.LastError, d.DeliveredAt = entity.WebhookDelivered, "", &now
+	case d.Attempts >= dep.Webhooks.maxAttempts():
+		d.Status, d.LastError = entity.WebhookDead, truncate(sendErr.Error(), webhookErrorMaxLength)
+		logger.Warnf("Webhook delivery dead after %d attempts: %v", d.Attempts, sendErr)
+	default:
+		d.LastError = truncate(sendErr.Error(), webhookErrorMaxLength)
+		d.NextAttemptAt = now.Add(dep.Webhooks.backoff().Delay(d.Attempts))
+		logger.Infof("Webhook delivery attempt %d failed, retrying at %v: %v", d.Attempts, d.NextAttemptAt, sendErr)
+	}
+	if err := dep.Dao.UpdateWebhookDelivery(conn, d); err != nil {
+		return false, err
+	}
+	return sendErr == nil, nil
+}
+
+func (c *WebhookConfig) maxAttempts() int {
+	if c.MaxAttempts <= 0 {
+		return DefaultWebhookMaxAttempts
+	}
+	return c.MaxAttempts
+}
+
+// claim outlasts a send, so a slow receiver does not get the delivery twice
+func (c *WebhookConfig) claim() time.Duration {
+	if c.Sender != nil && c.Sender.Client.Timeout+webhookClaimMargin > webhookClaim {
+		return c.Sender.Client.Timeout + webhookClaimMargin
+	}
+	return webhookClaim
+}
+
+func (c *WebhookConfig) backoff() webhook.Backoff {
+	if c.Backoff.Base <= 0 {
+		return webhook.Backoff{Base: DefaultWebhookBackoff, Max: DefaultWebhookMaxBackoff}
+	}
+	return c.Backoff
+}
@@ -0,0 +1,299 @@
+package requesthandler
+
+import (
+	"context"
+	"encoding/json"
+	"entity"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"outbox"
+	"responseutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"webhook"
+)
+
+const webhookSecret = "0123456789abcdef"
+
+func TestCreateWebhook(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("CreateWebhookSubscription", mock.Anything, mock.MatchedBy(func(s *entity.WebhookSubscription) bool {
+		return s.URL == "https://example.com/hook" && s.Secret == webhookSecret && s.Events == "order.created,order.taken"
+	})).Return(3, nil)
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Dao: dao}).HandleCreateWebhook(w, newJSONRequest("POST", "/admin/webhooks",
+		`{"url": "https://example.com/hook", "secret": "`+webhookSecret+`", "events": ["order.created", "order.taken", "order.created"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusCreated)
+	assert.Equal(t, "/admin/webhooks/3", w.Header().Get("Location"))
+	assert.NotContains(t, w.Body.String(), webhookSecret)
+	var res webhookResponse
+	_ = json.NewDecoder(w.Body).Decode(&res)
+	assert.Equal(t, []string{webhook.EventOrderCreated, webhook.EventOrderTaken}, res.Events)
+	dao.AssertExpectations(t)
+}
+
+func TestCreateWebhookInvalid(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	(&Dependencies{}).HandleCreateWebhook(w, newJSONRequest("POST", "/admin/webhooks",
+		`{"url": "ftp://example.com", "secret": "short", "events": ["order.created", "order.deleted"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	var fields []string
+	for _, e := range problem.Errors {
+		fields = append(fields, e.Field+" "+e.Code)
+	}
+	assert.Equal(t, []string{"url url", "secret minLength", "events[1] enum"}, fields)
+}
+
+func TestCreateWebhookForbiddenTarget(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Webhooks: WebhookConfig{Sender: webhook.NewSender(time.Second, false)}}
+
+	dep.HandleCreateWebhook(w, newJSONRequest("POST", "/admin/webhooks",
+		`{"url": "http://169.254.169.254/latest", "secret": "`+webhookSecret+`", "events": ["order.created"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	assert.Contains(t, w.Body.String(), `"field":"url","code":"forbiddenTarget"`)
+}
+
+func TestCreateWebhookMalformed(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&Dependencies{}).HandleCreateWebhook(w, newJSONRequest("POST", "/admin/webhooks",
+		`{"url": "https://example.com", "filter": 1, "events": ["order.created", 1]}`), nil)
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	assert.Equal(t, []responseutil.FieldError{
+		{Field: "events", Code: "type", Detail: "must be a list of strings"},
+		{Field: "filter", Code: "unknown", Detail: "unknown field"},
+	}, problem.Errors)
+
+	w = httptest.NewRecorder()
+	(&Dependencies{}).HandleCreateWebhook(w, newJSONRequest("POST", "/admin/webhooks", `{"url": "https://example.com"} {}`), nil)
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	assert.Contains(t, w.Body.String(), `"detail":"Request body is not a valid JSON object"`)
+
+	w = httptest.NewRecorder()
+	(&Dependencies{}).HandleCreateWebhook(w, newJSONRequest("POST", "/admin/webhooks", `{}`), nil)
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+	assert.Contains(t, w.Body.String(), `"field":"events","code":"required"`)
+}
+
+func TestListWebhooks(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{
+		{ID: 1, URL: "https://example.com/hook", Secret: webhookSecret, Events: "order.created"}}, nil)
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Dao: dao}).HandleListWebhooks(w, httptest.NewRequest("GET", "/admin/webhooks", nil), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	assert.Contains(t, w.Body.String(), `"events":["order.created"]`)
+	assert.NotContains(t, w.Body.String(), webhookSecret)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("DeleteWebhookSubscription", mock.Anything, uint64(1)).Return(true, nil)
+	dao.On("DeleteWebhookSubscription", mock.Anything, uint64(2)).Return(false, nil)
+	dep := &Dependencies{Dao: dao}
+
+	w := httptest.NewRecorder()
+	dep.HandleDeleteWebhook(w, httptest.NewRequest("DELETE", "/admin/webhooks/1", nil), webhookParams("1", ""))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	dep.HandleDeleteWebhook(w, httptest.NewRequest("DELETE", "/admin/webhooks/2", nil), webhookParams("2", ""))
+	checkNonEmptyResponse(t, w, http.StatusNotFound)
+
+	w = httptest.NewRecorder()
+	dep.HandleDeleteWebhook(w, httptest.NewRequest("DELETE", "/admin/webhooks/x", nil), webhookParams("x", ""))
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindWebhookDeliveries", mock.Anything, uint64(1), entity.WebhookDead, 10, 10, mock.Anything).Return([]entity.WebhookDelivery{
+		{ID: 7, SubscriptionID: 1, Event: webhook.EventOrderCreated, Status: entity.WebhookDead, Attempts: 8, LastStatus: 500}}, nil)
+	w := httptest.NewRecorder()
+
+	(&Dependencies{Dao: dao}).HandleListWebhookDeliveries(w, httptest.NewRequest("GET", "/admin/webhooks/1/deliveries?status=dead&page=2&limit=10", nil), webhookParams("1", ""))
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	var deliveries []entity.WebhookDelivery
+	_ = json.NewDecoder(w.Body).Decode(&deliveries)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, 8, deliveries[0].Attempts)
+}
+
+func TestListWebhookDeliveriesInvalidStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	(&Dependencies{}).HandleListWebhookDeliveries(w, httptest.NewRequest("GET", "/admin/webhooks/1/deliveries?status=failed", nil), webhookParams("1", ""))
+
+	checkNonEmptyResponse(t, w, http.StatusBadRequest)
+}
+
+func TestRetryWebhookDelivery(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("RetryWebhookDelivery", mock.Anything, uint64(1), uint64(7), mock.Anything).Return(true, nil)
+	dao.On("RetryWebhookDelivery", mock.Anything, uint64(1), uint64(8), mock.Anything).Return(false, nil)
+	dep := &Dependencies{Dao: dao}
+
+	w := httptest.NewRecorder()
+	dep.HandleRetryWebhookDelivery(w, httptest.NewRequest("POST", "/admin/webhooks/1/deliveries/7/retry", nil), webhookParams("1", "7"))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	dep.HandleRetryWebhookDelivery(w, httptest.NewRequest("POST", "/admin/webhooks/1/deliveries/8/retry", nil), webhookParams("1", "8"))
+	checkNonEmptyResponse(t, w, http.StatusNotFound)
+}
+
+func TestWebhookSink(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{
+		{ID: 1, Events: "order.created,order.taken"}, {ID: 2, Events: "order.taken"}}, nil)
+	dao.On("CreateWebhookDeliveries", mock.Anything, mock.Anything).Return(nil)
+	dep := &Dependencies{Dao: dao}
+
+	err := dep.WebhookSink().Publish(context.Background(), outbox.Event{ID: 5, Type: webhook.EventOrderCreated, Payload: []byte(`{"n":1}`)})
+
+	assert.Nil(t, err)
+	deliveries := dao.Calls[1].Arguments.Get(1).([]entity.WebhookDelivery)
+	assert.Equal(t, []entity.WebhookDelivery{{SubscriptionID: 1, EventID: 5, Event: webhook.EventOrderCreated, Payload: `{"n":1}`,
+		Status: entity.WebhookPending, NextAttemptAt: deliveries[0].NextAttemptAt}}, deliveries)
+}
+
+func TestWebhookSinkWithoutSubscribers(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{{ID: 2, Events: "order.taken"}}, nil)
+
+	assert.Nil(t, (&Dependencies{Dao: dao}).WebhookSink().Publish(context.Background(), outbox.Event{ID: 5, Type: webhook.EventOrderCreated}))
+	dao.AssertNotCalled(t, "CreateWebhookDeliveries", mock.Anything, mock.Anything)
+}
+
+func TestWebhookSinkDBError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return(nil, errors.New("down"))
+
+	assert.NotNil(t, (&Dependencies{Dao: dao}).WebhookSink().Publish(context.Background(), outbox.Event{ID: 5, Type: webhook.EventOrderCreated}))
+}
+
+// receiver records the correctly signed deliveries it got, failing those to /down
+type receiver struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if !webhook.Verify(webhookSecret, r.Header.Get(webhook.HeaderTimestamp), body, r.Header.Get(webhook.HeaderSignature)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	rc.mu.Lock()
+	rc.received = append(rc.received, r.URL.Path+" "+r.Header.Get(webhook.HeaderDelivery)+" "+string(body))
+	rc.mu.Unlock()
+	if r.URL.Path == "/down" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	dao := &GormDBMock{}
+	dao.On("FindDueWebhookDeliveries", mock.Anything, mock.Anything, 10, mock.Anything).Return([]entity.WebhookDelivery{
+		{ID: 1, SubscriptionID: 1, Event: webhook.EventOrderCreated, Payload: `{"n":1}`, Status: entity.WebhookPending},
+		{ID: 2, SubscriptionID: 2, Event: webhook.EventOrderCreated, Payload: `{"n":2}`, Status: entity.WebhookPending},
+		{ID: 3, SubscriptionID: 2, Event: webhook.EventOrderCreated, Payload: `{"n":3}`, Status: entity.WebhookPending, Attempts: 2},
+		{ID: 4, SubscriptionID: 9, Event: webhook.EventOrderCreated, Payload: `{"n":4}`, Status: entity.WebhookPending},
+	}, nil)
+	dao.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{
+		{ID: 1, URL: srv.URL + "/up", Secret: webhookSecret}, {ID: 2, URL: srv.URL + "/down", Secret: webhookSecret}}, nil)
+	dao.On("ClaimWebhookDelivery", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	updated := map[uint64]entity.WebhookDelivery{}
+	dao.On("UpdateWebhookDelivery", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		d := args.Get(1).(*entity.WebhookDelivery)
+		updated[d.ID] = *d
+	})
+	dep := &Dependencies{Dao: dao, Webhooks: WebhookConfig{Sender: webhook.NewSender(time.Second, true), MaxAttempts: 3,
+		Backoff: webhook.Backoff{Base: time.Minute, Max: time.Hour}}}
+
+	start := time.Now()
+	n, err := dep.DeliverWebhooks(context.Background(), 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"/up 1 {\"n\":1}", "/down 2 {\"n\":2}", "/down 3 {\"n\":3}"}, rc.received)
+
+	assert.Equal(t, entity.WebhookDelivered, updated[1].Status)
+	assert.Equal(t, http.StatusOK, updated[1].LastStatus)
+	assert.NotNil(t, updated[1].DeliveredAt)
+
+	assert.Equal(t, entity.WebhookPending, updated[2].Status)
+	assert.Equal(t, 1, updated[2].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, updated[2].LastStatus)
+	assert.WithinDuration(t, start.Add(time.Minute), updated[2].NextAttemptAt, 5*time.Second)
+
+	assert.Equal(t, entity.WebhookDead, updated[3].Status)
+	assert.Equal(t, 3, updated[3].Attempts)
+	assert.Contains(t, updated[3].LastError, "503")
+
+	assert.Equal(t, entity.WebhookDead, updated[4].Status)
+	assert.Contains(t, updated[4].LastError, "webhook 9 not found")
+}
+
+func TestDeliverWebhooksClaimedElsewhere(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	dao := &GormDBMock{}
+	dao.On("FindDueWebhookDeliveries", mock.Anything, mock.Anything, 10, mock.Anything).Return([]entity.WebhookDelivery{
+		{ID: 1, SubscriptionID: 1, Payload: `{}`, Status: entity.WebhookPending}}, nil)
+	dao.On("FindWebhookSubscriptions", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{
+		{ID: 1, URL: srv.URL, Secret: webhookSecret}}, nil)
+	dao.On("ClaimWebhookDelivery", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	dep := &Dependencies{Dao: dao, Webhooks: WebhookConfig{Sender: webhook.NewSender(time.Second, true)}}
+
+	n, err := dep.DeliverWebhooks(context.Background(), 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Empty(t, rc.received)
+	dao.AssertNotCalled(t, "UpdateWebhookDelivery", mock.Anything, mock.Anything)
+}
+
+func TestDeliverWebhooksDBError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindDueWebhookDeliveries", mock.Anything, mock.Anything, 10, mock.Anything).Return(nil, errors.New("down"))
+
+	_, err := (&Dependencies{Dao: dao}).DeliverWebhooks(context.Background(), 10)
+
+	assert.NotNil(t, err)
+}
+
+func newJSONRequest(method string, target string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func webhookParams(id string, delivery string) httprouter.Params {
+	return httprouter.Params{httprouter.Param{Key: "id", Value: id}, httprouter.Param{Key: "delivery", Value: delivery}}
+}
@@ -0,0 +1,135 @@
+package requesthandler
+
+import (
+	"context"
+	"encoding/json"
+	"entity"
+	"fmt"
+	"geo"
+	"geofence"
+	"github.com/julienschmidt/httprouter"
+	"logging"
+	"net/http"
+	"responseutil"
+)
+
+// HandleListZones returns the service areas as GeoJSON feature collection
+func (dep *Dependencies) HandleListZones(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	responseutil.WriteJSONToResponse(geofence.Collection(dep.Zones.List()), w)
+}
+
+// HandleReplaceZones replaces every service area with the features of the GeoJSON feature collection.
+// An empty collection lets orders be placed anywhere.
+func (dep *Dependencies) HandleReplaceZones(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !checkContentType(r, w, "application/json") {
+		return
+	}
+	zones, errs, err := geofence.Parse(r.Body)
+	if err != nil {
+		responseutil.WriteError(w, r, http.StatusBadRequest, responseutil.CodeInvalidJSON, "Request body is not valid GeoJSON")
+		return
+	}
+	if len(errs) > 0 {
+		fieldErrors := make([]responseutil.FieldError, len(errs))
+		for i, e := range errs {
+			fieldErrors[i] = responseutil.FieldError{Field: e.Field, Code: "invalid", Detail: e.Message}
+		}
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusBadRequest, responseutil.CodeValidationFailed, "Invalid service areas", fieldErrors...))
+		return
+	}
+
+	if err := dep.SaveZones(r.Context(), zones); err != nil {
+		logging.FromContext(r.Context()).Errorf("Cannot replace service areas: %v", err)
+		responseutil.WriteError(w, r, http.StatusInternalServerError, responseutil.CodeInternal, "Service areas could not be saved")
+		return
+	}
+	logging.FromContext(r.Context()).Infof("Service areas replaced with %d zones", len(zones))
+
+	responseutil.WriteJSONToResponse(geofence.Collection(zones), w)
+}
+
+// SaveZones stores zones in place of all service areas and applies them
+func (dep *Dependencies) SaveZones(ctx context.Context, zones []geofence.Zone) error {
+	areas, err := serviceAreas(zones)
+	if err != nil {
+		return err
+	}
+	if err := dep.Dao.ReplaceServiceAreas(dep.db(ctx), areas); err != nil {
+		return err
+	}
+	dep.Zones.Replace(zones)
+	return nil
+}
+
+// SeedZones stores zones unless service areas were seeded or replaced before, applying them when stored. It
+// returns whether zones were stored.
+func (dep *Dependencies) SeedZones(ctx context.Context, zones []geofence.Zone) (bool, error) {
+	areas, err := serviceAreas(zones)
+	if err != nil {
+		return false, err
+	}
+	seeded, err := dep.Dao.SeedServiceAreas(dep.db(ctx), areas)
+	if err != nil || !seeded {
+		return false, err
+	}
+	dep.Zones.Replace(zones)
+	return true, nil
+}
+
+// ReloadZones reads the service areas from the database, so replacements made through other instances apply
+func (dep *Dependencies) ReloadZones(ctx context.Context) error {
+	var areas []entity.ServiceArea
+	if err := dep.Dao.FindServiceAreas(dep.db(ctx), &areas); err != nil {
+		return err
+	}
+	zones := make([]geofence.Zone, len(areas))
+	for i, a := range areas {
+		zones[i].Name = a.Name
+		if err := json.Unmarshal([]byte(a.Geometry), &zones[i].Area); err != nil {
+			return fmt.Errorf("service area %q: %v", a.Name, err)
+		}
+	}
+	dep.Zones.Replace(zones)
+	return nil
+}
+
+// locateZone returns the service area of the origin. When origin or destination is outside every service area
+// the problem is written and false is returned.
+func (dep *Dependencies) locateZone(w http.ResponseWriter, r *http.Request, t *trip) (string, bool) {
+	points := []struct {
+		path   string
+		coords []string
+		point  geo.Point
+	}{
+		{"origin", t.req.Origin, t.origin},
+		{"destination", t.req.Destination, t.destination},
+	}
+
+	var outside []responseutil.FieldError
+	zones := make([]string, len(points))
+	for i, p := range points {
+		name, ok := dep.Zones.Locate(p.point)
+		if !ok {
+			outside = append(outside, responseutil.FieldError{Field: p.path, Code: "serviceArea", Detail: "is outside the service area", Value: p.coords})
+		}
+		zones[i] = name
+	}
+	if len(outside) > 0 {
+		responseutil.WriteProblem(w, r, responseutil.NewProblem(http.StatusUnprocessableEntity, responseutil.CodeOutsideServiceArea,
+			"Order is outside the service area", outside...))
+		return "", false
+	}
+	return zones[0], true
+}
+
+func serviceAreas(zones []geofence.Zone) ([]entity.ServiceArea, error) {
+	areas := make([]entity.ServiceArea, len(zones))
+	for i, z := range zones {
+		b, err := json.Marshal(z.Area)
+		if err != nil {
+			return nil, err
+		}
+		areas[i] = entity.ServiceArea{Name: z.Name, Position: i, Geometry: string(b)}
+	}
+	return areas, nil
+}
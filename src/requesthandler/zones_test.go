@@ -0,0 +1,140 @@
+package requesthandler
+
+import (
+	"context"
+	"encoding/json"
+	"entity"
+	"errors"
+	"geofence"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"responseutil"
+	"strings"
+	"testing"
+)
+
+const centralArea = `{"type":"Polygon","coordinates":[[[114.15,22.27],[114.19,22.27],[114.19,22.29],[114.15,22.29],[114.15,22.27]]]}`
+
+const zonesBody = `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "central"}, "geometry": ` + centralArea + `}]}`
+
+func getCentralZones(t *testing.T) *geofence.Zones {
+	zones, _, err := geofence.Parse(strings.NewReader(zonesBody))
+	if err != nil {
+		t.Fatalf("Cannot parse zones: %v", err)
+	}
+	z := &geofence.Zones{}
+	z.Replace(zones)
+	return z
+}
+
+func TestNewOrderTaggedWithZone(t *testing.T) {
+	w := httptest.NewRecorder()
+	dao := getMockDaoForNewOrder(id, nil)
+	dep := &Dependencies{Dao: dao, MapHelper: getMockMapForNewOrder(distance, nil), Zones: getCentralZones(t)}
+
+	dep.HandleNewOrder(w, newOrderRequest(normalCoordinates), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	if created := dao.Calls[0].Arguments.Get(1).(*entity.Order); created.Zone != "central" {
+		t.Errorf("Expected order in zone central, got %q", created.Zone)
+	}
+}
+
+func TestNewOrderOutsideZones(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Zones: getCentralZones(t)}
+
+	dep.HandleNewOrder(w, newOrderRequest(`{"origin": ["22.2802", "114.184919"], "destination": ["25.033964", "121.564468"]}`), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusUnprocessableEntity)
+	var problem responseutil.Problem
+	_ = json.NewDecoder(w.Body).Decode(&problem)
+	if problem.Code != responseutil.CodeOutsideServiceArea || len(problem.Errors) != 1 || problem.Errors[0].Field != "destination" {
+		t.Errorf("Expected destination outside service area, got %#v", problem)
+	}
+}
+
+func TestListZones(t *testing.T) {
+	w := httptest.NewRecorder()
+	dep := &Dependencies{Zones: getCentralZones(t)}
+
+	dep.HandleListZones(w, httptest.NewRequest("GET", "/admin/zones", nil), nil)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"name":"central"`) || !strings.Contains(w.Body.String(), centralArea) {
+		t.Errorf("Unexpected zones %s", w.Body.String())
+	}
+}
+
+func TestReplaceZones(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("ReplaceServiceAreas", mock.Anything, []entity.ServiceArea{{Name: "central", Position: 0, Geometry: centralArea}}).Return(nil)
+	dep := &Dependencies{Dao: dao, Zones: &geofence.Zones{}}
+
+	w := testReplaceZones(dep, zonesBody)
+
+	checkNonEmptyResponse(t, w, http.StatusOK)
+	dao.AssertExpectations(t)
+	if len(dep.Zones.List()) != 1 {
+		t.Errorf("Expected zones to be applied")
+	}
+}
+
+func TestSeedZones(t *testing.T) {
+	zones := getCentralZones(t).List()
+	for _, seeded := range []bool{true, false} {
+		dao := &GormDBMock{}
+		dao.On("SeedServiceAreas", mock.Anything, []entity.ServiceArea{{Name: "central", Position: 0, Geometry: centralArea}}).Return(seeded, nil)
+		dep := &Dependencies{Dao: dao, Zones: &geofence.Zones{}}
+
+		ok, err := dep.SeedZones(context.Background(), zones)
+
+		if err != nil || ok != seeded {
+			t.Errorf("Expected seeded %v, got %v %v", seeded, ok, err)
+		}
+		if applied := len(dep.Zones.List()) == 1; applied != seeded {
+			t.Errorf("Expected zones applied %v, got %v", seeded, applied)
+		}
+	}
+}
+
+func TestReplaceZonesInvalid(t *testing.T) {
+	dep := &Dependencies{Zones: &geofence.Zones{}}
+
+	checkNonEmptyResponse(t, testReplaceZones(dep, `{"type": "FeatureCollection", "features": [{"type": "Feature"}]}`), http.StatusBadRequest)
+	checkNonEmptyResponse(t, testReplaceZones(dep, `{"type": `), http.StatusBadRequest)
+}
+
+func TestReplaceZonesDBError(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("ReplaceServiceAreas", mock.Anything, mock.Anything).Return(errors.New(""))
+	dep := &Dependencies{Dao: dao, Zones: &geofence.Zones{}}
+
+	checkNonEmptyResponse(t, testReplaceZones(dep, zonesBody), http.StatusInternalServerError)
+	if len(dep.Zones.List()) != 0 {
+		t.Errorf("Expected zones not to be applied")
+	}
+}
+
+func TestReloadZones(t *testing.T) {
+	dao := &GormDBMock{}
+	dao.On("FindServiceAreas", mock.Anything, mock.Anything).Return([]entity.ServiceArea{{Name: "central", Geometry: centralArea}}, nil).Once()
+	dao.On("FindServiceAreas", mock.Anything, mock.Anything).Return([]entity.ServiceArea{{Name: "broken", Geometry: "{}"}}, nil)
+	dep := &Dependencies{Dao: dao, Zones: &geofence.Zones{}}
+
+	if err := dep.ReloadZones(context.Background()); err != nil || len(dep.Zones.List()) != 1 {
+		t.Errorf("Expected zones to be loaded, err %v", err)
+	}
+	if err := dep.ReloadZones(context.Background()); err == nil || len(dep.Zones.List()) != 1 {
+		t.Errorf("Expected invalid zones to be rejected, err %v", err)
+	}
+}
+
+func testReplaceZones(dep *Dependencies, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("PUT", "/admin/zones", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	dep.HandleReplaceZones(w, r, nil)
+	return w
+}
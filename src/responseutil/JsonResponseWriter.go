@@ -1,26 +1,30 @@
 package responseutil
 
 import (
+	"bufio"
 	"encoding/json"
-	"fmt"
+	"net"
 	"net/http"
 )
 
-type ErrorMessage struct {
-	Error string `json:"error"`
+func WriteJSONToResponse(v interface{}, w http.ResponseWriter) {
+	WriteJSONToResponseWithStatus(v, w, http.StatusOK)
 }
 
-func WriteJSONToResponse(v interface{}, w http.ResponseWriter) {
-	setResponseHeaderToJson(w, http.StatusOK)
+func WriteJSONToResponseWithStatus(v interface{}, w http.ResponseWriter, code int) {
+	setResponseHeaderToJson(w, code)
 	if err := json.NewEncoder(w).Encode(v); err != nil {
-		WriteJSONErrorResponse(w, fmt.Sprintf("Cannot marshal JSON body: %v", err), http.StatusInternalServerError)
+		WriteError(w, nil, http.StatusInternalServerError, CodeInternal, "Cannot marshal JSON body")
 		return
 	}
 }
 
-func WriteJSONErrorResponse(w http.ResponseWriter, error string, code int) {
-	setResponseHeaderToJson(w, code)
-	_, _ = fmt.Fprintln(w, getErrorJsonString(error))
+// WriteRawResponse writes an already marshalled body
+func WriteRawResponse(w http.ResponseWriter, contentType string, body []byte, code int) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
 }
 
 func setResponseHeaderToJson(w http.ResponseWriter, code int) {
@@ -29,10 +33,44 @@ func setResponseHeaderToJson(w http.ResponseWriter, code int) {
 	w.WriteHeader(code)
 }
 
-func getErrorJsonString(errMsg string) string {
-	s, err := json.Marshal(&ErrorMessage{errMsg})
-	if err != nil {
-		panic(fmt.Sprintf("Cannot marshal json: %s", errMsg))
+// StatusWriter records the status code and number of bytes written through it
+type StatusWriter struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int
+}
+
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (sw *StatusWriter) WriteHeader(code int) {
+	sw.Status = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *StatusWriter) Write(b []byte) (int, error) {
+	n, err := sw.ResponseWriter.Write(b)
+	sw.Bytes += n
+	return n, err
+}
+
+func (sw *StatusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
 	}
-	return string(s)
+}
+
+// Hijack lets handlers take over the connection, e.g. for WebSockets
+func (sw *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(sw.ResponseWriter).Hijack()
+	if err == nil {
+		sw.Status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to extend the write deadline
+func (sw *StatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
 }
@@ -0,0 +1,94 @@
+package responseutil
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:llmc:problem:"
+	requestIDHeader    = "X-Request-ID"
+)
+
+// Error codes are part of the API, clients branch on them - never change existing ones
+const (
+	CodeInternal             = "internal_error"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeRateLimited          = "rate_limited"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInvalidJSON          = "invalid_json"
+	CodeRequestTooLarge      = "request_too_large"
+	CodeValidationFailed     = "validation_failed"
+	CodeInvalidQuery         = "invalid_query"
+	CodeInvalidID            = "invalid_id"
+	CodeOrderNotFound        = "order_not_found"
+	CodeOrderModified        = "order_modified"
+	CodeOrderNotUpdated      = "order_not_updated"
+	CodeInvalidStatus        = "invalid_status"
+	CodeDistanceUnavailable  = "distance_unavailable"
+	CodeDistanceNotFound     = "distance_not_found"
+	CodeGeocodingUnavailable = "geocoding_unavailable"
+	CodeAddressNotFound      = "address_not_found"
+	CodePricingUnavailable   = "pricing_unavailable"
+	CodeOutsideServiceArea   = "outside_service_area"
+	CodeRouteIdenticalPoints = "route_identical_points"
+	CodeRouteTooShort        = "route_too_short"
+	CodeRouteTooLong         = "route_too_long"
+	CodeRouteDurationTooLong = "route_duration_too_long"
+	CodeWebhookNotFound      = "webhook_not_found"
+	CodeDeliveryNotFound     = "webhook_delivery_not_found"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeIdempotencyKeyBusy   = "idempotency_key_in_progress"
+	CodeInvalidResponse      = "invalid_response"
+)
+
+// Problem is an RFC 7807 error response, extended with a stable code, the request id and field errors
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why one input field was rejected
+type FieldError struct {
+	Field  string      `json:"field"`
+	Code   string      `json:"code"`
+	Detail string      `json:"detail"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+func NewProblem(status int, code string, detail string, errors ...FieldError) *Problem {
+	return &Problem{Type: problemTypePrefix + code, Title: http.StatusText(status), Status: status, Detail: detail,
+		Code: code, Errors: errors}
+}
+
+// WriteProblem writes the problem for request r, which may be nil outside of a request
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if r != nil && r.URL != nil && p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = w.Header().Get(requestIDHeader)
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		b = []byte(`{"type":"` + problemTypePrefix + CodeInternal + `","status":500,"code":"` + CodeInternal + `"}`)
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(append(b, '\n'))
+}
+
+// WriteError writes a problem without field errors
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	WriteProblem(w, r, NewProblem(status, code, detail))
+}
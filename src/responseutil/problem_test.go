@@ -0,0 +1,44 @@
+package responseutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/orders?page=0", nil)
+	w := httptest.NewRecorder()
+	w.Header().Set(requestIDHeader, "abc")
+
+	WriteProblem(w, r, NewProblem(http.StatusBadRequest, CodeInvalidQuery, "Invalid query parameters",
+		FieldError{Field: "page", Code: "minimum", Detail: "must be at least 1", Value: 0}))
+
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("Expected 400 %s, got %d %s", ProblemContentType, w.Code, w.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "urn:llmc:problem:invalid_query" || p.Title != "Bad Request" || p.Status != http.StatusBadRequest ||
+		p.Code != CodeInvalidQuery || p.Instance != "/orders" || p.RequestID != "abc" {
+		t.Errorf("Unexpected problem %#v", p)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "page" || p.Errors[0].Code != "minimum" {
+		t.Errorf("Unexpected field errors %#v", p.Errors)
+	}
+}
+
+func TestWriteErrorWithoutRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	WriteError(w, nil, http.StatusInternalServerError, CodeInternal, "")
+
+	var p Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	if p.Code != CodeInternal || p.Instance != "" || p.Detail != "" {
+		t.Errorf("Unexpected problem %#v", p)
+	}
+}
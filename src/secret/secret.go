@@ -0,0 +1,138 @@
+// Package secret holds credentials such as API keys and passwords. Secrets can be read from files, the way Docker
+// and Kubernetes mount them, and are reloaded when the files change. They print as Redacted, so logging a secret or
+// a struct holding one does not leak it.
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const Redacted = "<redacted>"
+
+// Value is a secret, safe for concurrent use. The nil Value is empty.
+type Value struct {
+	state atomic.Value
+}
+
+// state is the secret and the file it was read from, swapped together
+type state struct {
+	secret string
+	path   string
+}
+
+func New(s string) *Value {
+	v := &Value{}
+	v.Set(s)
+	return v
+}
+
+// Get returns the secret, only to be passed to where it is used
+func (v *Value) Get() string {
+	return v.load().secret
+}
+
+// Path returns the file the secret is read from, empty when it was set directly
+func (v *Value) Path() string {
+	return v.load().path
+}
+
+// Set sets the secret, it is no longer reloaded from a file
+func (v *Value) Set(s string) {
+	v.state.Store(state{secret: s})
+}
+
+// SetFile reads the secret from the file at path, which is reloaded by Reload
+func (v *Value) SetFile(path string) error {
+	s, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	v.state.Store(state{secret: s, path: path})
+	return nil
+}
+
+// Reload reads the file of the secret again, returning whether the secret changed. Secrets set directly do not
+// change.
+func (v *Value) Reload() (bool, error) {
+	old := v.load()
+	if old.path == "" {
+		return false, nil
+	}
+	s, err := readFile(old.path)
+	if err != nil || s == old.secret {
+		return false, err
+	}
+	v.state.Store(state{secret: s, path: old.path})
+	return true, nil
+}
+
+func (v *Value) load() state {
+	if v == nil {
+		return state{}
+	}
+	s, _ := v.state.Load().(state)
+	return s
+}
+
+// String returns Redacted, or empty for an empty secret
+func (v *Value) String() string {
+	if v.Get() == "" {
+		return ""
+	}
+	return Redacted
+}
+
+func (v *Value) GoString() string {
+	return fmt.Sprintf("secret.Value(%q)", v.String())
+}
+
+func (v *Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// readFile returns the content of the secret file, without the trailing line break editors and echo add. The error
+// names the file only.
+func readFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file %s: %v", path, unwrapPathError(err))
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func unwrapPathError(err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err
+	}
+	return err
+}
+
+// Watch reloads the secrets read from files every interval until ctx is done. Files are polled rather than watched,
+// as Kubernetes replaces mounted secrets by swapping a symlink to their directory.
+func Watch(ctx context.Context, interval time.Duration, values ...*Value) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for _, v := range values {
+				changed, err := v.Reload()
+				if err != nil {
+					log.Errorf("Cannot reload secret: %v", err)
+				}
+				if changed {
+					log.Infof("Reloaded secret from %s", v.Path())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRedacted(t *testing.T) {
+	v := New("s3cret")
+	settings := struct {
+		Password *Value
+	}{v}
+
+	for _, s := range []string{fmt.Sprint(v), fmt.Sprintf("%s %q %v %+v %#v", v, v, v, settings, settings)} {
+		assert.NotContains(t, s, "s3cret")
+		assert.Contains(t, s, Redacted)
+	}
+	b, err := json.Marshal(settings)
+	assert.Nil(t, err)
+	var decoded map[string]string
+	assert.Nil(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, Redacted, decoded["Password"])
+	assert.Equal(t, "s3cret", v.Get())
+}
+
+func TestEmpty(t *testing.T) {
+	var v *Value
+
+	assert.Equal(t, "", v.Get())
+	assert.Equal(t, "", v.String())
+	assert.Equal(t, "", New("").String())
+}
+
+func TestReload(t *testing.T) {
+	path := writeFile(t, "old\n")
+	v := &Value{}
+	assert.Nil(t, v.SetFile(path))
+	assert.Equal(t, "old", v.Get())
+
+	changed, err := v.Reload()
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	writeTo(t, path, "new")
+	changed, err = v.Reload()
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "new", v.Get())
+
+	// the secret is kept while the file is missing
+	assert.Nil(t, os.Remove(path))
+	_, err = v.Reload()
+	assert.NotNil(t, err)
+	assert.Equal(t, "new", v.Get())
+}
+
+func TestSetStopsReload(t *testing.T) {
+	path := writeFile(t, "from file")
+	v := &Value{}
+	assert.Nil(t, v.SetFile(path))
+
+	v.Set("direct")
+	writeTo(t, path, "changed")
+	changed, err := v.Reload()
+
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "direct", v.Get())
+	assert.Equal(t, "", v.Path())
+}
+
+func TestSetFileMissing(t *testing.T) {
+	err := (&Value{}).SetFile(filepath.Join(t.TempDir(), "missing"))
+
+	assert.NotNil(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, "old")
+	v := &Value{}
+	assert.Nil(t, v.SetFile(path))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watch(ctx, time.Millisecond, v)
+		close(done)
+	}()
+
+	writeTo(t, path, "new")
+
+	assert.Eventually(t, func() bool { return v.Get() == "new" }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "secret")
+	writeTo(t, path, content)
+	return path
+}
+
+func writeTo(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"dao"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const spanKey = "tracing:span"
+
+// RegisterGormCallbacks creates a client span for every query made through db.
+// Spans are children of the context attached with dao.WithContext.
+func RegisterGormCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	cb.Create().Before("gorm:begin_transaction").Register("tracing:before_create", before("create"))
+	cb.Create().After("gorm:commit_or_rollback_transaction").Register("tracing:after_create", after)
+	cb.Query().Before("gorm:query").Register("tracing:before_query", before("query"))
+	cb.Query().After("gorm:after_query").Register("tracing:after_query", after)
+	cb.Update().Before("gorm:begin_transaction").Register("tracing:before_update", before("update"))
+	cb.Update().After("gorm:commit_or_rollback_transaction").Register("tracing:after_update", after)
+	cb.Delete().Before("gorm:begin_transaction").Register("tracing:before_delete", before("delete"))
+	cb.Delete().After("gorm:commit_or_rollback_transaction").Register("tracing:after_delete", after)
+	cb.RowQuery().Before("gorm:row_query").Register("tracing:before_row_query", before("row_query"))
+	cb.RowQuery().After("gorm:row_query").Register("tracing:after_row_query", after)
+}
+
+func before(operation string) func(*gorm.Scope) {
+	tracer := otel.Tracer("dao")
+	return func(scope *gorm.Scope) {
+		_, span := tracer.Start(dao.ContextOf(scope.DB()), "db "+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", scope.Dialect().GetName()),
+				attribute.String("db.operation", operation),
+				attribute.String("db.sql.table", scope.TableName()),
+			))
+		scope.Set(spanKey, span)
+	}
+}
+
+func after(scope *gorm.Scope) {
+	v, ok := scope.Get(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.String("db.statement", scope.SQL), attribute.Int64("db.rows_affected", scope.DB().RowsAffected))
+	if err := scope.DB().Error; err != nil && err != gorm.ErrRecordNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
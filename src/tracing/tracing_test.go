@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestInstrumentContinuesTrace(t *testing.T) {
+	sr := setupRecorder()
+	var inner trace.SpanContext
+	h := Instrument("/orders/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		inner = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r, _ := http.NewRequest("PATCH", "/orders/10", nil)
+	r.Header.Set("traceparent", traceparent)
+
+	h(httptest.NewRecorder(), r, nil)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "PATCH /orders/:id" {
+		t.Errorf("Expected span named after route, got %s", s.Name())
+	}
+	if s.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || s.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected parent from traceparent, got %v", s.Parent())
+	}
+	if inner.SpanID() != s.SpanContext().SpanID() {
+		t.Errorf("Expected handler to run within the server span")
+	}
+	if s.Status().Code != codes.Error {
+		t.Errorf("Expected error status for 500, got %v", s.Status())
+	}
+}
+
+func TestInitUnknownExporter(t *testing.T) {
+	if _, err := Init(context.Background(), "zipkin"); err == nil {
+		t.Errorf("Expected error for unknown exporter")
+	}
+}
+
+func TestInitNone(t *testing.T) {
+	shutdown, err := Init(context.Background(), ExporterNone)
+	if err != nil || shutdown(context.Background()) != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func setupRecorder() *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return sr
+}
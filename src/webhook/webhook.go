@@ -0,0 +1,180 @@
+// Package webhook signs and sends event notifications to subscribed URLs
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderTaken         = "order.taken"
+	EventOrderStatusChanged = "order.status_changed"
+
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+	// of the response body read to reuse the connection, the body itself is not kept
+	maxResponseDrain = 64 << 10
+)
+
+// ErrForbiddenTarget is returned for webhook URLs on loopback, private or link-local addresses, which would let
+// subscribers reach internal services
+var ErrForbiddenTarget = errors.New("webhook: target address is not allowed")
+
+// Events are the event types subscriptions can choose from
+var Events = []string{EventOrderCreated, EventOrderTaken, EventOrderStatusChanged}
+
+// ValidEvent is true for one of Events
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the X-Webhook-Signature of body sent at timestamp (unix seconds), the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with secret. Including the timestamp lets receivers reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	_, _ = mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is true when signature is the signature of body sent at timestamp, in constant time
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, t, body)), []byte(signature))
+}
+
+// Backoff is the delay before retrying a failed delivery, doubling from Base with every attempt up to Max
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the delay after the attempt-th failed attempt, counting from 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	return d
+}
+
+// Message is one delivery of an event to a subscriber
+type Message struct {
+	URL    string
+	Secret string
+	Event  string
+	// identifies the delivery across retries, so receivers can drop duplicates
+	DeliveryID string
+	Body       []byte
+}
+
+// Sender posts messages
+type Sender struct {
+	Client *http.Client
+	// allows targets on loopback, private and link-local addresses, e.g. receivers in the same network
+	AllowPrivate bool
+}
+
+// NewSender returns a sender giving up after timeout. Redirects are not followed, and unless allowPrivate the
+// connections are checked not to go to loopback, private or link-local addresses, whatever the URL resolves to.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+				return ErrForbiddenTarget
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be checked instead of the target
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Sender{AllowPrivate: allowPrivate, Client: &http.Client{Timeout: timeout, Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}}
+}
+
+// CheckURL rejects URLs naming a forbidden target directly, so subscriptions fail early. Host names resolving to
+// one are only caught when sending.
+func (s *Sender) CheckURL(u *url.URL) error {
+	if s.AllowPrivate {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenTarget
+	}
+	if ip := net.ParseIP(host); ip != nil && forbiddenIP(ip) {
+		return ErrForbiddenTarget
+	}
+	return nil
+}
+
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// Send posts the signed message, returning the status code received. Any status other than 2xx is an error,
+// including redirects. The response body is not reported, receivers could use it to read internal responses.
+func (s *Sender) Send(ctx context.Context, m Message) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, m.URL, bytes.NewReader(m.Body))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "llmc-webhook")
+	req.Header.Set(HeaderEvent, m.Event)
+	req.Header.Set(HeaderDelivery, m.DeliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(m.Secret, timestamp, m.Body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// let the connection be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxResponseDrain))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"order.created"}`)
+	sig := Sign("s3cret", 1700000000, body)
+
+	assert.Equal(t, "sha256=", sig[:7])
+	assert.True(t, Verify("s3cret", "1700000000", body, sig))
+	assert.False(t, Verify("other", "1700000000", body, sig))
+	assert.False(t, Verify("s3cret", "1700000001", body, sig))
+	assert.False(t, Verify("s3cret", "1700000000", []byte(`{}`), sig))
+	assert.False(t, Verify("s3cret", "now", body, sig))
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Base: 10 * time.Second, Max: time.Minute}
+
+	assert.Equal(t, 10*time.Second, b.Delay(1))
+	assert.Equal(t, 20*time.Second, b.Delay(2))
+	assert.Equal(t, 40*time.Second, b.Delay(3))
+	assert.Equal(t, time.Minute, b.Delay(4))
+	assert.Equal(t, time.Minute, b.Delay(100))
+}
+
+func TestSend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, EventOrderCreated, r.Header.Get(HeaderEvent))
+		assert.Equal(t, "42", r.Header.Get(HeaderDelivery))
+		assert.True(t, Verify("s3cret", r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+		assert.Equal(t, `{"id":1}`, string(body))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	code, err := NewSender(time.Second, true).Send(context.Background(),
+		Message{URL: srv.URL, Secret: "s3cret", Event: EventOrderCreated, DeliveryID: "42", Body: []byte(`{"id":1}`)})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, code)
+}
+
+func TestSendRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	code, err := NewSender(time.Second, true).Send(context.Background(), Message{URL: srv.URL, Body: []byte(`{}`)})
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.NotContains(t, err.Error(), "try later")
+}
+
+func TestSendRedirectNotFollowed(t *testing.T) {
+	followed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed = true
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	code, err := NewSender(time.Second, true).Send(context.Background(), Message{URL: srv.URL, Body: []byte(`{}`)})
+
+	assert.Equal(t, http.StatusTemporaryRedirect, code)
+	assert.NotNil(t, err)
+	assert.False(t, followed)
+}
+
+func TestSendPrivateTargetRejected(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+	// a host name is resolved while connecting, the address connected to is what gets rejected
+	u := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	_, err := NewSender(time.Second, false).Send(context.Background(), Message{URL: u, Body: []byte(`{}`)})
+
+	assert.True(t, errors.Is(err, ErrForbiddenTarget), "got %v", err)
+	assert.False(t, called)
+}
+
+func TestCheckURL(t *testing.T) {
+	s := NewSender(time.Second, false)
+	for raw, allowed := range map[string]bool{
+		"https://example.com/hook":      true,
+		"https://93.184.216.34/hook":    true,
+		"http://localhost:8080/":        false,
+		"http://127.0.0.1/":             false,
+		"http://10.1.2.3/":              false,
+		"http://192.168.0.1/":           false,
+		"http://169.254.169.254/latest": false,
+		"http://[::1]/":                 false,
+		"http://[fe80::1]/":             false,
+		"http://0.0.0.0/":               false,
+	} {
+		u, _ := url.Parse(raw)
+		assert.Equal(t, allowed, s.CheckURL(u) == nil, raw)
+	}
+	u, _ := url.Parse("http://127.0.0.1/")
+	assert.Nil(t, NewSender(time.Second, true).CheckURL(u))
+}
+
+func TestSendUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	code, err := NewSender(time.Second, true).Send(context.Background(), Message{URL: srv.URL, Body: []byte(`{}`)})
+
+	assert.Equal(t, 0, code)
+	assert.NotNil(t, err)
+}